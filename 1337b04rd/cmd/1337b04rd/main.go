@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"1337b04rd/internal/adapters/database"
 	d "1337b04rd/internal/adapters/database"
 	"1337b04rd/internal/adapters/s3"
+	"1337b04rd/internal/app/domain/models"
 	"1337b04rd/internal/app/domain/ports"
 	"1337b04rd/internal/app/domain/services"
+	"1337b04rd/internal/config"
 	"1337b04rd/internal/interface/handlers"
 	"1337b04rd/internal/interface/middleware"
 	"1337b04rd/internal/interface/routes"
@@ -35,10 +42,14 @@ func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
-	dsn := "host=db port=5432 user=board_user password=board_pass dbname=board_db sslmode=disable"
+	cfg := config.Load()
+
+	// Cancelled on SIGINT/SIGTERM to stop background jobs and the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Connect to DB
-	postRepo, commentRepo, db, err := initRepository(dsn)
+	postRepo, commentRepo, db, err := initRepository(cfg.DatabaseDSN)
 	if err != nil {
 		logger.Error("Failed to initialize repository", "error", err)
 		os.Exit(1)
@@ -46,30 +57,120 @@ func main() {
 	defer db.Close()
 
 	// Create services
+	boardService := services.NewBoardService(d.NewBoardRepositoryPg(db), cfg.DefaultBoard)
 	commentService := services.NewCommentService(commentRepo)
+	commentService.BumpLimit = cfg.BumpLimit
+	commentService.Boards = boardService
 	sessionRepo := &database.PostgresSessionRepo{DB: db}
 	sessionService := services.NewSessionService(sessionRepo)
-	postService := services.NewPostService(postRepo, sessionRepo)
-	postService.StartArchiver()
+	postService := services.NewPostService(postRepo, sessionRepo, boardService)
+	moderationService := services.NewModerationService(d.NewModeratorRepositoryPg(db), d.NewModerationRepositoryPg(db, cfg.ImageDeleteGrace))
+	if cfg.AdminName != "" && cfg.AdminPassword != "" {
+		if err := moderationService.EnsureModerator(cfg.AdminName, cfg.AdminPassword, models.RoleAdmin); err != nil {
+			logger.Error("Failed to create admin account", "error", err)
+		}
+	}
+
+	reportRepo := d.NewReportRepositoryPg(db)
+	reportService := services.NewReportService(reportRepo, moderationService)
+	reportService.RateLimit = cfg.ReportRateLimit
+	reportService.RateWindow = cfg.ReportRateWindow
+
+	duplicateService := services.NewDuplicateService(d.NewContentRepositoryPg(db), reportRepo)
+	duplicateService.Policy = cfg.DuplicatePolicy
+	postService.Duplicates = duplicateService
+	commentService.Duplicates = duplicateService
+
+	filterService := services.NewFilterService(d.NewFilterRuleRepositoryPg(db))
+	// Starting without the rules would let through what they are meant to stop
+	if err := filterService.Reload(); err != nil {
+		logger.Error("Failed to load filter rules", "error", err)
+		os.Exit(1)
+	}
+	filterService.StartReloader(ctx, cfg.FilterReloadInterval)
+	postService.Filters = filterService
+	commentService.Filters = filterService
+
+	tripcoder := services.NewTripcoder(cfg.TripcodeSalt)
+	postService.Tripcodes = tripcoder
+	commentService.Tripcodes = tripcoder
+
+	posterIDs := services.NewPosterIDs(cfg.PosterIDSalt)
+	postService.PosterIDs = posterIDs
+	commentService.PosterIDs = posterIDs
 
 	// S3 Adapter setup
 	s3Adapter := &s3.Adapter{
 		TripleSBaseURL:  "http://triple-s:9000",
 		PublicAccessURL: "http://localhost:9000",
+		ThumbnailSize:   cfg.ThumbnailSize,
+		Limits: s3.ImageLimits{
+			MaxBytes:     int64(cfg.ImageMaxBytes),
+			MaxDimension: cfg.ImageMaxDimension,
+			MaxPixels:    cfg.ImageMaxPixels,
+		},
+		MaxImages: cfg.MaxAttachments,
+		Client: s3.NewClient(s3.Timeouts{
+			Connect:  cfg.S3ConnectTimeout,
+			Response: cfg.S3ResponseTimeout,
+			Request:  cfg.S3RequestTimeout,
+		}),
+		Retry: s3.RetryPolicy{
+			Attempts: cfg.S3RetryAttempts,
+			Backoff:  cfg.S3RetryBackoff,
+		},
+	}
+
+	imageService := services.NewImageService(s3Adapter, d.NewImageRepositoryPg(db))
+	imageService.Grace = cfg.ImageDeleteGrace
+	postService.Images = imageService
+	postService.StartArchiver(ctx, cfg.ArchivePolicy, cfg.PurgePolicy, cfg.ArchiveInterval)
+	if cfg.MigrateImageKeys {
+		go func() {
+			if _, err := postService.MigrateImageKeys(ctx); err != nil {
+				logger.Error("Image key migration stopped", "error", err)
+			}
+		}()
 	}
 
+	markupService := services.NewMarkupService(d.NewQuoteRepositoryPg(db))
+
 	// Handlers
-	postHandler := handlers.NewPostHandler(postService, s3Adapter, commentService)
+	postHandler := handlers.NewPostHandler(postService, s3Adapter, commentService, markupService, boardService)
 	commentHandler := handlers.NewCommentHandler(commentService, sessionRepo, s3Adapter)
+	apiHandler := handlers.NewAPIHandler(postService, commentService, sessionRepo, s3Adapter, markupService, boardService)
+	postHandler.Images = imageService
+	commentHandler.Images = imageService
+	apiHandler.Images = imageService
+	reportHandler := handlers.NewReportHandler(reportService)
+	searchHandler := handlers.NewSearchHandler(services.NewSearchService(d.NewSearchRepositoryPg(db)), boardService)
+	modHandler := handlers.NewModHandler(moderationService, reportService, duplicateService, filterService, boardService)
 	authMiddleware := middleware.AuthMiddleware{SessionService: sessionService}
+	floodMiddleware := middleware.FloodMiddleware{
+		Guard:      services.NewFloodGuard(cfg.FloodPolicy, nil),
+		TrustProxy: cfg.TrustProxy,
+	}
+	modMiddleware := middleware.ModMiddleware{ModerationService: moderationService}
 
 	// Router setup
 	mux := http.NewServeMux()
-	routes.RegisterRoutes(mux, postHandler, commentHandler, authMiddleware)
+	routes.RegisterRoutes(mux, postHandler, commentHandler, apiHandler, reportHandler, searchHandler, modHandler, authMiddleware, floodMiddleware, modMiddleware)
 
 	// Start server
-	logger.Info("Server is running on port 8080...")
-	if err := http.ListenAndServe(":8080", mux); err != nil {
-		logger.Error("Failed to start server", "error", err)
+	server := &http.Server{Addr: ":8080", Handler: mux}
+	go func() {
+		logger.Info("Server is running on port 8080...")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Failed to start server", "error", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down server", "error", err)
 	}
 }
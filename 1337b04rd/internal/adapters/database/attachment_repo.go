@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// attachmentColumns lists the columns read by loadAttachments, after the
+// owner ID.
+const attachmentColumns = `id, position, url, COALESCE(thumbnail_url, ''), content_type, size, width, height, COALESCE(name, ''), hash, created_at`
+
+// insertAttachments stores the attachments of a new post or comment, owner
+// being "post_id" or "comment_id". IDs and creation times are filled in.
+func insertAttachments(tx *sql.Tx, owner string, ownerID int, attachments []models.Attachment) error {
+	query := `INSERT INTO attachments (` + owner + `, position, url, thumbnail_url, content_type, size, width, height, name, hash)
+	          VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, NULLIF($9, ''), $10)
+	          RETURNING id, created_at`
+	for i := range attachments {
+		a := &attachments[i]
+		a.Position = i
+		err := tx.QueryRow(query, ownerID, a.Position, a.URL, a.ThumbnailURL, a.ContentType, a.Size, a.Width, a.Height, a.Name, a.Hash).
+			Scan(&a.ID, &a.CreatedAt)
+		if err != nil {
+			slog.Error("Error storing attachment", "owner", owner, "ownerID", ownerID, "error", err)
+			return fmt.Errorf("error storing attachment: %v", err)
+		}
+	}
+	return nil
+}
+
+// loadAttachments returns the attachments of the posts or comments with the
+// given IDs, owner being "post_id" or "comment_id", keyed by owner ID and
+// in position order.
+func loadAttachments(db *sql.DB, owner string, ids []int) (map[int][]models.Attachment, error) {
+	byOwner := make(map[int][]models.Attachment)
+	if len(ids) == 0 {
+		return byOwner, nil
+	}
+	query := `SELECT ` + owner + `, ` + attachmentColumns + `
+	          FROM attachments
+	          WHERE ` + owner + ` = ANY($1)
+	          ORDER BY ` + owner + `, position`
+
+	rows, err := db.Query(query, intArray(ids))
+	if err != nil {
+		slog.Error("Error getting attachments", "owner", owner, "error", err)
+		return nil, fmt.Errorf("error getting attachments: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ownerID int
+		var a models.Attachment
+		err := rows.Scan(&ownerID, &a.ID, &a.Position, &a.URL, &a.ThumbnailURL, &a.ContentType, &a.Size, &a.Width, &a.Height, &a.Name, &a.Hash, &a.CreatedAt)
+		if err != nil {
+			slog.Error("Error scanning attachment", "error", err)
+			return nil, fmt.Errorf("error scanning attachment: %v", err)
+		}
+		byOwner[ownerID] = append(byOwner[ownerID], a)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating attachments: %v", rows.Err())
+	}
+	return byOwner, nil
+}
+
+// attachToPosts fills Attachments of posts.
+func attachToPosts(db *sql.DB, posts []*models.Post) error {
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	byPost, err := loadAttachments(db, "post_id", ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range posts {
+		p.Attachments = byPost[p.ID]
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+
+	"github.com/lib/pq"
+)
+
+// BoardRepositoryPg stores boards in PostgreSQL.
+type BoardRepositoryPg struct {
+	db *sql.DB
+}
+
+// NewBoardRepositoryPg creates a new instance of the board repository.
+func NewBoardRepositoryPg(db *sql.DB) ports.BoardRepository {
+	return &BoardRepositoryPg{db: db}
+}
+
+// boardColumns lists the columns read by scanBoard. Queries alias boards as b.
+const boardColumns = `b.slug, b.title, b.description, b.archive_ttl_no_replies, b.archive_ttl_replies,
+	b.max_threads, b.require_image, b.text_only, b.poster_ids, b.image_formats, b.created_at`
+
+// scanBoard scans boardColumns into a board. TTLs are stored in seconds.
+func scanBoard(row rowScanner) (*models.Board, error) {
+	var board models.Board
+	var noReplyTTL, replyTTL int64
+	var formats pq.StringArray
+	err := row.Scan(&board.Slug, &board.Title, &board.Description, &noReplyTTL, &replyTTL,
+		&board.MaxThreads, &board.RequireImage, &board.TextOnly, &board.PosterIDs, &formats, &board.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range formats {
+		board.ImageFormats = append(board.ImageFormats, models.ImageFormat(f))
+	}
+	board.Archive.NoReplyTTL = time.Duration(noReplyTTL) * time.Second
+	board.Archive.ReplyTTL = time.Duration(replyTTL) * time.Second
+	return &board, nil
+}
+
+// GetBoards returns all boards ordered by slug.
+func (r *BoardRepositoryPg) GetBoards() ([]*models.Board, error) {
+	query := `SELECT ` + boardColumns + ` FROM boards b ORDER BY b.slug ASC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		slog.Error("Error getting boards", "error", err)
+		return nil, fmt.Errorf("error getting boards: %v", err)
+	}
+	defer rows.Close()
+
+	var boards []*models.Board
+	for rows.Next() {
+		board, err := scanBoard(rows)
+		if err != nil {
+			slog.Error("Error scanning board", "error", err)
+			return nil, fmt.Errorf("error scanning board: %v", err)
+		}
+		boards = append(boards, board)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating boards: %v", rows.Err())
+	}
+	return boards, nil
+}
+
+// GetBoard returns the board with the given slug.
+func (r *BoardRepositoryPg) GetBoard(slug string) (*models.Board, error) {
+	query := `SELECT ` + boardColumns + ` FROM boards b WHERE b.slug = $1`
+	return r.getBoard(query, slug)
+}
+
+// GetBoardByPostID returns the board a thread was posted on.
+func (r *BoardRepositoryPg) GetBoardByPostID(postID int) (*models.Board, error) {
+	query := `SELECT ` + boardColumns + ` FROM boards b JOIN posts p ON p.board = b.slug WHERE p.id = $1`
+	return r.getBoard(query, postID)
+}
+
+func (r *BoardRepositoryPg) getBoard(query string, arg any) (*models.Board, error) {
+	board, err := scanBoard(r.db.QueryRow(query, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Error getting board", "key", arg, "error", err)
+		return nil, fmt.Errorf("error getting board: %v", err)
+	}
+	return board, nil
+}
+
+// SaveBoard inserts a board or updates the settings of an existing one.
+func (r *BoardRepositoryPg) SaveBoard(board *models.Board) error {
+	query := `INSERT INTO boards (slug, title, description, archive_ttl_no_replies, archive_ttl_replies,
+	                              max_threads, require_image, text_only, poster_ids, image_formats, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	          ON CONFLICT (slug) DO UPDATE SET
+	              title = EXCLUDED.title,
+	              description = EXCLUDED.description,
+	              archive_ttl_no_replies = EXCLUDED.archive_ttl_no_replies,
+	              archive_ttl_replies = EXCLUDED.archive_ttl_replies,
+	              max_threads = EXCLUDED.max_threads,
+	              require_image = EXCLUDED.require_image,
+	              text_only = EXCLUDED.text_only,
+	              poster_ids = EXCLUDED.poster_ids,
+	              image_formats = EXCLUDED.image_formats
+	          RETURNING created_at`
+
+	formats := pq.StringArray{}
+	for _, f := range board.ImageFormats {
+		formats = append(formats, string(f))
+	}
+	err := r.db.QueryRow(query, board.Slug, board.Title, board.Description,
+		int64(board.Archive.NoReplyTTL/time.Second), int64(board.Archive.ReplyTTL/time.Second),
+		board.MaxThreads, board.RequireImage, board.TextOnly, board.PosterIDs, formats, board.CreatedAt).Scan(&board.CreatedAt)
+	if err != nil {
+		slog.Error("Error saving board", "slug", board.Slug, "error", err)
+		return fmt.Errorf("error saving board: %v", err)
+	}
+	slog.Info("Board saved", "slug", board.Slug)
+	return nil
+}
+
+// DeleteBoard deletes a board that has no threads, live or archived.
+func (r *BoardRepositoryPg) DeleteBoard(slug string) error {
+	query := `DELETE FROM boards WHERE slug = $1 AND NOT EXISTS (SELECT 1 FROM posts WHERE board = $1)`
+	res, err := r.db.Exec(query, slug)
+	if err != nil {
+		slog.Error("Error deleting board", "slug", slug, "error", err)
+		return fmt.Errorf("error deleting board: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting board: %v", err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	board, err := r.GetBoard(slug)
+	if err != nil {
+		return err
+	}
+	if board == nil {
+		return fmt.Errorf("board %q does not exist: %w", slug, models.ErrNotFound)
+	}
+	return fmt.Errorf("board %q still has threads: %w", slug, models.ErrConflict)
+}
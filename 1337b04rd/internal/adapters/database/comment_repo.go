@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -20,56 +21,101 @@ func NewCommentRepositoryPg(db *sql.DB) ports.CommentRepository {
 	return &CommentRepositoryPg{db: db}
 }
 
-// CreateComment creates a new comment for the given post.
-func (r *CommentRepositoryPg) CreateComment(comment models.Comment) (*models.Comment, error) {
-	// Check if the post exists
-	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1)`
-	err := r.db.QueryRow(query, comment.PostID).Scan(&exists)
+// CreateComment creates a new comment for the given post and bumps the thread
+// in the same transaction. Sage replies and replies past bumpLimit (0 means no
+// limit) touch updated_at but leave bumped_at alone.
+func (r *CommentRepositoryPg) CreateComment(comment models.Comment, bumpLimit int) (*models.Comment, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
-		slog.Error("Error checking post existence", "postID", comment.PostID, "error", err)
-		return nil, fmt.Errorf("error checking post existence: %v", err)
+		slog.Error("Error starting transaction", "error", err)
+		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
+	defer tx.Rollback()
 
-	if !exists {
+	// Lock the post so concurrent replies see a consistent reply count
+	var replyCount int
+	var locked bool
+	query := `SELECT (SELECT COUNT(*) FROM comments WHERE post_id = p.id), p.is_locked
+	          FROM posts p WHERE p.id = $1 AND p.archived_at IS NULL AND p.is_hidden = FALSE
+	          FOR UPDATE`
+	err = tx.QueryRow(query, comment.PostID).Scan(&replyCount, &locked)
+	if errors.Is(err, sql.ErrNoRows) {
 		slog.Warn("Post does not exist", "postID", comment.PostID)
-		return nil, fmt.Errorf("post with id %d does not exist", comment.PostID)
+		return nil, fmt.Errorf("post with id %d does not exist: %w", comment.PostID, models.ErrNotFound)
+	}
+	if err != nil {
+		slog.Error("Error checking post existence", "postID", comment.PostID, "error", err)
+		return nil, fmt.Errorf("error checking post existence: %v", err)
+	}
+	if locked {
+		slog.Warn("Post is locked", "postID", comment.PostID)
+		return nil, fmt.Errorf("post with id %d is locked: %w", comment.PostID, models.ErrForbidden)
 	}
 
 	// Insert the new comment
 	query = `
-	INSERT INTO comments (post_id, parent_comment_id, user_name, user_avatar, text, image_url, created_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	INSERT INTO comments (post_id, parent_comment_id, user_name, tripcode, user_avatar, text, image_url, sage, created_at, text_hash, image_hash, poster_id, thumbnail_url, image_name)
+	VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''))
 	RETURNING id`
 
 	var id int
-	err = r.db.QueryRow(
+	err = tx.QueryRow(
 		query,
 		comment.PostID,
 		comment.ParentCommentID,
 		comment.UserName,
+		comment.Tripcode,
 		comment.UserAvatar,
 		comment.Text,
 		comment.ImageURL, // Added field for image
+		comment.Sage,
 		comment.CreatedAt,
+		comment.TextHash,
+		comment.ImageHash,
+		comment.PosterID,
+		comment.ThumbnailURL,
+		comment.ImageName,
 	).Scan(&id)
 	if err != nil {
 		slog.Error("Error creating comment", "error", err)
 		return nil, fmt.Errorf("error creating comment: %v", err)
 	}
 
+	if err := insertAttachments(tx, "comment_id", id, comment.Attachments); err != nil {
+		return nil, err
+	}
+	if err := insertReferences(tx, id, comment.PostID, comment.QuotedIDs); err != nil {
+		return nil, err
+	}
+
+	// Bump the thread unless the reply is saged or the thread hit the bump limit
+	bump := !comment.Sage && (bumpLimit <= 0 || replyCount < bumpLimit)
+	query = `UPDATE posts
+	         SET updated_at = $2, bumped_at = CASE WHEN $3 THEN $2 ELSE bumped_at END
+	         WHERE id = $1`
+	if _, err := tx.Exec(query, comment.PostID, comment.CreatedAt, bump); err != nil {
+		slog.Error("Error bumping post", "postID", comment.PostID, "error", err)
+		return nil, fmt.Errorf("error bumping post: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing comment", "error", err)
+		return nil, fmt.Errorf("error committing comment: %v", err)
+	}
+
 	comment.ID = id
-	slog.Info("Successfully created comment", "commentID", id)
+	slog.Info("Successfully created comment", "commentID", id, "bumped", bump)
 	return &comment, nil
 }
 
-// GetCommentsByPostID retrieves all comments for the given post.
+// GetCommentsByPostID retrieves all comments for the given post in
+// chronological order. Replies are not nested; see services.BuildCommentView.
 func (r *CommentRepositoryPg) GetCommentsByPostID(postID int) ([]*models.Comment, error) {
 	query := `
-		SELECT id, post_id, parent_comment_id, user_name, user_avatar, text, image_url, created_at
+		SELECT id, post_id, parent_comment_id, user_name, COALESCE(tripcode, ''), COALESCE(poster_id, ''), user_avatar, text, image_url, COALESCE(thumbnail_url, ''), COALESCE(image_name, ''), sage, created_at
 		FROM comments
 		WHERE post_id = $1
-		ORDER BY created_at ASC`
+		ORDER BY created_at ASC, id ASC`
 
 	rows, err := r.db.Query(query, postID)
 	if err != nil {
@@ -79,7 +125,6 @@ func (r *CommentRepositoryPg) GetCommentsByPostID(postID int) ([]*models.Comment
 	defer rows.Close()
 
 	var comments []*models.Comment
-	commentMap := make(map[int][]*models.Comment)
 
 	// Reading the comments
 	for rows.Next() {
@@ -89,36 +134,155 @@ func (r *CommentRepositoryPg) GetCommentsByPostID(postID int) ([]*models.Comment
 			&c.PostID,
 			&c.ParentCommentID,
 			&c.UserName,
+			&c.Tripcode,
+			&c.PosterID,
 			&c.UserAvatar,
 			&c.Text,
 			&c.ImageURL, // Added field for image
+			&c.ThumbnailURL,
+			&c.ImageName,
+			&c.Sage,
 			&c.CreatedAt,
 		)
 		if err != nil {
 			slog.Error("Error scanning comment", "error", err)
 			return nil, fmt.Errorf("error scanning comment: %v", err)
 		}
+		comments = append(comments, &c)
+	}
 
-		// If it's a reply, add it to the map
-		if c.ParentCommentID != nil {
-			commentMap[*c.ParentCommentID] = append(commentMap[*c.ParentCommentID], &c)
-		} else {
-			comments = append(comments, &c)
-		}
+	if rows.Err() != nil {
+		slog.Error("Error iterating comments", "error", rows.Err())
+		return nil, fmt.Errorf("error iterating comments: %v", rows.Err())
 	}
 
-	// Adding replies to parent comments
-	for i := range comments {
-		parent := comments[i]
-		if replies, ok := commentMap[parent.ID]; ok {
-			parent.Replies = replies
-		}
+	if err := r.attachBacklinks(postID, comments); err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+	byComment, err := loadAttachments(r.db, "comment_id", ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range comments {
+		c.Attachments = byComment[c.ID]
 	}
 
 	slog.Info("Successfully retrieved comments", "postID", postID, "commentCount", len(comments))
 	return comments, nil
 }
 
+// insertReferences stores the >>id quotes of a new comment. Each ID is
+// resolved the same way the markup renderer links it: a comment in the same
+// thread, the thread itself, another thread, then a comment elsewhere. IDs
+// that match nothing are skipped.
+func insertReferences(tx *sql.Tx, commentID, postID int, quotedIDs []int) error {
+	if len(quotedIDs) == 0 {
+		return nil
+	}
+	ids := intArray(quotedIDs)
+
+	posts := make(map[int]bool)
+	rows, err := tx.Query(`SELECT id FROM posts WHERE id = ANY($1)`, ids)
+	if err != nil {
+		slog.Error("Error resolving quoted posts", "error", err)
+		return fmt.Errorf("error resolving quoted posts: %v", err)
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning quoted post: %v", err)
+		}
+		posts[id] = true
+	}
+	rows.Close()
+
+	comments := make(map[int]int) // comment ID -> thread
+	rows, err = tx.Query(`SELECT id, post_id FROM comments WHERE id = ANY($1) AND id <> $2`, ids, commentID)
+	if err != nil {
+		slog.Error("Error resolving quoted comments", "error", err)
+		return fmt.Errorf("error resolving quoted comments: %v", err)
+	}
+	for rows.Next() {
+		var id, thread int
+		if err := rows.Scan(&id, &thread); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning quoted comment: %v", err)
+		}
+		comments[id] = thread
+	}
+	rows.Close()
+
+	query := `INSERT INTO comment_references (comment_id, target_type, target_id)
+	          VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	for _, id := range quotedIDs {
+		thread, isComment := comments[id]
+		var targetType string
+		switch {
+		case isComment && thread == postID:
+			targetType = "comment"
+		case id == postID || posts[id]:
+			targetType = "post"
+		case isComment:
+			targetType = "comment"
+		default:
+			continue
+		}
+		if _, err := tx.Exec(query, commentID, targetType, id); err != nil {
+			slog.Error("Error storing comment reference", "commentID", commentID, "targetID", id, "error", err)
+			return fmt.Errorf("error storing comment reference: %v", err)
+		}
+	}
+	return nil
+}
+
+// attachBacklinks fills Backlinks of the comments of a thread with the
+// comments that quote them, from any visible thread.
+func (r *CommentRepositoryPg) attachBacklinks(postID int, comments []*models.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	query := `
+		SELECT ref.target_id, ref.comment_id, src.post_id, p.archived_at IS NOT NULL
+		FROM comment_references ref
+		JOIN comments target ON target.id = ref.target_id
+		JOIN comments src ON src.id = ref.comment_id
+		JOIN posts p ON p.id = src.post_id
+		WHERE ref.target_type = 'comment' AND target.post_id = $1 AND p.is_hidden = FALSE
+		ORDER BY ref.comment_id ASC`
+
+	rows, err := r.db.Query(query, postID)
+	if err != nil {
+		slog.Error("Error getting backlinks", "postID", postID, "error", err)
+		return fmt.Errorf("error getting backlinks: %v", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int]*models.Comment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+	for rows.Next() {
+		var targetID int
+		var link models.Backlink
+		if err := rows.Scan(&targetID, &link.ID, &link.PostID, &link.Archived); err != nil {
+			slog.Error("Error scanning backlink", "error", err)
+			return fmt.Errorf("error scanning backlink: %v", err)
+		}
+		if c, ok := byID[targetID]; ok {
+			c.Backlinks = append(c.Backlinks, link)
+		}
+	}
+	if rows.Err() != nil {
+		return fmt.Errorf("error iterating backlinks: %v", rows.Err())
+	}
+	return nil
+}
+
 // DeleteComment deletes a comment by its ID.
 func (r *CommentRepositoryPg) DeleteComment(commentID string) error {
 	// Convert commentID from string to int
@@ -0,0 +1,70 @@
+package database_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/adapters/database"
+	"1337b04rd/internal/app/domain/models"
+)
+
+func TestBacklinks(t *testing.T) {
+	db := testDB(t)
+	posts := database.NewPostRepositoryPg(db)
+	comments := database.NewCommentRepositoryPg(db)
+
+	reply := func(postID int, quoted ...int) int {
+		t.Helper()
+		c, err := comments.CreateComment(models.Comment{PostID: postID, UserName: "Rick", Text: "reply", CreatedAt: time.Now(), QuotedIDs: quoted}, 0)
+		if err != nil {
+			t.Fatalf("CreateComment failed: %v", err)
+		}
+		return c.ID
+	}
+
+	a := insertPost(t, db, testPost{title: "a"})
+	b := insertPost(t, db, testPost{title: "b"})
+	archived := insertPost(t, db, testPost{title: "archived"})
+	hidden := insertPost(t, db, testPost{title: "hidden"})
+
+	target := reply(a)
+	sameThread := reply(a, target, a)
+	otherThread := reply(b, target, a, 999999) // 999999 matches nothing
+	fromArchive := reply(archived, target)
+	reply(hidden, target, a)
+	if _, err := db.Exec(`UPDATE posts SET archived_at = NOW() WHERE id = $1`, archived); err != nil {
+		t.Fatalf("archive thread: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE posts SET is_hidden = TRUE WHERE id = $1`, hidden); err != nil {
+		t.Fatalf("hide thread: %v", err)
+	}
+
+	list, err := comments.GetCommentsByPostID(a)
+	if err != nil {
+		t.Fatalf("GetCommentsByPostID failed: %v", err)
+	}
+	want := []models.Backlink{
+		{ID: sameThread, PostID: a},
+		{ID: otherThread, PostID: b},
+		{ID: fromArchive, PostID: archived, Archived: true},
+	}
+	if got := list[0].Backlinks; !reflect.DeepEqual(got, want) {
+		t.Errorf("comment backlinks = %+v, want %+v", got, want)
+	}
+
+	post, err := posts.GetPostByID(fmt.Sprint(a))
+	if err != nil {
+		t.Fatalf("GetPostByID failed: %v", err)
+	}
+	if got, want := post.Backlinks, want[:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("post backlinks = %+v, want %+v", got, want)
+	}
+
+	var refs int
+	db.QueryRow(`SELECT COUNT(*) FROM comment_references WHERE comment_id = $1`, otherThread).Scan(&refs)
+	if refs != 2 {
+		t.Errorf("%d references stored for a quote of a missing ID, want 2", refs)
+	}
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// ContentRepositoryPg queries content fingerprints and banned images.
+type ContentRepositoryPg struct {
+	db *sql.DB
+}
+
+// NewContentRepositoryPg creates a new instance of the content repository.
+func NewContentRepositoryPg(db *sql.DB) ports.ContentRepository {
+	return &ContentRepositoryPg{db: db}
+}
+
+// HasRecentText reports whether any post or comment with textHash was created
+// at or after since.
+func (r *ContentRepositoryPg) HasRecentText(textHash string, since time.Time) (bool, error) {
+	return r.hasRecent("text_hash", textHash, since, "")
+}
+
+// HasRecentImage reports whether any post, comment or attachment with
+// imageHash was created at or after since.
+func (r *ContentRepositoryPg) HasRecentImage(imageHash string, since time.Time) (bool, error) {
+	return r.hasRecent("image_hash", imageHash, since, `OR EXISTS (SELECT 1 FROM attachments WHERE hash = $1 AND created_at >= $2)`)
+}
+
+// hasRecent runs the duplicate lookup for column, which is one of the fixed
+// hash column names above, with also appended to the condition.
+func (r *ContentRepositoryPg) hasRecent(column, hash string, since time.Time, also string) (bool, error) {
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM posts WHERE %[1]s = $1 AND created_at >= $2)
+	                          OR EXISTS (SELECT 1 FROM comments WHERE %[1]s = $1 AND created_at >= $2) %[2]s`, column, also)
+
+	var found bool
+	if err := r.db.QueryRow(query, hash, since).Scan(&found); err != nil {
+		slog.Error("Error looking up duplicate content", "column", column, "error", err)
+		return false, fmt.Errorf("error looking up duplicate content: %v", err)
+	}
+	return found, nil
+}
+
+// GetImageHash returns the hash of the first attachment of a post or
+// comment, or the image_hash of one made before attachments were stored.
+func (r *ContentRepositoryPg) GetImageHash(targetType string, targetID int) (string, error) {
+	var query string
+	switch targetType {
+	case "post":
+		query = `SELECT COALESCE((SELECT hash FROM attachments WHERE post_id = p.id ORDER BY position LIMIT 1), p.image_hash, '')
+		         FROM posts p WHERE p.id = $1`
+	case "comment":
+		query = `SELECT COALESCE((SELECT hash FROM attachments WHERE comment_id = c.id ORDER BY position LIMIT 1), c.image_hash, '')
+		         FROM comments c WHERE c.id = $1`
+	default:
+		return "", fmt.Errorf("unknown target type %q: %w", targetType, models.ErrInvalidInput)
+	}
+
+	var hash string
+	err := r.db.QueryRow(query, targetID).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%s %d does not exist: %w", targetType, targetID, models.ErrNotFound)
+	}
+	if err != nil {
+		slog.Error("Error getting image hash", "targetType", targetType, "targetID", targetID, "error", err)
+		return "", fmt.Errorf("error getting image hash: %v", err)
+	}
+	return hash, nil
+}
+
+// IsImageBanned reports whether imageHash is on the ban list.
+func (r *ContentRepositoryPg) IsImageBanned(imageHash string) (bool, error) {
+	var banned bool
+	query := `SELECT EXISTS (SELECT 1 FROM banned_images WHERE hash = $1)`
+	if err := r.db.QueryRow(query, imageHash).Scan(&banned); err != nil {
+		slog.Error("Error checking banned image", "error", err)
+		return false, fmt.Errorf("error checking banned image: %v", err)
+	}
+	return banned, nil
+}
+
+// BanImage adds a hash to the ban list and records action in the audit log
+// in one transaction. Banning a hash twice keeps the original entry and
+// logs nothing.
+func (r *ContentRepositoryPg) BanImage(ban *models.BannedImage, action *models.ModAction) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO banned_images (hash, reason, moderator_id, moderator_name, created_at)
+	          VALUES ($1, $2, $3, $4, $5)
+	          ON CONFLICT (hash) DO NOTHING`
+	res, err := tx.Exec(query, ban.Hash, ban.Reason, ban.ModeratorID, ban.ModeratorName, ban.CreatedAt)
+	if err != nil {
+		slog.Error("Error banning image", "hash", ban.Hash, "error", err)
+		return fmt.Errorf("error banning image: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	if err := insertModAction(tx, action); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing image ban", "error", err)
+		return fmt.Errorf("error committing image ban: %v", err)
+	}
+	return nil
+}
+
+// UnbanImage removes a hash from the ban list and records action in the
+// audit log in one transaction.
+func (r *ContentRepositoryPg) UnbanImage(imageHash string, action *models.ModAction) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM banned_images WHERE hash = $1`, imageHash)
+	if err != nil {
+		slog.Error("Error unbanning image", "hash", imageHash, "error", err)
+		return fmt.Errorf("error unbanning image: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("image %s is not banned: %w", imageHash, models.ErrNotFound)
+	}
+	if err := insertModAction(tx, action); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing image unban", "error", err)
+		return fmt.Errorf("error committing image unban: %v", err)
+	}
+	return nil
+}
+
+// GetBannedImages returns the latest bans first.
+func (r *ContentRepositoryPg) GetBannedImages(limit int) ([]*models.BannedImage, error) {
+	query := `SELECT hash, reason, COALESCE(moderator_id, 0), moderator_name, created_at
+	          FROM banned_images
+	          ORDER BY created_at DESC
+	          LIMIT $1`
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		slog.Error("Error getting banned images", "error", err)
+		return nil, fmt.Errorf("error getting banned images: %v", err)
+	}
+	defer rows.Close()
+
+	var bans []*models.BannedImage
+	for rows.Next() {
+		var ban models.BannedImage
+		if err := rows.Scan(&ban.Hash, &ban.Reason, &ban.ModeratorID, &ban.ModeratorName, &ban.CreatedAt); err != nil {
+			slog.Error("Error scanning banned image", "error", err)
+			return nil, fmt.Errorf("error scanning banned image: %v", err)
+		}
+		bans = append(bans, &ban)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating banned images: %v", rows.Err())
+	}
+	return bans, nil
+}
@@ -0,0 +1,82 @@
+package database_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/adapters/database"
+	"1337b04rd/internal/app/domain/models"
+)
+
+func TestBanImage_WritesAuditLog(t *testing.T) {
+	db := testDB(t)
+	content := database.NewContentRepositoryPg(db)
+	moderation := database.NewModerationRepositoryPg(db, 0)
+
+	hash := strings.Repeat("c", 64)
+	now := time.Now()
+	ban := &models.BannedImage{Hash: hash, Reason: "gore", ModeratorName: "mod", CreatedAt: now}
+	action := func(a models.ModActionType, reason string) *models.ModAction {
+		return &models.ModAction{ModeratorName: "mod", Action: a, TargetType: "image", ImageHash: hash, Reason: reason, CreatedAt: now}
+	}
+
+	if err := content.BanImage(ban, action(models.ActionBanImage, "gore")); err != nil {
+		t.Fatalf("BanImage failed: %v", err)
+	}
+	// A second ban keeps the first one and is not logged
+	if err := content.BanImage(ban, action(models.ActionBanImage, "again")); err != nil {
+		t.Fatalf("second BanImage failed: %v", err)
+	}
+	if err := content.UnbanImage(hash, action(models.ActionUnbanImage, "appeal")); err != nil {
+		t.Fatalf("UnbanImage failed: %v", err)
+	}
+	if err := content.UnbanImage(hash, action(models.ActionUnbanImage, "twice")); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("second UnbanImage: error = %v, want ErrNotFound", err)
+	}
+
+	log, err := moderation.GetAuditLog(10)
+	if err != nil {
+		t.Fatalf("GetAuditLog failed: %v", err)
+	}
+	if len(log) != 2 || log[0].Action != models.ActionUnbanImage || log[1].Action != models.ActionBanImage {
+		t.Fatalf("audit log has %d entries, want unban then ban", len(log))
+	}
+	if log[1].ImageHash != hash || log[1].Reason != "gore" || log[0].Reason != "appeal" {
+		t.Errorf("audit log = %+v, %+v", log[0], log[1])
+	}
+}
+
+func TestGetImageHash(t *testing.T) {
+	db := testDB(t)
+	content := database.NewContentRepositoryPg(db)
+
+	legacy := insertPost(t, db, testPost{title: "legacy"})
+	db.Exec(`UPDATE posts SET image_url = '/img/l.png', image_hash = $2 WHERE id = $1`, legacy, strings.Repeat("1", 64))
+	withAttachments := insertPost(t, db, testPost{title: "attachments"})
+	insertAttachment(t, db, "post_id", withAttachments, 1, "/img/b.png", strings.Repeat("b", 64))
+	insertAttachment(t, db, "post_id", withAttachments, 0, "/img/a.png", strings.Repeat("a", 64))
+	comment := insertComment(t, db, withAttachments, "")
+	insertAttachment(t, db, "comment_id", comment, 0, "/img/c.png", strings.Repeat("c", 64))
+	plain := insertComment(t, db, withAttachments, "")
+
+	tests := []struct {
+		targetType string
+		id         int
+		want       string
+	}{
+		{"post", legacy, strings.Repeat("1", 64)},
+		{"post", withAttachments, strings.Repeat("a", 64)},
+		{"comment", comment, strings.Repeat("c", 64)},
+		{"comment", plain, ""},
+	}
+	for _, tt := range tests {
+		if got, err := content.GetImageHash(tt.targetType, tt.id); err != nil || got != tt.want {
+			t.Errorf("GetImageHash(%s %d) = %q, %v, want %q", tt.targetType, tt.id, got, err, tt.want)
+		}
+	}
+	if _, err := content.GetImageHash("post", withAttachments+100); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("missing post: error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,122 @@
+package database_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// testDB connects to the PostgreSQL server named by TEST_DATABASE_DSN and
+// returns a connection to a fresh schema created from init.sql. The schema
+// is dropped when the test ends. Without TEST_DATABASE_DSN the test is
+// skipped.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d_%d", os.Getpid(), time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	db, err := sql.Open("postgres", withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatalf("open schema: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	initSQL, err := os.ReadFile("../../../../init.sql")
+	if err != nil {
+		t.Fatalf("read init.sql: %v", err)
+	}
+	if _, err := db.Exec(string(initSQL)); err != nil {
+		t.Fatalf("run init.sql: %v", err)
+	}
+	return db
+}
+
+// withSearchPath makes every connection opened with dsn use schema. Both
+// URL and key=value connection strings are accepted.
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return dsn
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// testPost describes a thread inserted by insertPost.
+type testPost struct {
+	board    string
+	title    string
+	bumped   time.Time
+	archived *time.Time
+	hidden   bool
+	pinned   bool
+}
+
+// insertPost stores a thread directly and returns its ID.
+func insertPost(t *testing.T, db *sql.DB, p testPost) int {
+	t.Helper()
+	if p.board == "" {
+		p.board = "b"
+	}
+	if p.bumped.IsZero() {
+		p.bumped = time.Now()
+	}
+	var id int
+	err := db.QueryRow(`INSERT INTO posts (board, title, text, user_name, user_avatar, image_url, created_at, bumped_at, archived_at, is_hidden, is_pinned)
+	                    VALUES ($1, $2, 'text', 'Rick', '', '', $3, $3, $4, $5, $6) RETURNING id`,
+		p.board, p.title, p.bumped, p.archived, p.hidden, p.pinned).Scan(&id)
+	if err != nil {
+		t.Fatalf("insert post: %v", err)
+	}
+	return id
+}
+
+// insertAttachment stores an attachment of the post or comment with the
+// given ID, whichever column is named.
+func insertAttachment(t *testing.T, db *sql.DB, column string, id, position int, url, hash string) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO attachments (`+column+`, position, url, content_type, size, width, height, hash)
+	                   VALUES ($1, $2, $3, 'image/png', 1, 1, 1, $4)`, id, position, url, hash)
+	if err != nil {
+		t.Fatalf("insert attachment: %v", err)
+	}
+}
+
+// insertComment stores a reply to postID directly and returns its ID.
+func insertComment(t *testing.T, db *sql.DB, postID int, imageURL string) int {
+	t.Helper()
+	var id int
+	err := db.QueryRow(`INSERT INTO comments (post_id, user_name, user_avatar, text, image_url, created_at)
+	                    VALUES ($1, 'Rick', '', 'reply', $2, NOW()) RETURNING id`, postID, imageURL).Scan(&id)
+	if err != nil {
+		t.Fatalf("insert comment: %v", err)
+	}
+	return id
+}
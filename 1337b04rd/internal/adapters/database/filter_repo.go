@@ -0,0 +1,74 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// FilterRuleRepositoryPg stores filter rules in PostgreSQL.
+type FilterRuleRepositoryPg struct {
+	db *sql.DB
+}
+
+// NewFilterRuleRepositoryPg creates a new instance of the filter rule repository.
+func NewFilterRuleRepositoryPg(db *sql.DB) ports.FilterRuleRepository {
+	return &FilterRuleRepositoryPg{db: db}
+}
+
+// GetFilterRules returns all rules in the order they were created.
+func (r *FilterRuleRepositoryPg) GetFilterRules() ([]*models.FilterRule, error) {
+	query := `SELECT id, kind, pattern, replacement, created_by, created_at
+	          FROM filter_rules
+	          ORDER BY id ASC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		slog.Error("Error getting filter rules", "error", err)
+		return nil, fmt.Errorf("error getting filter rules: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []*models.FilterRule
+	for rows.Next() {
+		var rule models.FilterRule
+		if err := rows.Scan(&rule.ID, &rule.Kind, &rule.Pattern, &rule.Replacement, &rule.CreatedBy, &rule.CreatedAt); err != nil {
+			slog.Error("Error scanning filter rule", "error", err)
+			return nil, fmt.Errorf("error scanning filter rule: %v", err)
+		}
+		rules = append(rules, &rule)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating filter rules: %v", rows.Err())
+	}
+	return rules, nil
+}
+
+// CreateFilterRule stores a rule and sets its ID.
+func (r *FilterRuleRepositoryPg) CreateFilterRule(rule *models.FilterRule) error {
+	query := `INSERT INTO filter_rules (kind, pattern, replacement, created_by, created_at)
+	          VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err := r.db.QueryRow(query, rule.Kind, rule.Pattern, rule.Replacement, rule.CreatedBy, rule.CreatedAt).Scan(&rule.ID)
+	if err != nil {
+		slog.Error("Error creating filter rule", "error", err)
+		return fmt.Errorf("error creating filter rule: %v", err)
+	}
+	slog.Info("Filter rule created", "ruleID", rule.ID, "kind", rule.Kind)
+	return nil
+}
+
+// DeleteFilterRule removes a rule.
+func (r *FilterRuleRepositoryPg) DeleteFilterRule(id int) error {
+	res, err := r.db.Exec(`DELETE FROM filter_rules WHERE id = $1`, id)
+	if err != nil {
+		slog.Error("Error deleting filter rule", "ruleID", id, "error", err)
+		return fmt.Errorf("error deleting filter rule: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("filter rule %d does not exist: %w", id, models.ErrNotFound)
+	}
+	return nil
+}
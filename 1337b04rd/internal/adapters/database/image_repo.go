@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// ImageRepositoryPg keeps the image deletion queue in PostgreSQL.
+type ImageRepositoryPg struct {
+	db *sql.DB
+}
+
+// NewImageRepositoryPg creates a new instance of the image repository.
+func NewImageRepositoryPg(db *sql.DB) ports.ImageRepository {
+	return &ImageRepositoryPg{db: db}
+}
+
+// scheduleDeletions queues the URLs in $1 for deletion at $2, keeping the
+// later time for URLs already queued.
+const scheduleDeletions = `INSERT INTO image_deletions (url, due_at)
+                           SELECT DISTINCT u, $2::timestamp FROM unnest($1::text[]) AS u WHERE u <> ''
+                           ON CONFLICT (url) DO UPDATE SET due_at = GREATEST(image_deletions.due_at, EXCLUDED.due_at)`
+
+// ScheduleDeletions queues urls for deletion at due, keeping the later time
+// for URLs already queued.
+func (r *ImageRepositoryPg) ScheduleDeletions(ctx context.Context, urls []string, due time.Time) error {
+	if _, err := r.db.ExecContext(ctx, scheduleDeletions, pq.StringArray(urls), due); err != nil {
+		slog.Error("Error scheduling image deletions", "error", err)
+		return fmt.Errorf("error scheduling image deletions: %v", err)
+	}
+	return nil
+}
+
+// CancelDeletions takes urls off the queue. Deleting a row locked by
+// SweepDeletions waits for the sweep to commit.
+func (r *ImageRepositoryPg) CancelDeletions(ctx context.Context, urls []string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM image_deletions WHERE url = ANY($1)`, pq.StringArray(urls)); err != nil {
+		slog.Error("Error cancelling image deletions", "error", err)
+		return fmt.Errorf("error cancelling image deletions: %v", err)
+	}
+	return nil
+}
+
+// imageUnused is true for a URL u that no post, comment or attachment uses
+// as image or thumbnail.
+const imageUnused = `NOT EXISTS (SELECT 1 FROM posts WHERE image_url = u OR thumbnail_url = u)
+                     AND NOT EXISTS (SELECT 1 FROM comments WHERE image_url = u OR thumbnail_url = u)
+                     AND NOT EXISTS (SELECT 1 FROM attachments WHERE url = u OR thumbnail_url = u)`
+
+// SweepDeletions removes due URLs that nothing uses. The queued rows are
+// locked for the whole sweep, skipping those another sweep holds, and the
+// references are checked only once they are locked: a message that uses a
+// URL after that has cancelled its deletion first, which waits for the
+// sweep. URLs whose removal fails stay queued for the next sweep.
+func (r *ImageRepositoryPg) SweepDeletions(ctx context.Context, now time.Time, limit int, remove func(ctx context.Context, url string) error) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	urls, err := queryURLs(ctx, tx, `SELECT url FROM image_deletions WHERE due_at <= $1
+	                                 ORDER BY due_at, url LIMIT $2 FOR UPDATE SKIP LOCKED`, now, limit)
+	if err != nil || len(urls) == 0 {
+		return 0, err
+	}
+	unused, err := queryURLs(ctx, tx, `SELECT u FROM unnest($1::text[]) AS u WHERE `+imageUnused, pq.StringArray(urls))
+	if err != nil {
+		return 0, err
+	}
+	isUnused := make(map[string]bool, len(unused))
+	for _, url := range unused {
+		isUnused[url] = true
+	}
+
+	removed, done := 0, make([]string, 0, len(urls))
+	for _, url := range urls {
+		if isUnused[url] {
+			if err := remove(ctx, url); err != nil {
+				slog.Warn("Failed to delete queued image", "url", url, "error", err)
+				continue
+			}
+			removed++
+		}
+		done = append(done, url)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM image_deletions WHERE url = ANY($1)`, pq.StringArray(done)); err != nil {
+		slog.Error("Error dequeuing image deletions", "error", err)
+		return removed, fmt.Errorf("error dequeuing image deletions: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing image deletions", "error", err)
+		return removed, fmt.Errorf("error committing image deletions: %v", err)
+	}
+	return removed, nil
+}
+
+// queryURLs runs a query returning one text column within tx.
+func queryURLs(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		slog.Error("Error querying image deletions", "error", err)
+		return nil, fmt.Errorf("error querying image deletions: %v", err)
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, fmt.Errorf("error scanning image deletion: %v", err)
+		}
+		urls = append(urls, url)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating image deletions: %v", rows.Err())
+	}
+	return urls, nil
+}
+
+// LockImageMigration takes a session advisory lock on a connection of its
+// own, which unlock gives back to the pool. The lock also ends with the
+// connection, should the process die first.
+func (r *ImageRepositoryPg) LockImageMigration(ctx context.Context) (func(), error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		slog.Error("Error getting connection", "error", err)
+		return nil, fmt.Errorf("error getting connection: %v", err)
+	}
+
+	var locked bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1, 0)`, lockImageMigration).Scan(&locked)
+	if err != nil {
+		conn.Close()
+		slog.Error("Error locking image migration", "error", err)
+		return nil, fmt.Errorf("error locking image migration: %v", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, fmt.Errorf("image keys are being migrated by another process: %w", models.ErrConflict)
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1, 0)`, lockImageMigration); err != nil {
+			// A connection still holding the lock must not go back to the pool
+			slog.Warn("Error unlocking image migration", "error", err)
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
@@ -0,0 +1,124 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/adapters/database"
+	"1337b04rd/internal/app/domain/models"
+)
+
+// queuedURLs lists the URLs in the deletion queue in order.
+func queuedURLs(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT url FROM image_deletions ORDER BY url`)
+	if err != nil {
+		t.Fatalf("list queued deletions: %v", err)
+	}
+	defer rows.Close()
+	var urls []string
+	for rows.Next() {
+		var url string
+		rows.Scan(&url)
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+func TestSweepDeletions_SkipsUsedImages(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewImageRepositoryPg(db)
+	ctx := context.Background()
+
+	// Used again since they were queued: the image of a thread and the
+	// thumbnail of an attachment
+	id := insertPost(t, db, testPost{title: "reused"})
+	db.Exec(`UPDATE posts SET image_url = '/img/post.png' WHERE id = $1`, id)
+	insertAttachment(t, db, "post_id", id, 0, "/img/post.png", strings.Repeat("a", 64))
+	db.Exec(`UPDATE attachments SET thumbnail_url = '/img/thumb.png'`)
+
+	now := time.Now()
+	due := []string{"/img/post.png", "/img/thumb.png", "/img/unused.png", "/img/failing.png"}
+	if err := repo.ScheduleDeletions(ctx, due, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("ScheduleDeletions failed: %v", err)
+	}
+	if err := repo.ScheduleDeletions(ctx, []string{"/img/later.png"}, now.Add(time.Hour)); err != nil {
+		t.Fatalf("ScheduleDeletions failed: %v", err)
+	}
+
+	var removed []string
+	n, err := repo.SweepDeletions(ctx, now, 10, func(ctx context.Context, url string) error {
+		if url == "/img/failing.png" {
+			return errors.New("storage unavailable")
+		}
+		removed = append(removed, url)
+		return nil
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("SweepDeletions = %d, %v, want 1 removed", n, err)
+	}
+	if !reflect.DeepEqual(removed, []string{"/img/unused.png"}) {
+		t.Errorf("removed %q, want only the unused image", removed)
+	}
+	// Used images leave the queue, failed ones are tried again
+	if got, want := queuedURLs(t, db), []string{"/img/failing.png", "/img/later.png"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("queue holds %q, want %q", got, want)
+	}
+}
+
+func TestCancelDeletions_WaitsForSweep(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewImageRepositoryPg(db)
+	ctx := context.Background()
+
+	now := time.Now()
+	if err := repo.ScheduleDeletions(ctx, []string{"/img/a.png"}, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("ScheduleDeletions failed: %v", err)
+	}
+
+	// An upload of the same object cancels the deletion while the sweep
+	// is removing it; it must not go on to store the object before then
+	cancelled := make(chan time.Time)
+	var removedAt time.Time
+	_, err := repo.SweepDeletions(ctx, now, 10, func(ctx context.Context, url string) error {
+		go func() {
+			repo.CancelDeletions(ctx, []string{url})
+			cancelled <- time.Now()
+		}()
+		time.Sleep(200 * time.Millisecond)
+		removedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SweepDeletions failed: %v", err)
+	}
+	if at := <-cancelled; at.Before(removedAt) {
+		t.Error("CancelDeletions returned while the sweep was still removing the object")
+	}
+}
+
+func TestLockImageMigration(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewImageRepositoryPg(db)
+	ctx := context.Background()
+
+	unlock, err := repo.LockImageMigration(ctx)
+	if err != nil {
+		t.Fatalf("LockImageMigration failed: %v", err)
+	}
+	if _, err := repo.LockImageMigration(ctx); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("second lock: error = %v, want ErrConflict", err)
+	}
+	unlock()
+
+	unlock, err = repo.LockImageMigration(ctx)
+	if err != nil {
+		t.Fatalf("lock after unlock failed: %v", err)
+	}
+	unlock()
+}
@@ -0,0 +1,8 @@
+package database
+
+// Advisory lock namespaces, the first key of the two-key forms of
+// pg_advisory_lock. The second key identifies the locked object.
+const (
+	lockReportSession  = 1 // hashtext of the session ID
+	lockImageMigration = 2 // Always 0, there is one migration
+)
@@ -0,0 +1,257 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// ModeratorRepositoryPg stores moderator accounts and sessions in PostgreSQL.
+type ModeratorRepositoryPg struct {
+	db *sql.DB
+}
+
+// NewModeratorRepositoryPg creates a new instance of the moderator repository.
+func NewModeratorRepositoryPg(db *sql.DB) ports.ModeratorRepository {
+	return &ModeratorRepositoryPg{db: db}
+}
+
+// GetModeratorByName retrieves a moderator by name.
+// Returns nil without an error if no moderator has that name.
+func (r *ModeratorRepositoryPg) GetModeratorByName(name string) (*models.Moderator, error) {
+	query := `SELECT id, name, role, password_hash FROM moderators WHERE name = $1`
+
+	var m models.Moderator
+	err := r.db.QueryRow(query, name).Scan(&m.ID, &m.Name, &m.Role, &m.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Error getting moderator", "name", name, "error", err)
+		return nil, fmt.Errorf("error getting moderator: %v", err)
+	}
+	return &m, nil
+}
+
+// UpsertModerator creates a moderator or updates the role and password of an
+// existing one with the same name. The ID is filled in on return.
+func (r *ModeratorRepositoryPg) UpsertModerator(moderator *models.Moderator) error {
+	query := `INSERT INTO moderators (name, role, password_hash)
+	          VALUES ($1, $2, $3)
+	          ON CONFLICT (name) DO UPDATE SET role = EXCLUDED.role, password_hash = EXCLUDED.password_hash
+	          RETURNING id`
+
+	err := r.db.QueryRow(query, moderator.Name, moderator.Role, moderator.PasswordHash).Scan(&moderator.ID)
+	if err != nil {
+		slog.Error("Error saving moderator", "name", moderator.Name, "error", err)
+		return fmt.Errorf("error saving moderator: %v", err)
+	}
+
+	slog.Info("Moderator saved", "name", moderator.Name, "role", moderator.Role)
+	return nil
+}
+
+// CreateModSession stores a login session for a moderator.
+func (r *ModeratorRepositoryPg) CreateModSession(token string, moderatorID int, expiresAt time.Time) error {
+	query := `INSERT INTO mod_sessions (token, moderator_id, expires_at) VALUES ($1, $2, $3)`
+	if _, err := r.db.Exec(query, token, moderatorID, expiresAt); err != nil {
+		slog.Error("Error creating moderator session", "moderatorID", moderatorID, "error", err)
+		return fmt.Errorf("error creating moderator session: %v", err)
+	}
+	return nil
+}
+
+// GetModeratorBySession returns the moderator owning an unexpired session.
+// Returns nil without an error if the session is unknown or expired.
+func (r *ModeratorRepositoryPg) GetModeratorBySession(token string, now time.Time) (*models.Moderator, error) {
+	query := `SELECT m.id, m.name, m.role, m.password_hash
+	          FROM mod_sessions s
+	          JOIN moderators m ON m.id = s.moderator_id
+	          WHERE s.token = $1 AND s.expires_at > $2`
+
+	var m models.Moderator
+	err := r.db.QueryRow(query, token, now).Scan(&m.ID, &m.Name, &m.Role, &m.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("Error getting moderator session", "error", err)
+		return nil, fmt.Errorf("error getting moderator session: %v", err)
+	}
+	return &m, nil
+}
+
+// DeleteModSession removes a moderator session.
+func (r *ModeratorRepositoryPg) DeleteModSession(token string) error {
+	if _, err := r.db.Exec(`DELETE FROM mod_sessions WHERE token = $1`, token); err != nil {
+		slog.Error("Error deleting moderator session", "error", err)
+		return fmt.Errorf("error deleting moderator session: %v", err)
+	}
+	return nil
+}
+
+// ModerationRepositoryPg applies moderation actions in PostgreSQL.
+type ModerationRepositoryPg struct {
+	db    *sql.DB
+	grace time.Duration
+}
+
+// NewModerationRepositoryPg creates a new instance of the moderation
+// repository. Images of deleted posts and comments are queued for deletion
+// grace after the action.
+func NewModerationRepositoryPg(db *sql.DB, grace time.Duration) ports.ModerationRepository {
+	return &ModerationRepositoryPg{db: db, grace: grace}
+}
+
+// actionQueries holds the statement performing each action. Every statement
+// takes the target ID as $1.
+var actionQueries = map[models.ModActionType]string{
+	models.ActionHidePost:      `UPDATE posts SET is_hidden = TRUE WHERE id = $1`,
+	models.ActionUnhidePost:    `UPDATE posts SET is_hidden = FALSE WHERE id = $1`,
+	models.ActionLockPost:      `UPDATE posts SET is_locked = TRUE WHERE id = $1`,
+	models.ActionUnlockPost:    `UPDATE posts SET is_locked = FALSE WHERE id = $1`,
+	models.ActionPinPost:       `UPDATE posts SET is_pinned = TRUE WHERE id = $1`,
+	models.ActionUnpinPost:     `UPDATE posts SET is_pinned = FALSE WHERE id = $1`,
+	models.ActionDeletePost:    `DELETE FROM posts WHERE id = $1`,
+	models.ActionDeleteComment: `DELETE FROM comments WHERE id = $1`,
+}
+
+// actionLocks locks the target of a deleting action, taking its ID as $1.
+// Replies to it wait for the lock on their foreign key, so none is added
+// after actionImages has listed the images.
+var actionLocks = map[models.ModActionType]string{
+	models.ActionDeletePost:    `SELECT id FROM posts WHERE id = $1 FOR UPDATE`,
+	models.ActionDeleteComment: `SELECT id FROM comments WHERE id = $1 FOR UPDATE`,
+}
+
+// actionImages lists the images and thumbnails that an action deletes
+// together with its target, taking the target ID as $1: those of a thread,
+// its comments and their attachments, or those of a comment, the replies
+// that go with it (ON DELETE CASCADE) and their attachments.
+var actionImages = map[models.ModActionType]string{
+	models.ActionDeletePost: `SELECT url FROM (
+	                              SELECT unnest(ARRAY[image_url, thumbnail_url]) AS url FROM posts WHERE id = $1
+	                              UNION
+	                              SELECT unnest(ARRAY[image_url, thumbnail_url]) FROM comments WHERE post_id = $1
+	                              UNION
+	                              SELECT unnest(ARRAY[a.url, a.thumbnail_url]) FROM attachments a
+	                              LEFT JOIN comments c ON c.id = a.comment_id
+	                              WHERE a.post_id = $1 OR c.post_id = $1
+	                          ) t WHERE url <> ''`,
+	models.ActionDeleteComment: `WITH RECURSIVE deleted AS (
+	                                 SELECT id FROM comments WHERE id = $1
+	                                 UNION
+	                                 SELECT c.id FROM comments c JOIN deleted d ON c.parent_comment_id = d.id
+	                             )
+	                             SELECT url FROM (
+	                                 SELECT unnest(ARRAY[image_url, thumbnail_url]) AS url FROM comments WHERE id IN (SELECT id FROM deleted)
+	                                 UNION
+	                                 SELECT unnest(ARRAY[url, thumbnail_url]) FROM attachments WHERE comment_id IN (SELECT id FROM deleted)
+	                             ) t WHERE url <> ''`,
+}
+
+// ApplyAction performs the action and appends it to the audit log in one
+// transaction. The ID and CreatedAt of the action are filled in on return.
+// The images of a deleted post or comment are queued for deletion in the
+// same transaction; the sweep keeps those other messages still use.
+func (r *ModerationRepositoryPg) ApplyAction(action *models.ModAction) error {
+	query, ok := actionQueries[action.Action]
+	if !ok {
+		return fmt.Errorf("unknown moderation action %q: %w", action.Action, models.ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var images []string
+	if imagesQuery, ok := actionImages[action.Action]; ok {
+		if _, err := tx.Exec(actionLocks[action.Action], action.TargetID); err != nil {
+			slog.Error("Error locking moderation target", "action", action.Action, "targetID", action.TargetID, "error", err)
+			return fmt.Errorf("error locking moderation target: %v", err)
+		}
+		images, err = queryURLs(context.Background(), tx, imagesQuery, action.TargetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.Exec(query, action.TargetID)
+	if err != nil {
+		slog.Error("Error applying moderation action", "action", action.Action, "targetID", action.TargetID, "error", err)
+		return fmt.Errorf("error applying moderation action: %v", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s %d does not exist: %w", action.TargetType, action.TargetID, models.ErrNotFound)
+	}
+
+	if len(images) > 0 {
+		if _, err := tx.Exec(scheduleDeletions, pq.StringArray(images), action.CreatedAt.Add(r.grace)); err != nil {
+			slog.Error("Error scheduling image deletions", "error", err)
+			return fmt.Errorf("error scheduling image deletions: %v", err)
+		}
+	}
+	if err := insertModAction(tx, action); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing moderation action", "error", err)
+		return fmt.Errorf("error committing moderation action: %v", err)
+	}
+
+	slog.Info("Moderation action applied", "action", action.Action, "targetID", action.TargetID, "moderator", action.ModeratorName)
+	return nil
+}
+
+// insertModAction appends action to the audit log within tx and fills in
+// its ID.
+func insertModAction(tx *sql.Tx, action *models.ModAction) error {
+	query := `INSERT INTO mod_actions (moderator_id, moderator_name, action, target_type, target_id, image_hash, reason, created_at)
+	          VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+	          RETURNING id`
+	err := tx.QueryRow(query, action.ModeratorID, action.ModeratorName, action.Action, action.TargetType,
+		action.TargetID, action.ImageHash, action.Reason, action.CreatedAt).Scan(&action.ID)
+	if err != nil {
+		slog.Error("Error writing audit log", "error", err)
+		return fmt.Errorf("error writing audit log: %v", err)
+	}
+	return nil
+}
+
+// GetAuditLog returns the most recent moderation actions, newest first.
+func (r *ModerationRepositoryPg) GetAuditLog(limit int) ([]*models.ModAction, error) {
+	query := `SELECT id, COALESCE(moderator_id, 0), moderator_name, action, target_type, target_id, COALESCE(image_hash, ''), COALESCE(reason, ''), created_at
+	          FROM mod_actions
+	          ORDER BY created_at DESC, id DESC
+	          LIMIT $1`
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		slog.Error("Error getting audit log", "error", err)
+		return nil, fmt.Errorf("error getting audit log: %v", err)
+	}
+	defer rows.Close()
+
+	var actions []*models.ModAction
+	for rows.Next() {
+		var a models.ModAction
+		err := rows.Scan(&a.ID, &a.ModeratorID, &a.ModeratorName, &a.Action, &a.TargetType, &a.TargetID, &a.ImageHash, &a.Reason, &a.CreatedAt)
+		if err != nil {
+			slog.Error("Error scanning audit log entry", "error", err)
+			return nil, fmt.Errorf("error scanning audit log entry: %v", err)
+		}
+		actions = append(actions, &a)
+	}
+	return actions, rows.Err()
+}
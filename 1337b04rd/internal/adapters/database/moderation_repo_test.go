@@ -0,0 +1,49 @@
+package database_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/adapters/database"
+	"1337b04rd/internal/app/domain/models"
+)
+
+func TestApplyAction_QueuesDeletedImages(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewModerationRepositoryPg(db, time.Minute)
+
+	thread := insertPost(t, db, testPost{title: "thread"})
+	db.Exec(`UPDATE posts SET image_url = '/img/o1.png', thumbnail_url = '/img/o2.png' WHERE id = $1`, thread)
+	insertAttachment(t, db, "post_id", thread, 0, "/img/o1.png", strings.Repeat("a", 64))
+	insertComment(t, db, thread, "/img/p.png")
+
+	// A comment with an attachment and a reply to it, which goes with it
+	comment := insertComment(t, db, thread, "/img/c1.png")
+	insertAttachment(t, db, "comment_id", comment, 0, "/img/c1.png", strings.Repeat("c", 64))
+	db.Exec(`UPDATE attachments SET thumbnail_url = '/img/c2.png' WHERE comment_id = $1`, comment)
+	reply := insertComment(t, db, thread, "/img/r.png")
+	db.Exec(`UPDATE comments SET parent_comment_id = $2 WHERE id = $1`, reply, comment)
+
+	act := func(a models.ModActionType, target int) {
+		t.Helper()
+		action := &models.ModAction{ModeratorName: "mod", Action: a, TargetType: a.TargetType(), TargetID: target,
+			Reason: "spam", CreatedAt: time.Now()}
+		if err := repo.ApplyAction(action); err != nil {
+			t.Fatalf("%s failed: %v", a, err)
+		}
+	}
+
+	act(models.ActionDeleteComment, comment)
+	want := []string{"/img/c1.png", "/img/c2.png", "/img/r.png"}
+	if got := queuedURLs(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("after deleting the comment queued = %v, want %v", got, want)
+	}
+
+	act(models.ActionDeletePost, thread)
+	want = []string{"/img/c1.png", "/img/c2.png", "/img/o1.png", "/img/o2.png", "/img/p.png", "/img/r.png"}
+	if got := queuedURLs(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("after deleting the thread queued = %v, want %v", got, want)
+	}
+}
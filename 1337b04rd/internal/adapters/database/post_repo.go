@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -10,6 +11,8 @@ import (
 
 	"1337b04rd/internal/app/domain/models"
 	"1337b04rd/internal/app/domain/ports"
+
+	"github.com/lib/pq"
 )
 
 type PostRepositoryPg struct {
@@ -20,76 +23,208 @@ func NewPostRepositoryPg(db *sql.DB) ports.PostRepository {
 	return &PostRepositoryPg{db: db}
 }
 
+// postColumns lists the columns read by scanPost. Queries select them from
+// postTables.
+const postColumns = `p.id, p.board, p.title, p.text, p.user_name, COALESCE(p.tripcode, ''), COALESCE(p.poster_id, ''), p.user_avatar, p.image_url, COALESCE(p.thumbnail_url, ''), COALESCE(p.image_name, ''), p.created_at, p.updated_at,
+	p.bumped_at, p.archived_at, p.is_hidden, p.is_locked, p.is_pinned, stats.reply_count, stats.image_count + op.image_count`
+
+// postTables joins every post with the reply and image counts of its thread,
+// aggregated in the same query over idx_comments_post_id and the attachment
+// indexes. A message counts its attachments, or its image_url if it was made
+// before attachments were stored.
+const postTables = `posts p
+	          CROSS JOIN LATERAL (
+	              SELECT GREATEST(COUNT(*), (COALESCE(p.image_url, '') <> '')::int) AS image_count
+	              FROM attachments a
+	              WHERE a.post_id = p.id
+	          ) op
+	          CROSS JOIN LATERAL (
+	              SELECT COUNT(*) AS reply_count, COALESCE(SUM(ci.image_count), 0)::bigint AS image_count
+	              FROM comments c
+	              CROSS JOIN LATERAL (
+	                  SELECT GREATEST(COUNT(*), (COALESCE(c.image_url, '') <> '')::int) AS image_count
+	                  FROM attachments a
+	                  WHERE a.comment_id = c.id
+	              ) ci
+	              WHERE c.post_id = p.id
+	          ) stats`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPost scans postColumns, followed by any extra destinations, into a post.
+func scanPost(row rowScanner, extra ...any) (*models.Post, error) {
+	var post models.Post
+	dest := []any{
+		&post.ID, &post.Board, &post.Title, &post.Text, &post.UserName, &post.Tripcode, &post.PosterID, &post.UserAvatar, &post.ImageURL, &post.ThumbnailURL, &post.ImageName, &post.CreatedAt, &post.UpdatedAt,
+		&post.BumpedAt, &post.ArchivedAt, &post.IsHidden, &post.IsLocked, &post.IsPinned, &post.ReplyCount, &post.ImageCount,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
 // CreatePost creates a new post and returns the created post with its ID.
-func (r *PostRepositoryPg) CreatePost(post *models.Post) (*models.Post, error) {
-	query := `INSERT INTO posts (title, text, user_name, user_avatar, image_url, created_at, updated_at, is_hidden) 
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+// A post.ID reserved with NextPostID is kept, otherwise a new one is assigned.
+// With maxThreads > 0 the board row is locked for the transaction and the
+// least recently bumped threads past the cap are archived before commit, so
+// concurrent thread creation cannot push the board over its cap.
+func (r *PostRepositoryPg) CreatePost(post *models.Post, maxThreads int) (*models.Post, error) {
+	query := `INSERT INTO posts (id, board, title, text, user_name, tripcode, user_avatar, image_url, created_at, updated_at, bumped_at, is_hidden, text_hash, image_hash, poster_id, thumbnail_url, image_name) 
+	          VALUES (COALESCE(NULLIF($1, 0), nextval(pg_get_serial_sequence('posts', 'id'))),
+	                  $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11, $12, NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, ''), NULLIF($16, ''), NULLIF($17, '')) RETURNING id`
+
+	// A new thread starts at the top of the catalog
+	if post.BumpedAt.IsZero() {
+		post.BumpedAt = post.CreatedAt
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if maxThreads > 0 {
+		if _, err := tx.Exec(`SELECT 1 FROM boards WHERE slug = $1 FOR UPDATE`, post.Board); err != nil {
+			slog.Error("Error locking board", "board", post.Board, "error", err)
+			return nil, fmt.Errorf("error locking board: %v", err)
+		}
+	}
 
 	// Execute the query and get the automatically generated ID
-	err := r.db.QueryRow(query, post.Title, post.Text, post.UserName, post.UserAvatar, post.ImageURL, post.CreatedAt, post.UpdatedAt, post.IsHidden).Scan(&post.ID)
+	err = tx.QueryRow(query, post.ID, post.Board, post.Title, post.Text, post.UserName, post.Tripcode, post.UserAvatar, post.ImageURL, post.CreatedAt, post.UpdatedAt, post.BumpedAt, post.IsHidden, post.TextHash, post.ImageHash, post.PosterID, post.ThumbnailURL, post.ImageName).Scan(&post.ID)
 	if err != nil {
 		slog.Error("Error creating post", "error", err)
 		return nil, fmt.Errorf("error creating post: %v", err)
 	}
+	if err := insertAttachments(tx, "post_id", post.ID, post.Attachments); err != nil {
+		return nil, err
+	}
+
+	pruned := 0
+	if maxThreads > 0 {
+		pruned, err = pruneThreads(context.Background(), tx, post.Board, maxThreads, post.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing post", "error", err)
+		return nil, fmt.Errorf("error committing post: %v", err)
+	}
+
+	if pruned > 0 {
+		slog.Info("Threads over the board cap archived", "board", post.Board, "count", pruned)
+	}
 	slog.Info("Successfully created post", "postID", post.ID)
 	return post, nil
 }
 
-// GetAllPosts retrieves all posts that are not hidden and archived.
-func (r *PostRepositoryPg) GetAllPosts() ([]*models.Post, error) {
-	query := `SELECT id, title, text,  user_name, user_avatar, image_url, created_at, updated_at, is_hidden 
-	          FROM posts 
-	          WHERE is_hidden = FALSE AND archived_at IS NULL`
+// NextPostID takes the next value of the posts ID sequence.
+func (r *PostRepositoryPg) NextPostID() (int, error) {
+	var id int
+	if err := r.db.QueryRow(`SELECT nextval(pg_get_serial_sequence('posts', 'id'))`).Scan(&id); err != nil {
+		slog.Error("Error reserving post ID", "error", err)
+		return 0, fmt.Errorf("error reserving post ID: %v", err)
+	}
+	return id, nil
+}
+
+// GetAllPosts retrieves one page of the posts of a board that are not hidden
+// and archived. Pinned posts are kept out of the pagination and returned with
+// the first page. Active posts have no archive time, so sorting by it is
+// refused with ErrInvalidInput.
+func (r *PostRepositoryPg) GetAllPosts(board string, page models.PageRequest) (*models.PostPage, error) {
+	page = page.Normalize(models.SortByBumpTime)
+	if page.Sort == models.SortByArchivedAt {
+		return nil, fmt.Errorf("sort %q is only for the archive: %w", page.Sort, models.ErrInvalidInput)
+	}
+
+	result, err := r.listPosts("p.board = $1 AND p.is_hidden = FALSE AND p.archived_at IS NULL AND p.is_pinned = FALSE", []any{board}, page)
+	if err != nil {
+		return nil, err
+	}
+	if page.Cursor == "" {
+		result.Pinned, err = r.getPinnedPosts(board)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// getPinnedPosts retrieves all visible pinned posts of a board, most recently
+// bumped first.
+func (r *PostRepositoryPg) getPinnedPosts(board string) ([]*models.Post, error) {
+	query := `SELECT ` + postColumns + `
+	          FROM ` + postTables + `
+	          WHERE p.board = $1 AND p.is_pinned = TRUE AND p.is_hidden = FALSE AND p.archived_at IS NULL
+	          ORDER BY p.bumped_at DESC, p.id DESC`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, board)
 	if err != nil {
-		slog.Error("Error getting all posts", "error", err)
-		return nil, fmt.Errorf("error getting all posts: %v", err)
+		slog.Error("Error getting pinned posts", "error", err)
+		return nil, fmt.Errorf("error getting pinned posts: %v", err)
 	}
 	defer rows.Close()
 
 	var posts []*models.Post
 	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(&post.ID, &post.Title, &post.Text, &post.UserName, &post.UserAvatar, &post.ImageURL, &post.CreatedAt, &post.UpdatedAt, &post.IsHidden)
+		post, err := scanPost(rows)
 		if err != nil {
-			slog.Error("Error scanning row", "error", err)
-			return nil, fmt.Errorf("error scanning row: %v", err)
+			slog.Error("Error scanning pinned post", "error", err)
+			return nil, fmt.Errorf("error scanning pinned post: %v", err)
 		}
-		posts = append(posts, &post)
+		posts = append(posts, post)
 	}
-
 	if rows.Err() != nil {
-		slog.Error("Error iterating rows", "error", rows.Err())
-		return nil, fmt.Errorf("error iterating rows: %v", rows.Err())
+		return nil, fmt.Errorf("error iterating pinned posts: %v", rows.Err())
+	}
+	if err := attachToPosts(r.db, posts); err != nil {
+		return nil, err
 	}
-
-	slog.Info("Successfully retrieved posts", "postCount", len(posts))
 	return posts, nil
 }
 
-// GetPostByID retrieves a post by its ID.
+// GetPostByID retrieves a live, visible post by its ID.
+// Returns nil without an error if no such post exists.
 func (r *PostRepositoryPg) GetPostByID(id string) (*models.Post, error) {
 	// Convert ID from string to int
 	idInt, err := strconv.Atoi(id)
 	if err != nil {
 		slog.Error("Invalid ID format", "id", id, "error", err)
-		return nil, fmt.Errorf("invalid id format: %v", err)
+		return nil, fmt.Errorf("invalid id format: %w", models.ErrInvalidInput)
 	}
 
-	query := `SELECT id, title, text,  user_name, user_avatar, image_url, created_at, updated_at, is_hidden 
-	          FROM posts WHERE id = $1 AND archived_at IS NULL`
+	query := `SELECT ` + postColumns + `
+	          FROM ` + postTables + `
+	          WHERE p.id = $1 AND p.archived_at IS NULL AND p.is_hidden = FALSE`
 
-	var post models.Post
-	err = r.db.QueryRow(query, idInt).Scan(&post.ID, &post.Title, &post.Text, &post.UserName, &post.UserAvatar, &post.ImageURL, &post.CreatedAt, &post.UpdatedAt, &post.IsHidden)
+	post, err := scanPost(r.db.QueryRow(query, idInt))
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.Warn("Post not found", "id", idInt)
+		return nil, nil
+	}
 	if err != nil {
 		slog.Error("Error getting post by ID", "id", idInt, "error", err)
 		return nil, fmt.Errorf("error getting post by id: %v", err)
 	}
 
+	if post.Backlinks, err = r.getPostBacklinks(post.ID); err != nil {
+		return nil, err
+	}
+	if err := attachToPosts(r.db, []*models.Post{post}); err != nil {
+		return nil, err
+	}
+
 	slog.Info("Successfully retrieved post by ID", "postID", post.ID)
-	return &post, nil
+	return post, nil
 }
 
 // ArchivePost archives a post by setting its archived_at field.
@@ -105,52 +240,428 @@ func (r *PostRepositoryPg) ArchivePost(postID int) error {
 	return nil
 }
 
-// GetArchivedPosts retrieves all archived posts.
-func (r *PostRepositoryPg) GetArchivedPosts() ([]*models.Post, error) {
-	query := `SELECT id, title, text, user_name, user_avatar, image_url, created_at, updated_at, archived_at
-	          FROM posts WHERE archived_at IS NOT NULL ORDER BY archived_at DESC`
+// ArchiveExpiredPosts archives, in one statement, every live post of a board
+// that has no comments and is older than policy.NoReplyTTL, or whose latest
+// comment is older than policy.ReplyTTL. Pinned posts never expire.
+func (r *PostRepositoryPg) ArchiveExpiredPosts(ctx context.Context, board string, now time.Time, policy models.ArchivePolicy) (int, error) {
+	query := `WITH last_activity AS (
+	              SELECT p.id, MAX(c.created_at) AS last_comment_at
+	              FROM posts p
+	              LEFT JOIN comments c ON c.post_id = p.id
+	              WHERE p.board = $4 AND p.archived_at IS NULL AND p.is_pinned = FALSE
+	              GROUP BY p.id
+	          )
+	          UPDATE posts p SET archived_at = $1
+	          FROM last_activity la
+	          WHERE p.id = la.id
+	            AND ((la.last_comment_at IS NULL AND p.created_at < $2)
+	              OR la.last_comment_at < $3)`
+
+	res, err := r.db.ExecContext(ctx, query, now, now.Add(-policy.NoReplyTTL), now.Add(-policy.ReplyTTL), board)
+	if err != nil {
+		slog.Error("Error archiving expired posts", "board", board, "error", err)
+		return 0, fmt.Errorf("error archiving expired posts: %v", err)
+	}
+
+	archived, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error counting archived posts: %v", err)
+	}
+	return int(archived), nil
+}
+
+// PruneThreads archives the least recently bumped live threads of a board
+// beyond the first maxThreads. Pinned and hidden threads are neither counted
+// nor pruned.
+func (r *PostRepositoryPg) PruneThreads(ctx context.Context, board string, maxThreads int, now time.Time) (int, error) {
+	return pruneThreads(ctx, r.db, board, maxThreads, now)
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func pruneThreads(ctx context.Context, db execer, board string, maxThreads int, now time.Time) (int, error) {
+	query := `UPDATE posts SET archived_at = $3
+	          WHERE id IN (
+	              SELECT id FROM posts
+	              WHERE board = $1 AND archived_at IS NULL AND is_pinned = FALSE AND is_hidden = FALSE
+	              ORDER BY bumped_at DESC, id DESC
+	              OFFSET $2
+	          )`
+
+	res, err := db.ExecContext(ctx, query, board, maxThreads, now)
+	if err != nil {
+		slog.Error("Error pruning threads", "board", board, "error", err)
+		return 0, fmt.Errorf("error pruning threads: %v", err)
+	}
+
+	pruned, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error counting pruned threads: %v", err)
+	}
+	return int(pruned), nil
+}
+
+// GetArchivedPosts retrieves one page of the visible archived posts of a board.
+func (r *PostRepositoryPg) GetArchivedPosts(board string, page models.PageRequest) (*models.PostPage, error) {
+	page = page.Normalize(models.SortByArchivedAt)
+	return r.listPosts("p.board = $1 AND p.is_hidden = FALSE AND p.archived_at IS NOT NULL", []any{board}, page)
+}
+
+// PurgeArchivedPosts deletes the threads archived before archivedBefore
+// together with their comments and reports. The images and thumbnails they
+// used are returned unless a post or comment outside the purge still uses
+// them. With dryRun the same threads and images are only looked up, in a
+// read-only transaction, and nothing is locked or deleted.
+func (r *PostRepositoryPg) PurgeArchivedPosts(ctx context.Context, archivedBefore time.Time, dryRun bool) (*models.PurgeSummary, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: dryRun})
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id FROM posts WHERE archived_at < $1 ORDER BY id`
+	if !dryRun {
+		query += ` FOR UPDATE`
+	}
+
+	summary := &models.PurgeSummary{}
+	rows, err := tx.QueryContext(ctx, query, archivedBefore)
+	if err != nil {
+		slog.Error("Error selecting threads to purge", "error", err)
+		return nil, fmt.Errorf("error selecting threads to purge: %v", err)
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning thread to purge: %v", err)
+		}
+		summary.ThreadIDs = append(summary.ThreadIDs, id)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating threads to purge: %v", rows.Err())
+	}
+	if len(summary.ThreadIDs) == 0 {
+		return summary, nil
+	}
+	ids := intArray(summary.ThreadIDs)
+
+	var images pq.StringArray
+	err = tx.QueryRowContext(ctx, `SELECT (SELECT COUNT(*) FROM comments WHERE post_id = ANY($1)),
+	                                      COALESCE((SELECT array_agg(url) FROM (
+	                                          SELECT unnest(ARRAY[image_url, thumbnail_url]) AS url FROM posts WHERE id = ANY($1)
+	                                          UNION
+	                                          SELECT unnest(ARRAY[image_url, thumbnail_url]) FROM comments WHERE post_id = ANY($1)
+	                                          UNION
+	                                          SELECT unnest(ARRAY[a.url, a.thumbnail_url]) FROM attachments a
+	                                          LEFT JOIN comments c ON c.id = a.comment_id
+	                                          WHERE a.post_id = ANY($1) OR c.post_id = ANY($1)
+	                                      ) t WHERE url <> ''), '{}')`, ids).
+		Scan(&summary.Comments, &images)
+	if err != nil {
+		slog.Error("Error collecting purged content", "error", err)
+		return nil, fmt.Errorf("error collecting purged content: %v", err)
+	}
+
+	// Several posts can point at the same object, keep those still used by
+	// threads outside the purge
+	rows, err = tx.QueryContext(ctx, `SELECT u FROM unnest($1::text[]) AS u
+	                                  WHERE NOT EXISTS (SELECT 1 FROM posts
+	                                                    WHERE (image_url = u OR thumbnail_url = u) AND id <> ALL($2))
+	                                    AND NOT EXISTS (SELECT 1 FROM comments
+	                                                    WHERE (image_url = u OR thumbnail_url = u) AND post_id <> ALL($2))
+	                                    AND NOT EXISTS (SELECT 1 FROM attachments a LEFT JOIN comments c ON c.id = a.comment_id
+	                                                    WHERE (a.url = u OR a.thumbnail_url = u) AND COALESCE(a.post_id, c.post_id) <> ALL($2))
+	                                  ORDER BY u`, images, ids)
+	if err != nil {
+		slog.Error("Error filtering purged images", "error", err)
+		return nil, fmt.Errorf("error filtering purged images: %v", err)
+	}
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning purged image: %v", err)
+		}
+		summary.Images = append(summary.Images, url)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating purged images: %v", rows.Err())
+	}
+
+	if dryRun {
+		return summary, nil
+	}
+
+	// Comments, attachments, references and reports go with the posts (ON DELETE CASCADE)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE id = ANY($1)`, ids); err != nil {
+		slog.Error("Error deleting purged threads", "error", err)
+		return nil, fmt.Errorf("error deleting purged threads: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing purge", "error", err)
+		return nil, fmt.Errorf("error committing purge: %v", err)
+	}
+	return summary, nil
+}
+
+// legacyImagePattern matches content-addressed image URLs, whose key is a
+// SHA-256 plus extension. The URLs stored before such keys are the ones that
+// do not match it.
+const legacyImagePattern = `/[0-9a-f]{64}\.(jpg|png|gif)$`
+
+// LegacyImageURLs returns the distinct image URLs of posts, comments and
+// attachments that still use client file names as keys.
+func (r *PostRepositoryPg) LegacyImageURLs(ctx context.Context) ([]string, error) {
+	query := `SELECT image_url FROM posts WHERE image_url <> '' AND image_url !~ $1
+	          UNION
+	          SELECT image_url FROM comments WHERE image_url <> '' AND image_url !~ $1
+	          UNION
+	          SELECT url FROM attachments WHERE url !~ $1
+	          ORDER BY 1`
+
+	rows, err := r.db.QueryContext(ctx, query, legacyImagePattern)
+	if err != nil {
+		slog.Error("Error getting legacy image URLs", "error", err)
+		return nil, fmt.Errorf("error getting legacy image URLs: %v", err)
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, fmt.Errorf("error scanning legacy image URL: %v", err)
+		}
+		urls = append(urls, url)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating legacy image URLs: %v", rows.Err())
+	}
+	return urls, nil
+}
+
+// ReplaceImageURL points every post, comment and attachment with image
+// oldURL at image, filling in the file name where none is stored, and
+// returns the thumbnail URLs those rows had before.
+func (r *PostRepositoryPg) ReplaceImageURL(ctx context.Context, oldURL string, image *models.UploadedImage) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var thumbnails pq.StringArray
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(array_agg(DISTINCT thumbnail_url), '{}') FROM (
+	                                   SELECT thumbnail_url FROM posts WHERE image_url = $1
+	                                   UNION ALL
+	                                   SELECT thumbnail_url FROM comments WHERE image_url = $1
+	                                   UNION ALL
+	                                   SELECT thumbnail_url FROM attachments WHERE url = $1
+	                               ) t WHERE thumbnail_url <> ''`, oldURL).Scan(&thumbnails)
+	if err != nil {
+		slog.Error("Error getting old thumbnails", "url", oldURL, "error", err)
+		return nil, fmt.Errorf("error getting old thumbnails: %v", err)
+	}
+
+	for _, table := range []string{"posts", "comments"} {
+		query := `UPDATE ` + table + `
+		          SET image_url = $2, thumbnail_url = NULLIF($3, ''), image_name = COALESCE(image_name, NULLIF($4, ''))
+		          WHERE image_url = $1`
+		if _, err := tx.ExecContext(ctx, query, oldURL, image.URL, image.ThumbnailURL, image.Name); err != nil {
+			slog.Error("Error replacing image URL", "table", table, "url", oldURL, "error", err)
+			return nil, fmt.Errorf("error replacing image URL: %v", err)
+		}
+	}
+	query := `UPDATE attachments
+	          SET url = $2, thumbnail_url = NULLIF($3, ''), name = COALESCE(name, NULLIF($4, '')),
+	              content_type = $5, size = $6, width = $7, height = $8
+	          WHERE url = $1`
+	if _, err := tx.ExecContext(ctx, query, oldURL, image.URL, image.ThumbnailURL, image.Name,
+		image.Format.ContentType(), image.Size, image.Width, image.Height); err != nil {
+		slog.Error("Error replacing attachment URL", "url", oldURL, "error", err)
+		return nil, fmt.Errorf("error replacing attachment URL: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing image URL replacement", "error", err)
+		return nil, fmt.Errorf("error committing image URL replacement: %v", err)
+	}
+	return thumbnails, nil
+}
+
+// sortKeys maps each sort to a bigint SQL expression so that cursors can
+// carry every kind of key the same way. Timestamps become Unix microseconds.
+var sortKeys = map[models.PostSort]string{
+	models.SortByBumpTime:     "(EXTRACT(EPOCH FROM p.bumped_at) * 1000000)::bigint",
+	models.SortByCreatedAt:    "(EXTRACT(EPOCH FROM p.created_at) * 1000000)::bigint",
+	models.SortByArchivedAt:   "(EXTRACT(EPOCH FROM p.archived_at) * 1000000)::bigint",
+	models.SortByCommentCount: "stats.reply_count",
+}
+
+// listPosts runs a keyset-paginated query over posts matching filter, whose
+// placeholders are bound to filterArgs. Pages are ordered by the sort key and
+// then by ID, both descending.
+func (r *PostRepositoryPg) listPosts(filter string, filterArgs []any, page models.PageRequest) (*models.PostPage, error) {
+	sortKey, ok := sortKeys[page.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q: %w", page.Sort, models.ErrInvalidInput)
+	}
 
-	rows, err := r.db.QueryContext(context.Background(), query)
+	cursor, err := page.DecodeCursor()
 	if err != nil {
-		slog.Error("Error getting archived posts", "error", err)
 		return nil, err
 	}
+	backward := cursor != nil && cursor.Backward
+
+	// Walking backwards flips both the comparison and the order; the rows
+	// are reversed again after scanning.
+	where := filter
+	order := "DESC"
+	args := append([]any{}, filterArgs...)
+	if cursor != nil {
+		op := "<"
+		if backward {
+			op = ">"
+			order = "ASC"
+		}
+		where += fmt.Sprintf(" AND (%s, p.id) %s ($%d, $%d)", sortKey, op, len(args)+1, len(args)+2)
+		args = append(args, cursor.Key, cursor.ID)
+	}
+	args = append(args, page.Limit+1)
+
+	query := fmt.Sprintf(`SELECT %s, %s AS sort_key
+	          FROM %s
+	          WHERE %s
+	          ORDER BY sort_key %s, p.id %s
+	          LIMIT $%d`, postColumns, sortKey, postTables, where, order, order, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		slog.Error("Error listing posts", "error", err)
+		return nil, fmt.Errorf("error listing posts: %v", err)
+	}
 	defer rows.Close()
 
 	var posts []*models.Post
+	var keys []int64
 	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(&post.ID, &post.Title, &post.Text, &post.UserName, &post.UserAvatar, &post.ImageURL, &post.CreatedAt, &post.UpdatedAt, &post.ArchivedAt)
+		var key int64
+		post, err := scanPost(rows, &key)
 		if err != nil {
-			slog.Error("Error scanning archived post", "error", err)
-			return nil, err
+			slog.Error("Error scanning row", "error", err)
+			return nil, fmt.Errorf("error scanning row: %v", err)
 		}
-		posts = append(posts, &post)
+		posts = append(posts, post)
+		keys = append(keys, key)
 	}
 
-	slog.Info("Successfully retrieved archived posts", "postCount", len(posts))
-	return posts, nil
+	if rows.Err() != nil {
+		slog.Error("Error iterating rows", "error", rows.Err())
+		return nil, fmt.Errorf("error iterating rows: %v", rows.Err())
+	}
+
+	hasMore := len(posts) > page.Limit
+	if hasMore {
+		posts = posts[:page.Limit]
+		keys = keys[:page.Limit]
+	}
+	if err := attachToPosts(r.db, posts); err != nil {
+		return nil, err
+	}
+	if backward {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	result := &models.PostPage{Posts: posts, Sort: page.Sort}
+	if len(posts) > 0 {
+		first, last := 0, len(posts)-1
+		// A forward page has a successor only if the query overflowed; a
+		// backward page always has one, since it was reached from it.
+		if hasMore || backward {
+			result.NextCursor = models.Cursor{Sort: page.Sort, Key: keys[last], ID: posts[last].ID}.Encode()
+		}
+		if (backward && hasMore) || (!backward && cursor != nil) {
+			result.PrevCursor = models.Cursor{Sort: page.Sort, Key: keys[first], ID: posts[first].ID, Backward: true}.Encode()
+		}
+	}
+
+	slog.Info("Successfully retrieved posts", "postCount", len(posts), "sort", page.Sort)
+	return result, nil
 }
 
 // GetArchivedPostByID retrieves an archived post by its ID.
+// Returns nil without an error if no archived post has that ID.
 func (r *PostRepositoryPg) GetArchivedPostByID(id string) (*models.Post, error) {
 	// Convert ID from string to int
 	idInt, err := strconv.Atoi(id)
 	if err != nil {
 		slog.Error("Invalid ID format", "id", id, "error", err)
-		return nil, fmt.Errorf("invalid id format: %v", err)
+		return nil, fmt.Errorf("invalid id format: %w", models.ErrInvalidInput)
 	}
 
-	query := `SELECT id, title, text, user_name, user_avatar, image_url, created_at, updated_at, is_hidden
-	          FROM posts WHERE id = $1 AND archived_at IS NOT NULL`
+	query := `SELECT ` + postColumns + `
+	          FROM ` + postTables + `
+	          WHERE p.id = $1 AND p.archived_at IS NOT NULL AND p.is_hidden = FALSE`
 
-	var post models.Post
-	err = r.db.QueryRow(query, idInt).Scan(&post.ID, &post.Title, &post.Text, &post.UserName, &post.UserAvatar, &post.ImageURL, &post.CreatedAt, &post.UpdatedAt, &post.IsHidden)
+	post, err := scanPost(r.db.QueryRow(query, idInt))
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.Warn("Archived post not found", "id", idInt)
+		return nil, nil
+	}
 	if err != nil {
 		slog.Error("Error getting archived post by ID", "id", idInt, "error", err)
 		return nil, fmt.Errorf("error getting archived post by id: %v", err)
 	}
 
+	if post.Backlinks, err = r.getPostBacklinks(post.ID); err != nil {
+		return nil, err
+	}
+	if err := attachToPosts(r.db, []*models.Post{post}); err != nil {
+		return nil, err
+	}
+
 	slog.Info("Successfully retrieved archived post by ID", "postID", post.ID)
-	return &post, nil
+	return post, nil
+}
+
+// getPostBacklinks returns the visible comments that quote a post.
+func (r *PostRepositoryPg) getPostBacklinks(postID int) ([]models.Backlink, error) {
+	query := `SELECT ref.comment_id, src.post_id, p.archived_at IS NOT NULL
+	          FROM comment_references ref
+	          JOIN comments src ON src.id = ref.comment_id
+	          JOIN posts p ON p.id = src.post_id
+	          WHERE ref.target_type = 'post' AND ref.target_id = $1 AND p.is_hidden = FALSE
+	          ORDER BY ref.comment_id ASC`
+
+	rows, err := r.db.Query(query, postID)
+	if err != nil {
+		slog.Error("Error getting post backlinks", "postID", postID, "error", err)
+		return nil, fmt.Errorf("error getting post backlinks: %v", err)
+	}
+	defer rows.Close()
+
+	var links []models.Backlink
+	for rows.Next() {
+		var link models.Backlink
+		if err := rows.Scan(&link.ID, &link.PostID, &link.Archived); err != nil {
+			slog.Error("Error scanning post backlink", "error", err)
+			return nil, fmt.Errorf("error scanning post backlink: %v", err)
+		}
+		links = append(links, link)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating post backlinks: %v", rows.Err())
+	}
+	return links, nil
 }
@@ -0,0 +1,206 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/adapters/database"
+	"1337b04rd/internal/app/domain/models"
+)
+
+// postTitles lists the titles of a page in order.
+func postTitles(page *models.PostPage) []string {
+	var titles []string
+	for _, p := range page.Posts {
+		titles = append(titles, p.Title)
+	}
+	return titles
+}
+
+func TestGetArchivedPosts_SkipsHidden(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewPostRepositoryPg(db)
+
+	archivedAt := time.Now().Add(-time.Hour)
+	insertPost(t, db, testPost{title: "archived", archived: &archivedAt})
+	insertPost(t, db, testPost{title: "archived hidden", archived: &archivedAt, hidden: true})
+	insertPost(t, db, testPost{title: "live"})
+	insertPost(t, db, testPost{title: "archived elsewhere", board: "g", archived: &archivedAt})
+
+	page, err := repo.GetArchivedPosts("b", models.PageRequest{})
+	if err != nil {
+		t.Fatalf("GetArchivedPosts failed: %v", err)
+	}
+	if titles := postTitles(page); len(titles) != 1 || titles[0] != "archived" {
+		t.Errorf("archive lists %q, want only \"archived\"", titles)
+	}
+}
+
+func TestGetAllPosts_RefusesArchivedSort(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewPostRepositoryPg(db)
+
+	insertPost(t, db, testPost{title: "live"})
+	if _, err := repo.GetAllPosts("b", models.PageRequest{Sort: models.SortByArchivedAt}); !errors.Is(err, models.ErrInvalidInput) {
+		t.Errorf("GetAllPosts sorted by archive time: error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestCreatePost_ReservedID(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewPostRepositoryPg(db)
+
+	id, err := repo.NextPostID()
+	if err != nil {
+		t.Fatalf("NextPostID failed: %v", err)
+	}
+	post := &models.Post{ID: id, Board: "b", Title: "t", Text: "x", UserName: "Rick", PosterID: "AbCd1234", CreatedAt: time.Now()}
+	if _, err := repo.CreatePost(post, 0); err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+	if post.ID != id {
+		t.Errorf("thread stored as %d, want the reserved ID %d", post.ID, id)
+	}
+
+	// Threads without a reserved ID draw from the same sequence
+	other := &models.Post{Board: "b", Title: "t", Text: "x", UserName: "Rick", CreatedAt: time.Now()}
+	if _, err := repo.CreatePost(other, 0); err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+	if other.ID <= id {
+		t.Errorf("next thread got ID %d, want one after %d", other.ID, id)
+	}
+
+	stored, err := repo.GetPostByID(strconv.Itoa(id))
+	if err != nil || stored == nil {
+		t.Fatalf("GetPostByID failed: %v", err)
+	}
+	if stored.PosterID != "AbCd1234" {
+		t.Errorf("stored poster ID %q, want AbCd1234", stored.PosterID)
+	}
+}
+
+func TestPruneThreads_SkipsPinnedAndHidden(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewPostRepositoryPg(db)
+
+	now := time.Now()
+	insertPost(t, db, testPost{title: "newest", bumped: now})
+	insertPost(t, db, testPost{title: "hidden", bumped: now.Add(-time.Minute), hidden: true})
+	insertPost(t, db, testPost{title: "pinned", bumped: now.Add(-2 * time.Minute), pinned: true})
+	insertPost(t, db, testPost{title: "second", bumped: now.Add(-3 * time.Minute)})
+	insertPost(t, db, testPost{title: "oldest", bumped: now.Add(-4 * time.Minute)})
+	insertPost(t, db, testPost{title: "elsewhere", board: "g", bumped: now.Add(-5 * time.Minute)})
+
+	pruned, err := repo.PruneThreads(context.Background(), "b", 2, now)
+	if err != nil {
+		t.Fatalf("PruneThreads failed: %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("pruned %d threads, want 1", pruned)
+	}
+
+	if got := archivedTitles(t, db); len(got) != 1 || got[0] != "oldest" {
+		t.Errorf("archived %q, want only \"oldest\"", got)
+	}
+
+	// A new thread pushes out the next visible one, not the hidden thread
+	post := &models.Post{Board: "b", Title: "new", Text: "x", UserName: "Rick", CreatedAt: now.Add(time.Second)}
+	if _, err := repo.CreatePost(post, 2); err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+	if got := archivedTitles(t, db); len(got) != 2 || got[1] != "second" {
+		t.Errorf("archived %q, want \"oldest\" and \"second\"", got)
+	}
+}
+
+// archivedTitles lists the titles of all archived threads by ID.
+func archivedTitles(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT title FROM posts WHERE archived_at IS NOT NULL ORDER BY id`)
+	if err != nil {
+		t.Fatalf("list archived threads: %v", err)
+	}
+	defer rows.Close()
+	var titles []string
+	for rows.Next() {
+		var title string
+		rows.Scan(&title)
+		titles = append(titles, title)
+	}
+	return titles
+}
+
+func TestPurgeArchivedPosts_DryRun(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewPostRepositoryPg(db)
+
+	old := time.Now().Add(-48 * time.Hour)
+	purged := insertPost(t, db, testPost{title: "old", archived: &old})
+	shared := insertPost(t, db, testPost{title: "old with a shared image", archived: &old})
+	live := insertPost(t, db, testPost{title: "live"})
+	for id, url := range map[int]string{purged: "/img/a.png", shared: "/img/shared.png", live: "/img/shared.png"} {
+		if _, err := db.Exec(`UPDATE posts SET image_url = $2 WHERE id = $1`, id, url); err != nil {
+			t.Fatalf("set image: %v", err)
+		}
+	}
+	insertComment(t, db, purged, "/img/c.png")
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	dry, err := repo.PurgeArchivedPosts(context.Background(), cutoff, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	var threads int
+	db.QueryRow(`SELECT COUNT(*) FROM posts`).Scan(&threads)
+	if threads != 3 {
+		t.Errorf("dry run left %d threads, want 3", threads)
+	}
+
+	done, err := repo.PurgeArchivedPosts(context.Background(), cutoff, false)
+	if err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if !reflect.DeepEqual(dry, done) {
+		t.Errorf("dry run summary %+v differs from the purge %+v", dry, done)
+	}
+	if want := []string{"/img/a.png", "/img/c.png"}; !reflect.DeepEqual(done.Images, want) || done.Comments != 1 || len(done.ThreadIDs) != 2 {
+		t.Errorf("summary = %+v, want 2 threads, 1 comment and images %q", done, want)
+	}
+	db.QueryRow(`SELECT COUNT(*) FROM posts`).Scan(&threads)
+	if threads != 1 {
+		t.Errorf("purge left %d threads, want 1", threads)
+	}
+}
+
+func TestImageCount(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewPostRepositoryPg(db)
+
+	// The opening post has two attachments; the replies have three
+	// attachments, one legacy image and none
+	id := insertPost(t, db, testPost{title: "images"})
+	db.Exec(`UPDATE posts SET image_url = '/img/a.png' WHERE id = $1`, id)
+	insertAttachment(t, db, "post_id", id, 0, "/img/a.png", strings.Repeat("a", 64))
+	insertAttachment(t, db, "post_id", id, 1, "/img/b.png", strings.Repeat("b", 64))
+	withAttachments := insertComment(t, db, id, "/img/c.png")
+	for i, name := range []string{"c", "d", "e"} {
+		insertAttachment(t, db, "comment_id", withAttachments, i, "/img/"+name+".png", strings.Repeat(name, 64))
+	}
+	insertComment(t, db, id, "/img/legacy.png")
+	insertComment(t, db, id, "")
+
+	post, err := repo.GetPostByID(strconv.Itoa(id))
+	if err != nil || post == nil {
+		t.Fatalf("GetPostByID failed: %v", err)
+	}
+	if post.ReplyCount != 3 || post.ImageCount != 6 {
+		t.Errorf("counts R %d / I %d, want R 3 / I 6", post.ReplyCount, post.ImageCount)
+	}
+}
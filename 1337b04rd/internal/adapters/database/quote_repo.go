@@ -0,0 +1,63 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+
+	"github.com/lib/pq"
+)
+
+// QuoteRepositoryPg resolves quote targets in PostgreSQL.
+type QuoteRepositoryPg struct {
+	db *sql.DB
+}
+
+// NewQuoteRepositoryPg creates a new instance of the quote repository.
+func NewQuoteRepositoryPg(db *sql.DB) ports.QuoteRepository {
+	return &QuoteRepositoryPg{db: db}
+}
+
+// ResolveQuotes looks up visible posts and comments by ID in one query.
+func (r *QuoteRepositoryPg) ResolveQuotes(ids []int) ([]models.QuoteTarget, error) {
+	query := `SELECT p.id, p.id, TRUE, p.archived_at IS NOT NULL
+	          FROM posts p
+	          WHERE p.id = ANY($1) AND p.is_hidden = FALSE
+	          UNION ALL
+	          SELECT c.id, c.post_id, FALSE, p.archived_at IS NOT NULL
+	          FROM comments c JOIN posts p ON p.id = c.post_id
+	          WHERE c.id = ANY($1) AND p.is_hidden = FALSE`
+
+	rows, err := r.db.Query(query, intArray(ids))
+	if err != nil {
+		slog.Error("Error resolving quotes", "error", err)
+		return nil, fmt.Errorf("error resolving quotes: %v", err)
+	}
+	defer rows.Close()
+
+	var targets []models.QuoteTarget
+	for rows.Next() {
+		var t models.QuoteTarget
+		if err := rows.Scan(&t.ID, &t.PostID, &t.IsPost, &t.Archived); err != nil {
+			slog.Error("Error scanning quote target", "error", err)
+			return nil, fmt.Errorf("error scanning quote target: %v", err)
+		}
+		targets = append(targets, t)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating quote targets: %v", rows.Err())
+	}
+	return targets, nil
+}
+
+// intArray wraps ids as a PostgreSQL bigint[] parameter.
+func intArray(ids []int) pq.Int64Array {
+	ids64 := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		ids64[i] = int64(id)
+	}
+	return ids64
+}
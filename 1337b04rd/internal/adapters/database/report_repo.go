@@ -0,0 +1,182 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// ReportRepositoryPg stores user reports in PostgreSQL.
+type ReportRepositoryPg struct {
+	db *sql.DB
+}
+
+// NewReportRepositoryPg creates a new instance of the report repository.
+func NewReportRepositoryPg(db *sql.DB) ports.ReportRepository {
+	return &ReportRepositoryPg{db: db}
+}
+
+// CreateReport stores a report. The thread of the target is looked up so the
+// moderator queue can link to it. A session can report a target only once.
+// The rate limit is checked in the same transaction, under a lock on the
+// session, so that parallel reports are counted one after the other.
+func (r *ReportRepositoryPg) CreateReport(report *models.Report, rateLimit int, since time.Time) error {
+	var query string
+	switch report.TargetType {
+	case "post":
+		query = `SELECT id FROM posts WHERE id = $1`
+	case "comment":
+		query = `SELECT post_id FROM comments WHERE id = $1`
+	default:
+		return fmt.Errorf("unknown report target %q: %w", report.TargetType, models.ErrInvalidInput)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if rateLimit > 0 {
+		// Held until the transaction ends; the next report of the session
+		// waits here and then counts this one
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, lockReportSession, report.SessionID); err != nil {
+			slog.Error("Error locking session reports", "error", err)
+			return fmt.Errorf("error locking session reports: %v", err)
+		}
+		var count int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM reports WHERE session_id = $1 AND created_at >= $2`, report.SessionID, since).Scan(&count)
+		if err != nil {
+			slog.Error("Error counting reports", "error", err)
+			return fmt.Errorf("error counting reports: %v", err)
+		}
+		if count >= rateLimit {
+			return fmt.Errorf("too many reports, try again later: %w", models.ErrRateLimited)
+		}
+	}
+
+	err = tx.QueryRow(query, report.TargetID).Scan(&report.PostID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s %d does not exist: %w", report.TargetType, report.TargetID, models.ErrNotFound)
+	}
+	if err != nil {
+		slog.Error("Error looking up report target", "targetType", report.TargetType, "targetID", report.TargetID, "error", err)
+		return fmt.Errorf("error looking up report target: %v", err)
+	}
+
+	query = `INSERT INTO reports (target_type, target_id, post_id, session_id, category, text, status, created_at)
+	         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	         ON CONFLICT (session_id, target_type, target_id) DO NOTHING
+	         RETURNING id`
+	err = tx.QueryRow(query, report.TargetType, report.TargetID, report.PostID, report.SessionID,
+		report.Category, report.Text, report.Status, report.CreatedAt).Scan(&report.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s %d already reported: %w", report.TargetType, report.TargetID, models.ErrConflict)
+	}
+	if err != nil {
+		slog.Error("Error creating report", "error", err)
+		return fmt.Errorf("error creating report: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing report", "error", err)
+		return fmt.Errorf("error committing report: %v", err)
+	}
+
+	slog.Info("Report created", "reportID", report.ID, "targetType", report.TargetType, "targetID", report.TargetID)
+	return nil
+}
+
+// GetOpenReportGroups returns open reports grouped by target, most reported
+// targets first. Only the reports of the first limit targets are read.
+func (r *ReportRepositoryPg) GetOpenReportGroups(limit int) ([]*models.ReportGroup, error) {
+	var maxGroups any // NULL means no limit
+	if limit > 0 {
+		maxGroups = limit
+	}
+	query := `WITH targets AS (
+	              SELECT target_type, target_id, COUNT(*) AS reports, MAX(created_at) AS latest
+	              FROM reports
+	              WHERE status = $1
+	              GROUP BY target_type, target_id
+	              ORDER BY reports DESC, latest DESC, target_type, target_id
+	              LIMIT $2
+	          )
+	          SELECT r.id, r.target_type, r.target_id, r.post_id, r.category, COALESCE(r.text, ''), r.status, r.created_at
+	          FROM reports r
+	          JOIN targets t ON t.target_type = r.target_type AND t.target_id = r.target_id
+	          WHERE r.status = $1
+	          ORDER BY t.reports DESC, t.latest DESC, t.target_type, t.target_id, r.created_at ASC, r.id ASC`
+
+	rows, err := r.db.Query(query, models.ReportOpen, maxGroups)
+	if err != nil {
+		slog.Error("Error getting open reports", "error", err)
+		return nil, fmt.Errorf("error getting open reports: %v", err)
+	}
+	defer rows.Close()
+
+	// Rows arrive grouped by target, in queue order
+	var groups []*models.ReportGroup
+	for rows.Next() {
+		var rep models.Report
+		err := rows.Scan(&rep.ID, &rep.TargetType, &rep.TargetID, &rep.PostID, &rep.Category, &rep.Text, &rep.Status, &rep.CreatedAt)
+		if err != nil {
+			slog.Error("Error scanning report", "error", err)
+			return nil, fmt.Errorf("error scanning report: %v", err)
+		}
+
+		var group *models.ReportGroup
+		if n := len(groups); n > 0 && groups[n-1].TargetType == rep.TargetType && groups[n-1].TargetID == rep.TargetID {
+			group = groups[n-1]
+		} else {
+			group = &models.ReportGroup{
+				TargetType:    rep.TargetType,
+				TargetID:      rep.TargetID,
+				PostID:        rep.PostID,
+				FirstReportAt: rep.CreatedAt,
+			}
+			groups = append(groups, group)
+		}
+		group.Count++
+		group.LatestReportAt = rep.CreatedAt
+		group.Reports = append(group.Reports, &rep)
+		if !containsCategory(group.Categories, rep.Category) {
+			group.Categories = append(group.Categories, rep.Category)
+		}
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating reports: %v", rows.Err())
+	}
+	return groups, nil
+}
+
+// ResolveReports closes all open reports against a target.
+func (r *ReportRepositoryPg) ResolveReports(targetType string, targetID int, status models.ReportStatus, moderatorID int, at time.Time) (int, error) {
+	query := `UPDATE reports SET status = $1, resolved_by = $2, resolved_at = $3
+	          WHERE target_type = $4 AND target_id = $5 AND status = $6`
+
+	res, err := r.db.Exec(query, status, moderatorID, at, targetType, targetID, models.ReportOpen)
+	if err != nil {
+		slog.Error("Error resolving reports", "targetType", targetType, "targetID", targetID, "error", err)
+		return 0, fmt.Errorf("error resolving reports: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error counting resolved reports: %v", err)
+	}
+	return int(n), nil
+}
+
+func containsCategory(categories []models.ReportCategory, c models.ReportCategory) bool {
+	for _, existing := range categories {
+		if existing == c {
+			return true
+		}
+	}
+	return false
+}
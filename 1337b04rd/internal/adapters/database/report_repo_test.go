@@ -0,0 +1,117 @@
+package database_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/adapters/database"
+	"1337b04rd/internal/app/domain/models"
+)
+
+func newReport(sessionID string, postID int) *models.Report {
+	return &models.Report{
+		TargetType: "post",
+		TargetID:   postID,
+		SessionID:  sessionID,
+		Category:   models.ReportSpam,
+		Status:     models.ReportOpen,
+		CreatedAt:  time.Now(),
+	}
+}
+
+func TestCreateReport_RateLimitUnderLoad(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewReportRepositoryPg(db)
+
+	const limit, attempts = 3, 10
+	var postIDs []int
+	for i := 0; i < attempts; i++ {
+		postIDs = append(postIDs, insertPost(t, db, testPost{title: "spam"}))
+	}
+
+	// Every request reports a different thread, so only the limit stops them
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	since := time.Now().Add(-time.Minute)
+	for _, id := range postIDs {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			errs <- repo.CreateReport(newReport("s1", id), limit, since)
+		}(id)
+	}
+	wg.Wait()
+	close(errs)
+
+	created, limited := 0, 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, models.ErrRateLimited):
+			limited++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if created != limit || limited != attempts-limit {
+		t.Errorf("%d reports created and %d rate limited, want %d and %d", created, limited, limit, attempts-limit)
+	}
+
+	// Other sessions have their own limit
+	if err := repo.CreateReport(newReport("s2", postIDs[0]), limit, since); err != nil {
+		t.Errorf("report of another session failed: %v", err)
+	}
+}
+
+func TestCreateReport_Errors(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewReportRepositoryPg(db)
+	postID := insertPost(t, db, testPost{title: "thread"})
+
+	if err := repo.CreateReport(newReport("s1", postID), 0, time.Time{}); err != nil {
+		t.Fatalf("CreateReport failed: %v", err)
+	}
+	if err := repo.CreateReport(newReport("s1", postID), 0, time.Time{}); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("second report of the same target: error = %v, want ErrConflict", err)
+	}
+	if err := repo.CreateReport(newReport("s1", postID+100), 0, time.Time{}); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("report of a missing thread: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetOpenReportGroups(t *testing.T) {
+	db := testDB(t)
+	repo := database.NewReportRepositoryPg(db)
+
+	// Thread a gets three reports, b two and c one
+	a := insertPost(t, db, testPost{title: "a"})
+	b := insertPost(t, db, testPost{title: "b"})
+	c := insertPost(t, db, testPost{title: "c"})
+	for i, id := range []int{a, b, a, c, b, a} {
+		if err := repo.CreateReport(newReport(string(rune('p'+i)), id), 0, time.Time{}); err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+	}
+
+	groups, err := repo.GetOpenReportGroups(2)
+	if err != nil {
+		t.Fatalf("GetOpenReportGroups failed: %v", err)
+	}
+	if len(groups) != 2 || groups[0].TargetID != a || groups[1].TargetID != b {
+		t.Fatalf("got %d groups, want threads a and b", len(groups))
+	}
+	if groups[0].Count != 3 || len(groups[0].Reports) != 3 || groups[1].Count != 2 {
+		t.Errorf("group counts %d and %d, want 3 and 2", groups[0].Count, groups[1].Count)
+	}
+
+	// Reports of deleted threads go with them
+	if _, err := db.Exec(`DELETE FROM posts WHERE id = $1`, a); err != nil {
+		t.Fatalf("delete thread: %v", err)
+	}
+	if groups, _ = repo.GetOpenReportGroups(0); len(groups) != 2 || groups[0].TargetID != b {
+		t.Errorf("after deleting thread a got %d groups, want b and c", len(groups))
+	}
+}
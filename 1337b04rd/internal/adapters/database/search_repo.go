@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// searchHeadlineOptions are passed to ts_headline. The snippet is kept short
+// and the matches are wrapped in the highlight runes of the models package.
+var searchHeadlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MinWords=10, MaxWords=30, MaxFragments=2, FragmentDelimiter=\" … \"",
+	models.HighlightStart, models.HighlightStop)
+
+// SearchRepositoryPg searches the search_vector columns of posts and comments.
+// Both use the 'simple' text search configuration, which does not stem, so
+// threads in any language are matched word for word.
+type SearchRepositoryPg struct {
+	db *sql.DB
+}
+
+// NewSearchRepositoryPg creates a new instance of the search repository.
+func NewSearchRepositoryPg(db *sql.DB) ports.SearchRepository {
+	return &SearchRepositoryPg{db: db}
+}
+
+// Search matches query.Text, in the syntax of websearch_to_tsquery, against
+// thread titles and text and against comments, in one query.
+func (r *SearchRepositoryPg) Search(ctx context.Context, query models.SearchQuery) ([]models.SearchResult, error) {
+	args := []any{query.Text, searchHeadlineOptions}
+
+	// {item} is the matched row: the post itself or the comment
+	conds := []string{"p.is_hidden = FALSE"}
+	addCond := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if query.Board != "" {
+		addCond("p.board = $%d", query.Board)
+	}
+	switch query.Scope {
+	case models.SearchLive:
+		conds = append(conds, "p.archived_at IS NULL")
+	case models.SearchArchived:
+		conds = append(conds, "p.archived_at IS NOT NULL")
+	}
+	if !query.From.IsZero() {
+		addCond("{item}.created_at >= $%d", query.From)
+	}
+	if !query.To.IsZero() {
+		addCond("{item}.created_at < $%d", query.To)
+	}
+	if query.HasImage {
+		conds = append(conds, "COALESCE({item}.image_url, '') <> ''")
+	}
+	filter := strings.Join(conds, " AND ")
+
+	// ts_headline re-parses the whole text, so it only runs for the page
+	args = append(args, query.Limit, query.Offset)
+	sqlQuery := fmt.Sprintf(`WITH q AS (SELECT websearch_to_tsquery('simple', $1) AS query)
+	          SELECT post_id, comment_id, board, title, ts_headline('simple', body, q.query, $2), rank, image_url, created_at, archived
+	          FROM (
+	              SELECT * FROM (
+	                  SELECT p.id AS post_id, 0 AS comment_id, p.board, p.title, p.title || E'\n' || p.text AS body,
+	                         ts_rank(p.search_vector, q.query) AS rank,
+	                         COALESCE(p.image_url, '') AS image_url, p.created_at, p.archived_at IS NOT NULL AS archived
+	                  FROM posts p, q
+	                  WHERE p.search_vector @@ q.query AND %s
+	                  UNION ALL
+	                  SELECT c.post_id, c.id, p.board, p.title, c.text,
+	                         ts_rank(c.search_vector, q.query),
+	                         COALESCE(c.image_url, ''), c.created_at, p.archived_at IS NOT NULL
+	                  FROM comments c JOIN posts p ON p.id = c.post_id, q
+	                  WHERE c.search_vector @@ q.query AND %s
+	              ) matches
+	              ORDER BY rank DESC, created_at DESC, post_id DESC, comment_id DESC
+	              LIMIT $%d OFFSET $%d
+	          ) page, q
+	          ORDER BY rank DESC, created_at DESC, post_id DESC, comment_id DESC`,
+		strings.ReplaceAll(filter, "{item}", "p"), strings.ReplaceAll(filter, "{item}", "c"), len(args)-1, len(args))
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		slog.Error("Error searching posts", "error", err)
+		return nil, fmt.Errorf("error searching posts: %v", err)
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var res models.SearchResult
+		if err := rows.Scan(&res.PostID, &res.CommentID, &res.Board, &res.Title, &res.Snippet, &res.Rank,
+			&res.ImageURL, &res.CreatedAt, &res.Archived); err != nil {
+			slog.Error("Error scanning search result", "error", err)
+			return nil, fmt.Errorf("error scanning search result: %v", err)
+		}
+		results = append(results, res)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating search results: %v", rows.Err())
+	}
+	return results, nil
+}
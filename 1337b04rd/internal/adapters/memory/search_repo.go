@@ -0,0 +1,258 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// Weights of title and text matches, after the 'A' and 'B' weights the
+// PostgreSQL search_vector columns use.
+const (
+	titleWeight = 1.0
+	textWeight  = 0.4
+)
+
+// snippetWords is how many words around the first match a snippet keeps.
+const snippetWords = 30
+
+// SearchRepositoryMem is an in-memory SearchRepository for tests. It follows
+// the PostgreSQL repository closely enough for service and handler tests:
+// words match whole and case-insensitively, every word of the query must
+// appear, "-word" excludes, and the rank counts matches, with title matches
+// weighing more.
+type SearchRepositoryMem struct {
+	mu       sync.RWMutex
+	posts    map[int]models.Post
+	comments []models.Comment
+}
+
+// NewSearchRepositoryMem creates an empty in-memory search repository.
+func NewSearchRepositoryMem() *SearchRepositoryMem {
+	return &SearchRepositoryMem{posts: make(map[int]models.Post)}
+}
+
+// AddPost makes a thread searchable, replacing an earlier one with the same ID.
+func (r *SearchRepositoryMem) AddPost(post models.Post) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	post.Comments = nil
+	r.posts[post.ID] = post
+}
+
+// AddComment makes a comment searchable. Its thread has to be added too.
+func (r *SearchRepositoryMem) AddComment(comment models.Comment) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	comment.Replies = nil
+	r.comments = append(r.comments, comment)
+}
+
+// Search implements ports.SearchRepository.
+func (r *SearchRepositoryMem) Search(ctx context.Context, query models.SearchQuery) ([]models.SearchResult, error) {
+	include, exclude := parseTerms(query.Text)
+	if len(include) == 0 {
+		return nil, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var results []models.SearchResult
+	for _, post := range r.posts {
+		if !matchesThread(post, query) || !matchesItem(post.CreatedAt, post.ImageURL, query) {
+			continue
+		}
+		title, text := tokenize(post.Title), tokenize(post.Text)
+		rank, ok := rankTokens(include, exclude, title, text)
+		if !ok {
+			continue
+		}
+		results = append(results, models.SearchResult{
+			PostID:    post.ID,
+			Board:     post.Board,
+			Title:     post.Title,
+			Snippet:   snippet(post.Title+"\n"+post.Text, include),
+			Rank:      rank,
+			ImageURL:  post.ImageURL,
+			CreatedAt: post.CreatedAt,
+			Archived:  post.ArchivedAt != nil,
+		})
+	}
+	for _, comment := range r.comments {
+		post, found := r.posts[comment.PostID]
+		if !found || !matchesThread(post, query) || !matchesItem(comment.CreatedAt, comment.ImageURL, query) {
+			continue
+		}
+		rank, ok := rankTokens(include, exclude, nil, tokenize(comment.Text))
+		if !ok {
+			continue
+		}
+		results = append(results, models.SearchResult{
+			PostID:    comment.PostID,
+			CommentID: comment.ID,
+			Board:     post.Board,
+			Title:     post.Title,
+			Snippet:   snippet(comment.Text, include),
+			Rank:      rank,
+			ImageURL:  comment.ImageURL,
+			CreatedAt: comment.CreatedAt,
+			Archived:  post.ArchivedAt != nil,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		switch {
+		case a.Rank != b.Rank:
+			return a.Rank > b.Rank
+		case !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.After(b.CreatedAt)
+		case a.PostID != b.PostID:
+			return a.PostID > b.PostID
+		default:
+			return a.CommentID > b.CommentID
+		}
+	})
+
+	if query.Offset >= len(results) {
+		return nil, nil
+	}
+	results = results[query.Offset:]
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+// matchesThread applies the filters that depend on the thread only.
+func matchesThread(post models.Post, query models.SearchQuery) bool {
+	if post.IsHidden || (query.Board != "" && post.Board != query.Board) {
+		return false
+	}
+	switch query.Scope {
+	case models.SearchLive:
+		return post.ArchivedAt == nil
+	case models.SearchArchived:
+		return post.ArchivedAt != nil
+	}
+	return true
+}
+
+// matchesItem applies the filters on the matched post or comment itself.
+func matchesItem(createdAt time.Time, imageURL string, query models.SearchQuery) bool {
+	if !query.From.IsZero() && createdAt.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !createdAt.Before(query.To) {
+		return false
+	}
+	return !query.HasImage || imageURL != ""
+}
+
+// parseTerms splits a query into required words and "-word" exclusions.
+func parseTerms(text string) (include, exclude []string) {
+	for _, field := range strings.Fields(text) {
+		negated := strings.HasPrefix(field, "-")
+		for _, word := range tokenize(strings.TrimPrefix(field, "-")) {
+			if negated {
+				exclude = append(exclude, word.text)
+			} else {
+				include = append(include, word.text)
+			}
+		}
+	}
+	return include, exclude
+}
+
+// rankTokens reports whether title and text contain every included word and
+// none of the excluded ones, and ranks the match.
+func rankTokens(include, exclude []string, title, text []token) (float64, bool) {
+	counts := make(map[string]float64)
+	for _, t := range title {
+		counts[t.text] += titleWeight
+	}
+	for _, t := range text {
+		counts[t.text] += textWeight
+	}
+
+	for _, word := range exclude {
+		if counts[word] > 0 {
+			return 0, false
+		}
+	}
+	rank := 0.0
+	for _, word := range include {
+		if counts[word] == 0 {
+			return 0, false
+		}
+		rank += counts[word]
+	}
+	return rank, true
+}
+
+// token is a lowercased word and its byte offsets in the original text.
+type token struct {
+	text       string
+	start, end int
+}
+
+func tokenize(s string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range s {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{text: strings.ToLower(s[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: strings.ToLower(s[start:]), start: start, end: len(s)})
+	}
+	return tokens
+}
+
+// snippet cuts up to snippetWords words of s around the first match and
+// wraps every matched word in the highlight runes.
+func snippet(s string, include []string) string {
+	tokens := tokenize(s)
+	if len(tokens) == 0 {
+		return ""
+	}
+	wanted := make(map[string]bool, len(include))
+	for _, word := range include {
+		wanted[word] = true
+	}
+
+	first := 0
+	for i, t := range tokens {
+		if wanted[t.text] {
+			first = i
+			break
+		}
+	}
+	from := max(0, first-snippetWords/3)
+	to := min(len(tokens), from+snippetWords)
+
+	var b strings.Builder
+	pos := tokens[from].start
+	for _, t := range tokens[from:to] {
+		b.WriteString(s[pos:t.start])
+		if wanted[t.text] {
+			b.WriteString(models.HighlightStart + s[t.start:t.end] + models.HighlightStop)
+		} else {
+			b.WriteString(s[t.start:t.end])
+		}
+		pos = t.end
+	}
+	return b.String()
+}
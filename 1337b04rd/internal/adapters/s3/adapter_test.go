@@ -2,6 +2,12 @@ package s3_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -12,25 +18,23 @@ import (
 	"1337b04rd/internal/adapters/s3"
 )
 
-func TestUploadImage_Success(t *testing.T) {
+func TestUploadImages_Success(t *testing.T) {
 	// Фейковый сервер для эмуляции TripleS
-	s3Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	storage, s3Server := newFakeTripleS(t)
+	var bucketCreated bool
+	storage.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/post-images" {
+			return false
+		}
 		switch r.Method {
 		case http.MethodGet:
 			http.NotFound(w, r) // симулируем, что бакет не существует
 		case http.MethodPut:
-			// Разделяем по URL: создание бакета и загрузка изображения
-			if strings.Contains(r.URL.Path, "post-images/test-image.png") {
-				// Это загрузка изображения
-				w.WriteHeader(http.StatusOK)
-			} else {
-				// Это создание бакета
-				w.WriteHeader(http.StatusCreated)
-			}
-		default:
-			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+			bucketCreated = true
+			w.WriteHeader(http.StatusCreated)
 		}
-	}))
+		return true
+	}
 
 	publicURL := "http://public-url.com"
 	adapter := s3.NewAdapter(s3Server.URL, publicURL)
@@ -42,7 +46,9 @@ func TestUploadImage_Success(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create form file: %v", err)
 	}
-	_, err = io.Copy(part, strings.NewReader("fake image content"))
+	var img bytes.Buffer
+	png.Encode(&img, image.NewGray(image.Rect(0, 0, 10, 10)))
+	_, err = io.Copy(part, bytes.NewReader(img.Bytes()))
 	if err != nil {
 		t.Fatalf("failed to write image data: %v", err)
 	}
@@ -51,14 +57,184 @@ func TestUploadImage_Success(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/", &body)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	// Вызов метода UploadImage
-	resultURL, err := adapter.UploadImage(req, "post")
+	// Вызов метода UploadImages
+	results, err := adapter.UploadImages(req, "post", nil)
 	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d images, want 1", len(results))
+	}
+	result := results[0]
+
+	if !bucketCreated {
+		t.Error("bucket was not created")
+	}
+
+	// Ключ объекта - SHA-256 сохранённого файла, имя файла остаётся в Name
+	objects := storage.stored()
+	if len(objects) != 1 {
+		t.Fatalf("%d objects stored, want 1", len(objects))
+	}
+	var stored []byte
+	for _, object := range objects {
+		stored = object.data
+	}
+	key := sha256.Sum256(stored)
+	expectedURL := publicURL + "/post-images/" + hex.EncodeToString(key[:]) + ".png"
+	if result.URL != expectedURL {
+		t.Errorf("unexpected URL: got %s, want %s", result.URL, expectedURL)
+	}
+	if result.Name != "test-image.png" {
+		t.Errorf("unexpected name: got %q", result.Name)
+	}
+
+	// SHA-256 от загруженного файла
+	sum := sha256.Sum256(img.Bytes())
+	if expectedHash := hex.EncodeToString(sum[:]); result.Hash != expectedHash {
+		t.Errorf("unexpected hash: got %s, want %s", result.Hash, expectedHash)
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	var deleted []string
+	s3Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+			return
+		}
+		deleted = append(deleted, r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/gone.png") {
+			http.NotFound(w, r) // уже удалённый объект
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s3Server.Close()
+
+	publicURL := "http://public-url.com"
+	adapter := s3.NewAdapter(s3Server.URL, publicURL)
+
+	for _, name := range []string{"test-image.png", "gone.png"} {
+		if err := adapter.DeleteImage(context.Background(), publicURL+"/post-images/"+name); err != nil {
+			t.Errorf("unexpected error deleting %s: %v", name, err)
+		}
+	}
+	if len(deleted) != 2 || deleted[0] != "/post-images/test-image.png" {
+		t.Errorf("unexpected delete requests: %v", deleted)
 	}
 
-	expectedURL := publicURL + "/post-images/test-image.png"
-	if resultURL != expectedURL {
-		t.Errorf("unexpected URL: got %s, want %s", resultURL, expectedURL)
+	// Чужие URL не удаляются
+	if err := adapter.DeleteImage(context.Background(), "http://elsewhere.com/post-images/x.png"); err == nil {
+		t.Error("expected an error for a foreign URL")
+	}
+	if len(deleted) != 2 {
+		t.Errorf("foreign URL reached the server: %v", deleted)
+	}
+}
+
+func TestUploadImages_Thumbnail(t *testing.T) {
+	storage, s3Server := newFakeTripleS(t)
+
+	publicURL := "http://public-url.com"
+	adapter := s3.NewAdapter(s3Server.URL, publicURL)
+
+	// PNG 1000x500, уменьшается до 250x125
+	src := image.NewNRGBA(image.Rect(0, 0, 1000, 500))
+	for x := 0; x < 1000; x++ {
+		src.Set(x, 0, color.NRGBA{R: 255, A: 255})
+	}
+	var img bytes.Buffer
+	if err := png.Encode(&img, src); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", "wide.png")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	part.Write(img.Bytes())
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	results, err := adapter.UploadImages(req, "comment", nil)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("unexpected result: %v, %v", results, err)
+	}
+	result := results[0]
+	key := strings.TrimSuffix(strings.TrimPrefix(result.URL, publicURL), ".png")
+	objects := storage.stored()
+	if want := publicURL + key + "-thumb.png"; result.ThumbnailURL != want {
+		t.Errorf("unexpected thumbnail URL: got %s, want %s", result.ThumbnailURL, want)
+	}
+	if original, err := png.Decode(bytes.NewReader(objects[key+".png"].data)); err != nil || original.Bounds() != src.Bounds() {
+		t.Errorf("original was not uploaded in full size: %v", err)
+	}
+
+	thumb, err := png.Decode(bytes.NewReader(objects[key+"-thumb.png"].data))
+	if err != nil {
+		t.Fatalf("thumbnail is not a PNG: %v", err)
+	}
+	if size := thumb.Bounds().Size(); size != image.Pt(250, 125) {
+		t.Errorf("unexpected thumbnail size: %v", size)
+	}
+}
+
+func TestUploadImages_SmallImageHasNoThumbnail(t *testing.T) {
+	storage, s3Server := newFakeTripleS(t)
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewGray(image.Rect(0, 0, 100, 200)))
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", "small.png")
+	part.Write(img.Bytes())
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	results, err := s3.NewAdapter(s3Server.URL, "http://public-url.com").UploadImages(req, "post", nil)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("unexpected result: %v, %v", results, err)
+	}
+	result := results[0]
+	if result.ThumbnailURL != "" || len(storage.puts) != 1 {
+		t.Errorf("small image got a thumbnail: %q, uploads %v", result.ThumbnailURL, storage.puts)
+	}
+}
+
+func TestUploadImages_ThumbnailFailure(t *testing.T) {
+	var puts []string
+	storage, s3Server := newFakeTripleS(t)
+	storage.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut && !isStaged(r.URL.Path) {
+			puts = append(puts, r.URL.Path)
+			// Миниатюры не сохраняются
+			if strings.Contains(r.URL.Path, "-thumb") {
+				http.Error(w, "broken", http.StatusInternalServerError)
+				return true
+			}
+		}
+		return false
+	}
+
+	adapter := s3.NewAdapter(s3Server.URL, "http://public-url.com")
+	adapter.Retry = s3.RetryPolicy{Attempts: 1}
+	results, err := adapter.UploadImages(imageRequest(t, context.Background(), encodePNG(1000, 500)), "post", nil)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("unexpected result: %v, %v", results, err)
+	}
+	// Оригинал сохранён и показывается вместо миниатюры
+	if results[0].URL == "" || results[0].ThumbnailURL != "" {
+		t.Errorf("unexpected image: %+v", results[0])
+	}
+	if len(puts) != 2 || strings.Contains(puts[0], "-thumb") {
+		t.Errorf("unexpected uploads: %v", puts)
 	}
 }
@@ -0,0 +1,134 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Timeouts bound the requests sent to triple-s. Zero fields fall back to
+// DefaultTimeouts.
+type Timeouts struct {
+	Connect  time.Duration // Opening a connection
+	Response time.Duration // Waiting for the response headers once the request is sent
+	Request  time.Duration // Whole request, body and response included
+}
+
+// DefaultTimeouts leave room for the largest upload on a slow link.
+var DefaultTimeouts = Timeouts{
+	Connect:  5 * time.Second,
+	Response: 30 * time.Second,
+	Request:  2 * time.Minute,
+}
+
+func (t Timeouts) withDefaults() Timeouts {
+	if t.Connect <= 0 {
+		t.Connect = DefaultTimeouts.Connect
+	}
+	if t.Response <= 0 {
+		t.Response = DefaultTimeouts.Response
+	}
+	if t.Request <= 0 {
+		t.Request = DefaultTimeouts.Request
+	}
+	return t
+}
+
+// NewClient returns an HTTP client for triple-s with the given timeouts. It
+// keeps connections open between requests, so one client should be shared
+// by every request the adapter sends.
+func NewClient(timeouts Timeouts) *http.Client {
+	timeouts = timeouts.withDefaults()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeouts.Connect, KeepAlive: 30 * time.Second}).DialContext
+	transport.ResponseHeaderTimeout = timeouts.Response
+	transport.MaxIdleConnsPerHost = 16 // One host only; the default of 2 is too few for concurrent uploads
+	return &http.Client{Transport: transport, Timeout: timeouts.Request}
+}
+
+// defaultClient is used by adapters without a Client.
+var defaultClient = NewClient(DefaultTimeouts)
+
+// RetryPolicy decides how often a request to triple-s is tried again after a
+// connection error or a 5xx response. Zero fields fall back to
+// DefaultRetryPolicy; set Attempts to 1 to disable retries.
+type RetryPolicy struct {
+	Attempts   int           // Tries per request, the first one included
+	Backoff    time.Duration // Wait before the second try, doubled for each one after
+	MaxBackoff time.Duration // Longest wait between tries
+}
+
+// DefaultRetryPolicy tries three times over about a second.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	Backoff:    200 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.Attempts <= 0 {
+		p.Attempts = DefaultRetryPolicy.Attempts
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = DefaultRetryPolicy.Backoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+	return p
+}
+
+func (a *Adapter) client() *http.Client {
+	if a.Client != nil {
+		return a.Client
+	}
+	return defaultClient
+}
+
+// do sends the request made by newRequest, trying again with exponential
+// backoff after connection errors and 5xx responses. Every request triple-s
+// gets is idempotent, so repeating one that may have gone through is safe.
+// newRequest is called for every try, so that the body can be produced
+// again. Once ctx is done no further try is made. The last response is
+// returned even if it is a 5xx; the caller closes its body.
+func (a *Adapter) do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	retry := a.Retry.withDefaults()
+	wait := retry.Backoff
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := a.client().Do(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError || attempt >= retry.Attempts || ctx.Err() != nil {
+			return resp, err
+		}
+		if err != nil {
+			slog.Warn("Request to triple-s failed, retrying", "method", req.Method, "url", req.URL, "attempt", attempt, "error", err)
+		} else {
+			slog.Warn("Request to triple-s failed, retrying", "method", req.Method, "url", req.URL, "attempt", attempt, "status", resp.StatusCode)
+			drain(resp.Body)
+		}
+
+		// Jitter keeps uploads that failed together from retrying together
+		timer := time.NewTimer(wait/2 + rand.N(wait/2+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		wait = min(wait*2, retry.MaxBackoff)
+	}
+}
+
+// drain reads what is left of a response body, up to a limit, and closes it
+// so that the connection can be reused.
+func drain(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	body.Close()
+}
@@ -0,0 +1,205 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/adapters/s3"
+)
+
+// quickRetries повторяет запросы без заметных пауз.
+var quickRetries = s3.RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+func imageRequest(t *testing.T, ctx context.Context, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", "a.png")
+	part.Write(data)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body).WithContext(ctx)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadImages_RetriesServerErrors(t *testing.T) {
+	var mu sync.Mutex
+	tries := map[string]int{}
+	storage, s3Server := newFakeTripleS(t)
+	storage.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		tries[r.Method+" "+r.URL.Path]++
+		if r.Method != http.MethodPut || strings.Count(r.URL.Path, "/") < 2 {
+			return false
+		}
+		// Первая попытка обрывается, вторая получает 503
+		switch tries[r.Method+" "+r.URL.Path] {
+		case 1:
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return true
+		case 2:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	}
+
+	adapter := s3.NewAdapter(s3Server.URL, "http://public-url.com")
+	adapter.Retry = quickRetries
+	results, err := adapter.UploadImages(imageRequest(t, context.Background(), encodePNG(20, 10)), "post", nil)
+	if err != nil {
+		t.Fatalf("UploadImages failed: %v", err)
+	}
+
+	key := strings.TrimPrefix(results[0].URL, "http://public-url.com")
+	if tries["PUT "+key] != 3 {
+		t.Errorf("image sent %d times, want 3", tries["PUT "+key])
+	}
+	// Тело запроса при повторе создаётся заново и совпадает с ключом, как и
+	// временный файл, который кодируется заново при каждой попытке
+	sum := sha256.Sum256(storage.stored()[key].data)
+	if name := path.Base(key); name != hex.EncodeToString(sum[:])+".png" {
+		t.Errorf("stored file does not match its key %s", name)
+	}
+}
+
+func TestUploadImages_GivesUp(t *testing.T) {
+	var puts int
+	storage, s3Server := newFakeTripleS(t)
+	storage.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut && strings.Count(r.URL.Path, "/") == 2 {
+			puts++
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+
+	adapter := s3.NewAdapter(s3Server.URL, "http://public-url.com")
+	adapter.Retry = s3.RetryPolicy{Attempts: 2, Backoff: time.Millisecond}
+	if _, err := adapter.UploadImages(imageRequest(t, context.Background(), encodePNG(20, 10)), "post", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if puts != 2 {
+		t.Errorf("image sent %d times, want 2", puts)
+	}
+	if objects := storage.stored(); len(objects) != 0 {
+		t.Errorf("objects left in storage: %v", objects)
+	}
+}
+
+func TestUploadImages_BucketCheckedOnce(t *testing.T) {
+	var checks, creates int
+	storage, s3Server := newFakeTripleS(t)
+	storage.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/post-images":
+			checks++
+			if creates == 0 {
+				http.NotFound(w, r)
+			}
+			return true
+		case r.Method == http.MethodPut && r.URL.Path == "/post-images":
+			creates++
+			w.WriteHeader(http.StatusCreated)
+			return true
+		}
+		return false
+	}
+
+	adapter := s3.NewAdapter(s3Server.URL, "http://public-url.com")
+	for i := 0; i < 3; i++ {
+		if _, err := adapter.UploadImages(imageRequest(t, context.Background(), encodePNG(20+i, 10)), "post", nil); err != nil {
+			t.Fatalf("UploadImages failed: %v", err)
+		}
+	}
+	if checks != 1 || creates != 1 {
+		t.Errorf("bucket checked %d times and created %d times, want 1 and 1", checks, creates)
+	}
+}
+
+func TestUploadImages_Cancelled(t *testing.T) {
+	var requests int
+	s3Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer s3Server.Close()
+
+	// Клиент ушёл, пока форма ещё читалась
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s3.NewAdapter(s3Server.URL, "http://public-url.com").UploadImages(imageRequest(t, ctx, encodePNG(20, 10)), "post", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if requests != 0 {
+		t.Errorf("%d requests reached the storage after cancellation", requests)
+	}
+}
+
+func TestUploadImages_CancelledBetweenImages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var puts []string
+	storage, s3Server := newFakeTripleS(t)
+	storage.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut && strings.Count(r.URL.Path, "/") == 2 && !isStaged(r.URL.Path) {
+			// Клиент уходит, как только сохранён первый файл
+			puts = append(puts, r.URL.Path)
+			cancel()
+		}
+		return false
+	}
+
+	body, contentType := imagesForm(encodePNG(20, 10), encodePNG(30, 10))
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+
+	images, err := s3.NewAdapter(s3Server.URL, "http://public-url.com").UploadImages(req, "post", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	// Файл, который мог сохраниться, возвращается вместе с ошибкой, чтобы его можно было удалить
+	if len(puts) != 1 || len(images) != 1 || images[0].URL != "http://public-url.com"+puts[0] {
+		t.Errorf("stored %q, returned %d images, want the stored one returned", puts, len(images))
+	}
+	// Временные файлы удаляются и после ухода клиента
+	for key := range storage.stored() {
+		if isStaged(key) {
+			t.Errorf("temporary object %s left in storage", key)
+		}
+	}
+}
+
+func TestDeleteImage_Retries(t *testing.T) {
+	var deletes int
+	s3Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if deletes++; deletes == 1 {
+			http.Error(w, "busy", http.StatusBadGateway)
+		}
+	}))
+	defer s3Server.Close()
+
+	adapter := s3.NewAdapter(s3Server.URL, "http://public-url.com")
+	adapter.Retry = quickRetries
+	if err := adapter.DeleteImage(context.Background(), "http://public-url.com/post-images/a.png"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+	if deletes != 2 {
+		t.Errorf("delete sent %d times, want 2", deletes)
+	}
+}
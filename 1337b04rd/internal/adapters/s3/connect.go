@@ -2,117 +2,437 @@ package s3
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+
+	"1337b04rd/internal/app/domain/models"
 )
 
 type Adapter struct {
 	TripleSBaseURL  string
 	PublicAccessURL string
+	ThumbnailSize   int          // Longest side of thumbnails; 0 means DefaultThumbnailSize
+	Limits          ImageLimits  // Size limits of accepted images
+	MaxImages       int          // Files accepted per request; 0 means DefaultMaxImages
+	Client          *http.Client // Shared by all requests; nil means one with DefaultTimeouts
+	Retry           RetryPolicy  // Retries of failed requests to triple-s
+
+	buckets sync.Map // Names of buckets known to exist
 }
 
+// DefaultMaxImages is the number of images a post or comment may carry.
+const DefaultMaxImages = 4
+
 // NewAdapter creates a new Adapter instance.
 func NewAdapter(internalURL, publicURL string) *Adapter {
 	return &Adapter{
 		TripleSBaseURL:  internalURL,
 		PublicAccessURL: publicURL,
+		ThumbnailSize:   DefaultThumbnailSize,
+		Limits:          DefaultImageLimits,
+		MaxImages:       DefaultMaxImages,
+		Client:          defaultClient,
+		Retry:           DefaultRetryPolicy,
 	}
 }
 
-// UploadImage uploads an image from the HTTP request to the appropriate bucket.
-// Returns the public URL of the uploaded image or an error.
-func (a *Adapter) UploadImage(r *http.Request, imageType string) (string, error) {
-	file, header, err := r.FormFile("image")
+// UploadImages reads the multipart body of r as a stream, validating every
+// "image" file as it arrives, and uploads them, re-encoded without metadata,
+// to the appropriate bucket together with thumbnails of those larger than
+// ThumbnailSize. The other form fields are kept for r.FormValue, so callers
+// must not parse the form before. Returns the public URLs, format, size and
+// SHA-256 of each file in form order, or nil if the request has no image.
+//
+// check, unless nil, is called once the whole form has been read and before
+// any image is stored under its key, with the images as they will be
+// stored, or none. If it returns an error, nothing is stored and the error
+// is returned, so the caller can refuse the request on its fields or images
+// without leaving objects behind.
+//
+// Files are not held in memory. Each image is decoded as it arrives, then
+// re-encoded through a pipe straight into a temporary object in triple-s,
+// hashed on the way, and only then is the next one read. A request thus
+// keeps one decoded image, which ImageLimits bounds, plus the thumbnails of
+// the batch, however large the files are. Once the form has been read and
+// check accepts it, the temporary objects are copied to their content keys;
+// they are deleted however the request ends, so a refused form leaves
+// nothing behind. The batch is all or nothing: a *models.ImageError for any
+// file, or for more than MaxImages files, rejects the whole request. If
+// storing fails part way, for instance because the client went away, the
+// images stored so far, including the one that failed, are returned with
+// the error so that the caller can discard them.
+//
+// Requests to triple-s run under the context of r, so that uploads stop
+// when the client goes away.
+func (a *Adapter) UploadImages(r *http.Request, imageType string, check func([]*models.UploadedImage) error) ([]*models.UploadedImage, error) {
+	maxImages := a.MaxImages
+	if maxImages <= 0 {
+		maxImages = DefaultMaxImages
+	}
+	limits := a.Limits.withDefaults()
+	ctx := r.Context()
+	bucketName := bucketFor(imageType)
+
+	var uploads []*sanitizedImage
+	defer func() {
+		// Also once the client has gone away
+		for _, clean := range uploads {
+			a.unstage(context.WithoutCancel(ctx), bucketName, clean)
+		}
+	}()
+	// Every file may use its whole size limit, anything more is refused
+	err := readForm(r, int64(maxImages)*limits.MaxBytes, func(filename string, file io.Reader) error {
+		if len(uploads) == maxImages {
+			return reject(models.ErrInvalidInput, "more than %d files attached", maxImages)
+		}
+		clean, err := sanitizeImage(file, limits)
+		if err != nil {
+			var imageErr *models.ImageError
+			if errors.As(err, &imageErr) {
+				slog.Warn("Image rejected", "file", filename, "error", err)
+				imageErr.Reason = fmt.Sprintf("%s: %s", originalName(filename), imageErr.Reason)
+			}
+			return err
+		}
+		clean.name = originalName(filename)
+		uploads = append(uploads, clean)
+		return a.stage(ctx, bucketName, clean)
+	})
 	if err != nil {
-		if err == http.ErrMissingFile {
-			return "", nil
+		return nil, err
+	}
+
+	images := make([]*models.UploadedImage, 0, len(uploads))
+	for _, clean := range uploads {
+		images = append(images, a.describe(bucketName, clean))
+	}
+	if check != nil {
+		if err := check(images); err != nil {
+			return nil, err
+		}
+	}
+	if len(images) == 0 {
+		return nil, nil
+	}
+
+	// Objects stored before a failure are left to the caller: keys are
+	// content hashes, so another message may already use them
+	for i, clean := range uploads {
+		if err := ctx.Err(); err != nil {
+			return images[:i], err
 		}
-		return "", err
+		if err := a.storeImage(ctx, bucketName, clean, images[i]); err != nil {
+			return images[:i+1], err
+		}
+		image := images[i]
+		slog.Info("Image uploaded successfully", "url", image.URL, "thumbnail", image.ThumbnailURL, "name", image.Name, "hash", image.Hash)
 	}
-	defer file.Close()
+	return images, nil
+}
 
-	// Determine bucket name based on image type
-	bucketName := "images"
+// bucketFor returns the bucket images of imageType are stored in.
+func bucketFor(imageType string) string {
 	switch imageType {
 	case "post":
-		bucketName = "post-images"
+		return "post-images"
 	case "comment":
-		bucketName = "images"
+		return "images"
 	default:
-		bucketName = "misc-images"
+		return "misc-images"
+	}
+}
+
+// ensureBucket creates bucketName unless it already exists. Buckets found
+// or created are remembered, so each is checked once.
+func (a *Adapter) ensureBucket(ctx context.Context, bucketName string) error {
+	if _, ok := a.buckets.Load(bucketName); ok {
+		return nil
 	}
 
 	// Check if the bucket exists
 	checkBucketURL := fmt.Sprintf("%s/%s", a.TripleSBaseURL, bucketName)
-	checkReq, err := http.NewRequest(http.MethodGet, checkBucketURL, nil)
-	if err != nil {
-		slog.Error("Failed to create bucket check request", "error", err)
-		return "", err
-	}
-	checkResp, err := http.DefaultClient.Do(checkReq)
+	checkResp, err := a.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, checkBucketURL, nil)
+	})
 	if err != nil {
 		slog.Error("Failed to check bucket existence", "error", err)
-		return "", err
+		return err
 	}
-	defer checkResp.Body.Close()
+	defer drain(checkResp.Body)
 
-	// Create bucket if it doesn't exist
-	if checkResp.StatusCode == http.StatusNotFound {
+	switch {
+	case checkResp.StatusCode >= http.StatusInternalServerError:
+		body, _ := io.ReadAll(checkResp.Body)
+		slog.Error("Bucket check failed", "bucket", bucketName, "response", string(body))
+		return fmt.Errorf("bucket check failed: %s", body)
+	case checkResp.StatusCode == http.StatusNotFound:
+		// Create bucket if it doesn't exist
 		slog.Info("Bucket does not exist. Creating...", "bucket", bucketName)
 
-		createReq, err := http.NewRequest(http.MethodPut, checkBucketURL, nil)
-		if err != nil {
-			slog.Error("Failed to create bucket request", "error", err)
-			return "", err
-		}
-		createResp, err := http.DefaultClient.Do(createReq)
+		createResp, err := a.do(ctx, func() (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodPut, checkBucketURL, nil)
+		})
 		if err != nil {
 			slog.Error("Failed to send bucket creation request", "error", err)
-			return "", err
+			return err
 		}
-		defer createResp.Body.Close()
+		defer drain(createResp.Body)
 
-		if createResp.StatusCode != http.StatusCreated && createResp.StatusCode != http.StatusOK {
+		// Conflict: created meanwhile by another upload, or by a try that
+		// timed out after all
+		switch createResp.StatusCode {
+		case http.StatusCreated, http.StatusOK, http.StatusConflict:
+		default:
 			body, _ := io.ReadAll(createResp.Body)
 			slog.Error("Bucket creation failed", "response", string(body))
-			return "", fmt.Errorf("bucket creation failed: %s", body)
+			return fmt.Errorf("bucket creation failed: %s", body)
 		}
 		slog.Info("Bucket created successfully", "bucket", bucketName)
 	}
+	a.buckets.Store(bucketName, true)
+	return nil
+}
 
-	// Read the uploaded file into buffer
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, file); err != nil {
-		slog.Error("Failed to read file", "error", err)
-		return "", err
+// thumbnailSize returns the longest side of thumbnails.
+func (a *Adapter) thumbnailSize() int {
+	if a.ThumbnailSize <= 0 {
+		return DefaultThumbnailSize
 	}
+	return a.ThumbnailSize
+}
 
-	// Upload the image to the bucket
-	uploadURL := fmt.Sprintf("%s/%s/%s", a.TripleSBaseURL, bucketName, header.Filename)
-	publicURL := fmt.Sprintf("%s/%s/%s", a.PublicAccessURL, bucketName, header.Filename)
+// tmpKeyBytes is the number of random bytes in the key of a temporary object.
+const tmpKeyBytes = 16
 
-	uploadReq, err := http.NewRequest(http.MethodPut, uploadURL, &buf)
+// stage makes the thumbnail of clean and streams its re-encoded file to a
+// temporary object in bucket, which gives its content key and size. The
+// decoded image is dropped afterwards. An image whose thumbnail cannot be
+// made is stored without one.
+func (a *Adapter) stage(ctx context.Context, bucket string, clean *sanitizedImage) error {
+	if err := a.ensureBucket(ctx, bucket); err != nil {
+		return err
+	}
+	thumb, err := makeThumbnail(clean.img, clean.format, a.thumbnailSize())
 	if err != nil {
-		slog.Error("Failed to create upload request", "error", err)
-		return "", err
+		slog.Warn("Failed to create thumbnail", "name", clean.name, "error", err)
+	}
+	clean.thumb = thumb
+
+	random := make([]byte, tmpKeyBytes)
+	rand.Read(random)
+	clean.tmpKey = "tmp-" + hex.EncodeToString(random) + clean.format.Ext()
+
+	// Every try encodes the image again, which gives the same bytes
+	var file *hashingReader
+	body := func() (io.ReadCloser, error) {
+		file = newHashingReader(clean.encoded())
+		return file, nil
+	}
+	if err := a.putObject(ctx, bucket, clean.tmpKey, body, -1, clean.format.ContentType()); err != nil {
+		return err
 	}
-	uploadReq.Header.Set("Content-Type", "application/octet-stream")
+	if !file.eof {
+		return fmt.Errorf("storage answered before the %s image was sent in full", clean.format)
+	}
+	clean.key, clean.size = hex.EncodeToString(file.hash.Sum(nil)), file.n
+	clean.img, clean.anim = nil, nil
+	return nil
+}
+
+// unstage deletes the temporary object of clean, if stage got as far as
+// sending it. Failures are logged.
+func (a *Adapter) unstage(ctx context.Context, bucket string, clean *sanitizedImage) {
+	if clean.tmpKey == "" {
+		return
+	}
+	if err := a.deleteObject(ctx, bucket+"/"+clean.tmpKey); err != nil {
+		slog.Warn("Failed to delete temporary image", "bucket", bucket, "key", clean.tmpKey, "error", err)
+	}
+}
+
+// describe returns the URLs and details a staged image will have once
+// stored in bucket. The key is the SHA-256 of the stored file plus the
+// extension of its format, so the same image is stored once however often
+// and under whatever name it is uploaded; the thumbnail key adds "-thumb".
+func (a *Adapter) describe(bucket string, clean *sanitizedImage) *models.UploadedImage {
+	image := &models.UploadedImage{
+		URL:    fmt.Sprintf("%s/%s/%s", a.PublicAccessURL, bucket, clean.key+clean.format.Ext()),
+		Hash:   clean.hash,
+		Format: clean.format,
+		Name:   clean.name,
+		Size:   clean.size,
+		Width:  clean.width,
+		Height: clean.height,
+	}
+	if thumb := clean.thumb; thumb != nil {
+		image.ThumbnailURL = fmt.Sprintf("%s/%s/%s", a.PublicAccessURL, bucket, clean.key+"-thumb"+thumb.ext)
+	}
+	return image
+}
 
-	uploadResp, err := http.DefaultClient.Do(uploadReq)
+// storeImage copies a staged image to bucket under the key describe gave
+// it, streaming it from its temporary object, and uploads its thumbnail. If
+// the thumbnail cannot be stored, the ThumbnailURL of image is cleared.
+func (a *Adapter) storeImage(ctx context.Context, bucket string, clean *sanitizedImage, image *models.UploadedImage) error {
+	staged := fmt.Sprintf("%s/%s/%s", a.TripleSBaseURL, bucket, clean.tmpKey)
+	body := func() (io.ReadCloser, error) {
+		getResp, err := a.do(ctx, func() (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, staged, nil)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if getResp.StatusCode != http.StatusOK {
+			defer drain(getResp.Body)
+			body, _ := io.ReadAll(getResp.Body)
+			return nil, fmt.Errorf("reading temporary image failed: %s", body)
+		}
+		return getResp.Body, nil
+	}
+	if err := a.putObject(ctx, bucket, clean.key+clean.format.Ext(), body, clean.size, clean.format.ContentType()); err != nil {
+		return err
+	}
+
+	// The original is stored already; without a thumbnail pages show it
+	// instead
+	if thumb := clean.thumb; thumb != nil {
+		thumbKey := clean.key + "-thumb" + thumb.ext
+		body := func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(thumb.data)), nil }
+		if err := a.putObject(ctx, bucket, thumbKey, body, int64(len(thumb.data)), thumb.contentType); err != nil {
+			slog.Warn("Failed to store thumbnail", "key", thumbKey, "error", err)
+			image.ThumbnailURL = ""
+		}
+	}
+	return nil
+}
+
+// RekeyImage moves an image stored under its client file name, as uploads
+// were before content-addressed keys, to a content-addressed key in the same
+// bucket. The file goes through the same validation as new uploads and gets
+// a new thumbnail. The old object is left in place; the caller deletes it
+// once nothing refers to it. check, unless nil, is called with the new image
+// before it is stored, and can stop it.
+func (a *Adapter) RekeyImage(ctx context.Context, imageURL string, check func(*models.UploadedImage) error) (*models.UploadedImage, error) {
+	objectPath, ok := strings.CutPrefix(imageURL, a.PublicAccessURL+"/")
+	if !ok {
+		return nil, fmt.Errorf("image URL %q is not served from %s", imageURL, a.PublicAccessURL)
+	}
+	bucket, name, ok := strings.Cut(objectPath, "/")
+	if !ok {
+		return nil, fmt.Errorf("image URL %q has no bucket", imageURL)
+	}
+
+	getResp, err := a.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", a.TripleSBaseURL, objectPath), nil)
+	})
+	if err != nil {
+		slog.Error("Failed to download image", "url", imageURL, "error", err)
+		return nil, err
+	}
+	defer drain(getResp.Body)
+	if getResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(getResp.Body)
+		return nil, fmt.Errorf("download failed: %s", body)
+	}
+
+	clean, err := sanitizeImage(getResp.Body, a.Limits)
+	if err != nil {
+		return nil, err
+	}
+	clean.name = originalName(name)
+	defer a.unstage(context.WithoutCancel(ctx), bucket, clean)
+	if err := a.stage(ctx, bucket, clean); err != nil {
+		return nil, err
+	}
+	image := a.describe(bucket, clean)
+	if check != nil {
+		if err := check(image); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.storeImage(ctx, bucket, clean, image); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Image rekeyed", "from", imageURL, "to", image.URL)
+	return image, nil
+}
+
+// putObject uploads size bytes of body as bucket/key; a size of -1 sends
+// the body chunked until it ends. body is called for every try of the
+// request.
+func (a *Adapter) putObject(ctx context.Context, bucket, key string, body func() (io.ReadCloser, error), size int64, contentType string) error {
+	uploadResp, err := a.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("%s/%s/%s", a.TripleSBaseURL, bucket, key), nil)
+		if err != nil {
+			return nil, err
+		}
+		rc, err := body()
+		if err != nil {
+			return nil, err
+		}
+		req.Body, req.ContentLength = rc, size // The transport closes the body
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
 	if err != nil {
 		slog.Error("Failed to upload image", "error", err)
-		return "", err
+		return err
 	}
-	defer uploadResp.Body.Close()
+	defer drain(uploadResp.Body)
 
 	if uploadResp.StatusCode != http.StatusOK {
+		// The bucket may have been removed since it was checked
+		if uploadResp.StatusCode == http.StatusNotFound {
+			a.buckets.Delete(bucket)
+		}
 		body, _ := io.ReadAll(uploadResp.Body)
-		slog.Error("Image upload failed", "response", string(body))
-		return "", fmt.Errorf("upload failed: %s", body)
+		slog.Error("Image upload failed", "key", key, "response", string(body))
+		return fmt.Errorf("upload failed: %s", body)
 	}
+	return nil
+}
+
+// DeleteImage deletes the object behind a public image URL from its bucket.
+// A missing object counts as deleted.
+func (a *Adapter) DeleteImage(ctx context.Context, imageURL string) error {
+	objectPath, ok := strings.CutPrefix(imageURL, a.PublicAccessURL+"/")
+	if !ok {
+		return fmt.Errorf("image URL %q is not served from %s", imageURL, a.PublicAccessURL)
+	}
+	if err := a.deleteObject(ctx, objectPath); err != nil {
+		return err
+	}
+	slog.Info("Image deleted", "url", imageURL)
+	return nil
+}
 
-	slog.Info("Image uploaded successfully", "url", publicURL)
-	return publicURL, nil
+// deleteObject deletes the object at objectPath, "bucket/key". A missing
+// object counts as deleted.
+func (a *Adapter) deleteObject(ctx context.Context, objectPath string) error {
+	deleteResp, err := a.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf("%s/%s", a.TripleSBaseURL, objectPath), nil)
+	})
+	if err != nil {
+		slog.Error("Failed to delete object", "path", objectPath, "error", err)
+		return err
+	}
+	defer drain(deleteResp.Body)
+
+	switch deleteResp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		body, _ := io.ReadAll(deleteResp.Body)
+		slog.Error("Object deletion failed", "path", objectPath, "response", string(body))
+		return fmt.Errorf("delete failed: %s", body)
+	}
 }
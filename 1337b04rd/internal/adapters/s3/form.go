@@ -0,0 +1,103 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// maxFormValueBytes bounds the form fields other than files, all together.
+const maxFormValueBytes = 1 << 20
+
+// maxFormOverheadBytes allows for the boundaries and part headers of a form
+// on top of its files and fields.
+const maxFormOverheadBytes = 64 << 10
+
+// readForm walks the multipart body of r part by part, handing each
+// non-empty "image" file to onImage as it arrives. Nothing is spooled to
+// memory or disk. A body longer than maxFiles bytes of files plus the
+// fields and overhead allowed above is refused. The other fields are kept
+// in r.MultipartForm, r.PostForm and r.Form, so that r.FormValue works
+// afterwards. A form already parsed by the caller is read from
+// r.MultipartForm instead.
+func readForm(r *http.Request, maxFiles int64, onImage func(filename string, file io.Reader) error) error {
+	if r.MultipartForm != nil {
+		for _, header := range r.MultipartForm.File["image"] {
+			if header.Filename == "" && header.Size == 0 {
+				continue
+			}
+			file, err := header.Open()
+			if err != nil {
+				return err
+			}
+			err = onImage(header.Filename, file)
+			file.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	limit := maxFiles + maxFormValueBytes + maxFormOverheadBytes
+	r.Body = http.MaxBytesReader(nil, r.Body, limit)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return fmt.Errorf("failed to parse form: %v: %w", err, models.ErrInvalidInput)
+	}
+	values := make(url.Values)
+	budget := int64(maxFormValueBytes)
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			return fmt.Errorf("form is larger than %d bytes: %w", limit, models.ErrInvalidInput)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse form: %v: %w", err, models.ErrInvalidInput)
+		}
+
+		switch {
+		case part.FormName() == "image":
+			// Browsers send an empty part for a file input left blank
+			if part.FileName() != "" {
+				err = onImage(part.FileName(), part)
+			}
+		case part.FileName() != "":
+			// Files in other fields are ignored
+		default:
+			var value []byte
+			value, err = io.ReadAll(io.LimitReader(part, budget+1))
+			if budget -= int64(len(value)); err == nil && budget < 0 {
+				err = fmt.Errorf("form fields are larger than %d bytes: %w", maxFormValueBytes, models.ErrInvalidInput)
+			}
+			values.Add(part.FormName(), string(value))
+		}
+		part.Close()
+		if errors.As(err, &maxBytes) {
+			return fmt.Errorf("form is larger than %d bytes: %w", limit, models.ErrInvalidInput)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	r.MultipartForm = &multipart.Form{Value: values}
+	r.PostForm = values
+	r.Form = make(url.Values)
+	for key, vs := range values {
+		r.Form[key] = append(r.Form[key], vs...)
+	}
+	for key, vs := range r.URL.Query() {
+		r.Form[key] = append(r.Form[key], vs...)
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// ImageLimits bounds the images UploadImages accepts. Zero fields fall back
+// to DefaultImageLimits.
+type ImageLimits struct {
+	MaxBytes     int64 // File size
+	MaxDimension int   // Longest side, in pixels
+	MaxPixels    int   // Width × height, summed over all frames of a GIF
+}
+
+// DefaultImageLimits allows files up to 10 MiB and 8000 pixels per side.
+var DefaultImageLimits = ImageLimits{
+	MaxBytes:     10 << 20,
+	MaxDimension: 8000,
+	MaxPixels:    40_000_000,
+}
+
+func (l ImageLimits) withDefaults() ImageLimits {
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultImageLimits.MaxBytes
+	}
+	if l.MaxDimension <= 0 {
+		l.MaxDimension = DefaultImageLimits.MaxDimension
+	}
+	if l.MaxPixels <= 0 {
+		l.MaxPixels = DefaultImageLimits.MaxPixels
+	}
+	return l
+}
+
+// sanitizedJPEGQuality is used when re-encoding uploaded JPEG images.
+const sanitizedJPEGQuality = 90
+
+// sniffedFormats maps the MIME types detected from the first bytes of a file
+// to the formats the pipeline accepts.
+var sniffedFormats = map[string]models.ImageFormat{
+	"image/jpeg": models.ImageJPEG,
+	"image/png":  models.ImagePNG,
+	"image/gif":  models.ImageGIF,
+}
+
+// errCorrupt is reported by the format scanner for truncated or malformed files.
+var errCorrupt = errors.New("corrupt image")
+
+// sanitizedImage is an upload that passed validation. It holds the decoded
+// image until Adapter.stage streams the re-encoded file to storage and makes
+// the thumbnail.
+type sanitizedImage struct {
+	format        models.ImageFormat
+	img           image.Image // Decoded image, upright; the first frame of a GIF
+	anim          *gif.GIF    // Every frame of a GIF
+	width, height int         // Size as displayed, after orientation
+	hash          string      // Hex SHA-256 of the file as uploaded
+	name          string      // Client file name, cleaned up by originalName
+
+	tmpKey string     // Temporary object holding the re-encoded file, set by stage
+	key    string     // Hex SHA-256 of the re-encoded file
+	size   int64      // Bytes of the re-encoded file
+	thumb  *thumbnail // nil when the image is small enough or has no thumbnail
+}
+
+// encode writes the re-encoded file. The output depends only on the decoded
+// image, so encoding twice gives the same bytes.
+func (s *sanitizedImage) encode(w io.Writer) error {
+	switch s.format {
+	case models.ImageJPEG:
+		return jpeg.Encode(w, s.img, &jpeg.Options{Quality: sanitizedJPEGQuality})
+	case models.ImagePNG:
+		return png.Encode(w, s.img)
+	default:
+		return gif.EncodeAll(w, s.anim)
+	}
+}
+
+// encoded streams the re-encoded file through a pipe, encoding in a
+// goroutine as it is read. Closing the reader early stops the goroutine.
+func (s *sanitizedImage) encoded() *io.PipeReader {
+	pr, pw := io.Pipe()
+	go func() {
+		if err := s.encode(pw); err != nil {
+			pw.CloseWithError(fmt.Errorf("failed to encode %s image: %v", s.format, err))
+			return
+		}
+		pw.Close()
+	}()
+	return pr
+}
+
+// hashingReader hashes and counts what is read through it and notes whether
+// the end was reached.
+type hashingReader struct {
+	r    io.Reader // A TeeReader into hash
+	c    io.Closer
+	hash hash.Hash
+	n    int64
+	eof  bool
+}
+
+func newHashingReader(rc io.ReadCloser) *hashingReader {
+	h := sha256.New()
+	return &hashingReader{r: io.TeeReader(rc, h), c: rc, hash: h}
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.n += int64(n)
+	if err == io.EOF {
+		h.eof = true
+	}
+	return n, err
+}
+
+func (h *hashingReader) Close() error {
+	return h.c.Close()
+}
+
+func reject(err error, format string, args ...any) error {
+	return &models.ImageError{Reason: fmt.Sprintf(format, args...), Err: err}
+}
+
+// uploadReader passes an upload through to the decoder while hashing it,
+// enforcing the size limit and feeding it to the format scanner. The first
+// error sticks, so a decoder cannot carry on past it.
+type uploadReader struct {
+	r     io.Reader
+	hash  hash.Hash
+	n     int64
+	limit int64
+	scan  *imageScanner
+	err   error
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	n, err := u.r.Read(p)
+	if n > 0 {
+		if u.n += int64(n); u.n > u.limit {
+			u.err = reject(models.ErrInvalidInput, "file is larger than %d bytes", u.limit)
+			return 0, u.err
+		}
+		u.hash.Write(p[:n])
+		if _, err := u.scan.Write(p[:n]); err != nil {
+			u.err = err
+			return 0, err
+		}
+	}
+	return n, err
+}
+
+// sanitizeImage reads one image from r and checks that it is exactly one
+// JPEG, PNG or GIF image within limits. The format comes from the file
+// contents, never from its name. The file streams through to the decoder
+// and is not kept; only the decoded image is, whose size the limits bound.
+// Re-encoding it drops EXIF (including GPS), text chunks, comments and
+// anything else that is not pixels; JPEG images are first turned upright
+// according to their EXIF orientation.
+func sanitizeImage(r io.Reader, limits ImageLimits) (*sanitizedImage, error) {
+	limits = limits.withDefaults()
+
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	format, ok := sniffedFormats[http.DetectContentType(head)]
+	if !ok {
+		return nil, reject(models.ErrUnsupportedMedia, "not a JPEG, PNG or GIF image")
+	}
+
+	scan := newImageScanner(format, limits)
+	u := &uploadReader{r: br, hash: sha256.New(), limit: limits.MaxBytes, scan: scan}
+	out := &sanitizedImage{format: format}
+	switch format {
+	case models.ImageJPEG:
+		out.img, err = jpeg.Decode(u)
+	case models.ImagePNG:
+		out.img, err = png.Decode(u)
+	case models.ImageGIF:
+		if out.anim, err = gif.DecodeAll(u); err == nil {
+			out.img = out.anim.Image[0]
+			out.width, out.height = out.anim.Config.Width, out.anim.Config.Height
+		}
+	}
+	// Decoders stop at the end of the image; the rest still has to be read
+	// for the size limit and the check for appended data
+	if err == nil {
+		_, err = io.Copy(io.Discard, u)
+	}
+	if err == nil {
+		err = scan.finish()
+	}
+	if err != nil {
+		var imageErr *models.ImageError
+		switch {
+		case errors.As(u.err, &imageErr):
+			return nil, u.err
+		case errors.Is(u.err, errTrailingData):
+			return nil, reject(models.ErrUnsupportedMedia, "other data after the %s image", format)
+		case u.err != nil && !errors.Is(u.err, errCorrupt):
+			return nil, u.err // Reading the upload failed
+		}
+		return nil, reject(models.ErrUnsupportedMedia, "corrupt %s image", format)
+	}
+
+	if format == models.ImageJPEG {
+		out.img = orient(out.img, scan.orientation)
+	}
+	if out.width == 0 {
+		out.width, out.height = out.img.Bounds().Dx(), out.img.Bounds().Dy()
+	}
+	out.hash = hex.EncodeToString(u.hash.Sum(nil))
+	return out, nil
+}
+
+// exifOrientation reads the orientation tag from the first IFD of an APP1
+// segment, returning 1 when the segment has none.
+func exifOrientation(app1 []byte) int {
+	tiff, ok := bytes.CutPrefix(app1, []byte("Exif\x00\x00"))
+	if !ok || len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// orient applies an EXIF orientation (2-8) to img, so that the image stays
+// upright once the tag is gone.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored
+				sx, sy = w-1-x, y
+			case 3: // Upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // Upside down and mirrored
+				sx, sy = x, h-1-y
+			case 5: // Transposed
+				sx, sy = y, x
+			case 6: // Needs a quarter turn clockwise
+				sx, sy = y, h-1-x
+			case 7: // Transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // Needs a quarter turn counterclockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+	return dst
+}
+
+// maxNameLength is the longest original file name kept, in bytes.
+const maxNameLength = 255
+
+// originalName cleans up a client file name for display: the directory
+// part some browsers send and control characters are dropped.
+func originalName(filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(name, "")))
+	for len(name) > maxNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
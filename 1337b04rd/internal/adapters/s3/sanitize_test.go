@@ -0,0 +1,389 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"1337b04rd/internal/adapters/s3"
+	"1337b04rd/internal/app/domain/models"
+)
+
+// storedObject is a PUT received by the fake TripleS server.
+type storedObject struct {
+	data        []byte
+	contentType string
+}
+
+// fakeTripleS keeps objects in memory: PUT stores, GET serves and DELETE
+// removes an object. Buckets always exist. intercept, unless nil, sees every
+// request first, after its body has been read, and answers it instead when
+// it returns true.
+type fakeTripleS struct {
+	mu        sync.Mutex
+	objects   map[string]storedObject
+	puts      []string // Paths of objects stored under their content key
+	intercept func(w http.ResponseWriter, r *http.Request) bool
+}
+
+// newFakeTripleS starts a fake TripleS server, closed when the test ends.
+func newFakeTripleS(t testing.TB) (*fakeTripleS, *httptest.Server) {
+	f := &fakeTripleS{objects: map[string]storedObject{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+// isStaged tells the temporary objects uploads go through from the rest.
+func isStaged(objectPath string) bool {
+	return strings.HasPrefix(path.Base(objectPath), "tmp-")
+}
+
+func (f *fakeTripleS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	intercept := f.intercept
+	f.mu.Unlock()
+	if intercept != nil && intercept(w, r) {
+		return
+	}
+	if strings.Count(r.URL.Path, "/") < 2 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[r.URL.Path] = storedObject{data: body, contentType: r.Header.Get("Content-Type")}
+		if !isStaged(r.URL.Path) {
+			f.puts = append(f.puts, r.URL.Path)
+		}
+	case http.MethodGet:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Write(object.data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+	}
+}
+
+// stored returns the objects left in storage.
+func (f *fakeTripleS) stored() map[string]storedObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	objects := make(map[string]storedObject, len(f.objects))
+	for key, object := range f.objects {
+		objects[key] = object
+	}
+	return objects
+}
+
+// formFile is one "image" part of a test upload.
+type formFile struct {
+	name string
+	data []byte
+}
+
+// uploadAll sends files as "image" form files to adapter, pointed at a fake
+// TripleS server, and returns the objects left in storage.
+func uploadAll(t *testing.T, adapter *s3.Adapter, files ...formFile) ([]*models.UploadedImage, map[string]storedObject, error) {
+	t.Helper()
+	storage, s3Server := newFakeTripleS(t)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, f := range files {
+		part, err := writer.CreateFormFile("image", f.name)
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		part.Write(f.data)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	adapter.TripleSBaseURL = s3Server.URL
+	result, err := adapter.UploadImages(req, "post", nil)
+	return result, storage.stored(), err
+}
+
+// upload sends data as the only "image" form file to an adapter with the
+// given limits and returns what reached the storage.
+func upload(t *testing.T, filename string, data []byte, limits s3.ImageLimits) (*models.UploadedImage, map[string]storedObject, error) {
+	t.Helper()
+	adapter := s3.NewAdapter("", "http://public-url.com")
+	adapter.Limits = limits
+	results, objects, err := uploadAll(t, adapter, formFile{filename, data})
+	if len(results) > 1 {
+		t.Fatalf("got %d images for one file", len(results))
+	}
+	if len(results) == 0 {
+		return nil, objects, err
+	}
+	return results[0], objects, err
+}
+
+func encodePNG(w, h int) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)))
+	return buf.Bytes()
+}
+
+func encodeGIF(w, h, frames int) []byte {
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	gif.EncodeAll(&buf, g)
+	return buf.Bytes()
+}
+
+func TestUploadImages_Rejected(t *testing.T) {
+	pngFile := encodePNG(20, 10)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		limits s3.ImageLimits
+		want   error
+	}{
+		{"text", []byte("just some text, definitely not an image"), s3.ImageLimits{}, models.ErrUnsupportedMedia},
+		{"html", []byte("<html><script>alert(1)</script></html>"), s3.ImageLimits{}, models.ErrUnsupportedMedia},
+		{"truncated", pngFile[:len(pngFile)-20], s3.ImageLimits{}, models.ErrUnsupportedMedia},
+		// PNG с приклеенным ZIP-архивом
+		{"polyglot", append(append([]byte{}, pngFile...), "PK\x03\x04 hidden archive"...), s3.ImageLimits{}, models.ErrUnsupportedMedia},
+		{"too many bytes", pngFile, s3.ImageLimits{MaxBytes: 32}, models.ErrInvalidInput},
+		{"too wide", encodePNG(200, 10), s3.ImageLimits{MaxDimension: 100}, models.ErrInvalidInput},
+		{"too many pixels", encodePNG(50, 50), s3.ImageLimits{MaxPixels: 2000}, models.ErrInvalidInput},
+		// Каждый кадр GIF считается отдельно
+		{"too many frames", encodeGIF(20, 20, 6), s3.ImageLimits{MaxPixels: 2000}, models.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, objects, err := upload(t, "image.png", tt.data, tt.limits)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			var imageErr *models.ImageError
+			if !errors.As(err, &imageErr) {
+				t.Errorf("error is not a *models.ImageError: %T", err)
+			}
+			if result != nil || len(objects) != 0 {
+				t.Errorf("rejected file was stored: %v", objects)
+			}
+		})
+	}
+}
+
+func TestUploadImages_StripsEXIF(t *testing.T) {
+	// JPEG 40x20 с EXIF: ориентация 6 (повернуть на 90° по часовой) и
+	// координаты, которые не должны попасть в хранилище
+	var img bytes.Buffer
+	jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 40, 20)), nil)
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)                                   // Одна запись в IFD
+	tiff = append(tiff, 0x01, 0x12, 0x00, 0x03, 0, 0, 0, 1, 0x00, 0x06, 0x00, 0x00) // Orientation = 6
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, "GPS 55.7558N 37.6173E"...)
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	segment := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(app1)+2))
+	data := append(append(append([]byte{}, img.Bytes()[:2]...), append(segment, app1...)...), img.Bytes()[2:]...)
+
+	result, objects, err := upload(t, `..\photos/IMG 0001.PNG`, data, s3.ImageLimits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Format != models.ImageJPEG {
+		t.Errorf("got format %q, want jpeg", result.Format)
+	}
+
+	// Расширение и Content-Type берутся из содержимого, а не из имени файла
+	stored, ok := objects[strings.TrimPrefix(result.URL, "http://public-url.com")]
+	if !ok || !strings.HasSuffix(result.URL, ".jpg") {
+		t.Fatalf("image stored under an unexpected name: %s", result.URL)
+	}
+	if result.Name != "IMG 0001.PNG" {
+		t.Errorf("got name %q, want the client file name without its directory", result.Name)
+	}
+	if stored.contentType != "image/jpeg" {
+		t.Errorf("got content type %q, want image/jpeg", stored.contentType)
+	}
+	if bytes.Contains(stored.data, []byte("Exif")) || bytes.Contains(stored.data, []byte("GPS")) {
+		t.Error("metadata was not stripped")
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(stored.data))
+	if err != nil {
+		t.Fatalf("stored image is not a JPEG: %v", err)
+	}
+	if size := decoded.Bounds().Size(); size != image.Pt(20, 40) {
+		t.Errorf("orientation was not applied: got size %v", size)
+	}
+}
+
+func TestUploadImages_AnimatedGIF(t *testing.T) {
+	data := encodeGIF(30, 20, 3)
+	// Комментарий перед трейлером
+	data = append(data[:len(data)-1], append([]byte{0x21, 0xFE, 5}, "hello\x00\x3b"...)...)
+
+	result, objects, err := upload(t, "anim.gif", data, s3.ImageLimits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored := objects[strings.TrimPrefix(result.URL, "http://public-url.com")]
+	if result.Format != models.ImageGIF || stored.contentType != "image/gif" {
+		t.Errorf("got format %q stored as %q", result.Format, stored.contentType)
+	}
+	if bytes.Contains(stored.data, []byte("hello")) {
+		t.Error("comment was not stripped")
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(stored.data))
+	if err != nil {
+		t.Fatalf("stored image is not a GIF: %v", err)
+	}
+	if len(g.Image) != 3 {
+		t.Errorf("got %d frames, want 3", len(g.Image))
+	}
+}
+
+func TestUploadImages_ContentAddressed(t *testing.T) {
+	data := encodePNG(20, 10)
+
+	first, objects, err := upload(t, "image.png", data, s3.ImageLimits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, _, err := upload(t, "other name.png", data, s3.ImageLimits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.URL != second.URL {
+		t.Errorf("same image got two keys: %s and %s", first.URL, second.URL)
+	}
+	if second.Name != "other name.png" || strings.Contains(first.URL, "image.png") {
+		t.Errorf("file name leaked into the key or was lost: %s, %q", first.URL, second.Name)
+	}
+
+	// Разные картинки с одинаковым именем не перезаписывают друг друга
+	third, _, err := upload(t, "image.png", encodePNG(10, 20), s3.ImageLimits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if third.URL == first.URL {
+		t.Errorf("different images share the key %s", first.URL)
+	}
+
+	for path, object := range objects {
+		sum := sha256.Sum256(object.data)
+		if want := "/post-images/" + hex.EncodeToString(sum[:]) + ".png"; path != want {
+			t.Errorf("object %s is not keyed by its SHA-256 %s", path, want)
+		}
+	}
+}
+
+func TestRekeyImage(t *testing.T) {
+	storage, s3Server := newFakeTripleS(t)
+	storage.objects["/images/cat.png"] = storedObject{data: encodePNG(20, 10), contentType: "image/png"}
+
+	publicURL := "http://public-url.com"
+	adapter := s3.NewAdapter(s3Server.URL, publicURL)
+	result, err := adapter.RekeyImage(context.Background(), publicURL+"/images/cat.png", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	objects := storage.stored()
+	stored, ok := objects[strings.TrimPrefix(result.URL, publicURL)]
+	sum := sha256.Sum256(stored.data)
+	if !ok || result.URL != publicURL+"/images/"+hex.EncodeToString(sum[:])+".png" {
+		t.Errorf("image was not stored under its content key: %s", result.URL)
+	}
+	if result.Name != "cat.png" {
+		t.Errorf("got name %q, want the old file name", result.Name)
+	}
+	// Старый объект остаётся, временный удалён
+	if _, ok := objects["/images/cat.png"]; !ok || len(objects) != 2 {
+		t.Errorf("unexpected objects after rekeying: %d", len(objects))
+	}
+	if _, err := adapter.RekeyImage(context.Background(), publicURL+"/images/missing.png", nil); err == nil {
+		t.Error("expected an error for a missing object")
+	}
+}
+
+func TestUploadImages_Batch(t *testing.T) {
+	pngFile, gifFile := encodePNG(20, 10), encodeGIF(30, 20, 2)
+
+	results, objects, err := uploadAll(t, s3.NewAdapter("", "http://public-url.com"),
+		formFile{"first.png", pngFile}, formFile{"second.gif", gifFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 || len(objects) != 2 {
+		t.Fatalf("got %d images and %d objects, want 2 of each", len(results), len(objects))
+	}
+	// Порядок как в форме, у каждого файла свои размеры и имя
+	first, second := results[0], results[1]
+	if first.Name != "first.png" || first.Format != models.ImagePNG || first.Width != 20 || first.Height != 10 {
+		t.Errorf("unexpected first image: %+v", first)
+	}
+	if second.Name != "second.gif" || second.Format != models.ImageGIF || second.Width != 30 || second.Height != 20 {
+		t.Errorf("unexpected second image: %+v", second)
+	}
+	if stored := objects[strings.TrimPrefix(second.URL, "http://public-url.com")]; int64(len(stored.data)) != second.Size {
+		t.Errorf("got size %d, stored %d bytes", second.Size, len(stored.data))
+	}
+}
+
+func TestUploadImages_AllOrNothing(t *testing.T) {
+	pngFile := encodePNG(20, 10)
+
+	// Один плохой файл отклоняет весь запрос, ничего не сохраняется
+	results, objects, err := uploadAll(t, s3.NewAdapter("", "http://public-url.com"),
+		formFile{"good.png", pngFile}, formFile{"bad.png", []byte("not an image")})
+	if !errors.Is(err, models.ErrUnsupportedMedia) {
+		t.Fatalf("got %v, want ErrUnsupportedMedia", err)
+	}
+	if !strings.Contains(err.Error(), "bad.png") {
+		t.Errorf("error does not name the file: %v", err)
+	}
+	if results != nil || len(objects) != 0 {
+		t.Errorf("batch with a bad file was stored: %v", objects)
+	}
+
+	adapter := s3.NewAdapter("", "http://public-url.com")
+	adapter.MaxImages = 2
+	results, objects, err = uploadAll(t, adapter,
+		formFile{"1.png", pngFile}, formFile{"2.png", pngFile}, formFile{"3.png", pngFile})
+	var imageErr *models.ImageError
+	if !errors.Is(err, models.ErrInvalidInput) || !errors.As(err, &imageErr) {
+		t.Fatalf("got %v, want an ImageError with ErrInvalidInput", err)
+	}
+	if results != nil || len(objects) != 0 {
+		t.Errorf("too many files were stored: %v", objects)
+	}
+}
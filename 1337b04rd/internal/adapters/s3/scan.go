@@ -0,0 +1,301 @@
+package s3
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// errTrailingData is reported by imageScanner for non-zero bytes after the
+// end of the image.
+var errTrailingData = errors.New("data after the image")
+
+// imageScanner follows the structure of a JPEG, PNG or GIF file as it
+// streams past, without keeping it. Sizes are checked as soon as a header
+// declares them, so a decoder reading through the scanner never allocates
+// for an image over the limits. It also counts GIF frames, picks up the
+// EXIF orientation of a JPEG and finds where the image ends: anything after
+// that other than zero padding is a second file riding along, such as an
+// archive appended to make a polyglot.
+//
+// Scanning is a chain of steps: skip some bytes, then collect need bytes
+// and hand them to next, which sets up the following step.
+type imageScanner struct {
+	limits ImageLimits
+
+	skip    int64
+	need    int
+	buf     []byte
+	next    func(b []byte) error
+	entropy bool // Inside JPEG entropy-coded data, looking for a marker
+	ff      bool // The last entropy-coded byte was 0xFF
+	done    bool // Past the end of the image
+
+	width, height int
+	frames        int
+	orientation   int  // EXIF orientation of a JPEG, 1 when there is none
+	segment       byte // Marker of the JPEG segment being read
+}
+
+func newImageScanner(format models.ImageFormat, limits ImageLimits) *imageScanner {
+	s := &imageScanner{limits: limits, orientation: 1}
+	switch format {
+	case models.ImageJPEG:
+		s.step(0, 2, s.jpegStart)
+	case models.ImagePNG:
+		s.step(0, 8, s.pngStart)
+	case models.ImageGIF:
+		s.step(0, 13, s.gifHeader) // Header and logical screen descriptor
+	}
+	return s
+}
+
+func (s *imageScanner) step(skip int64, need int, next func([]byte) error) error {
+	s.skip, s.need, s.next = skip, need, next
+	return nil
+}
+
+func (s *imageScanner) end([]byte) error {
+	s.done = true
+	return nil
+}
+
+// Write feeds the next bytes of the file to the scanner.
+func (s *imageScanner) Write(p []byte) (int, error) {
+	n := len(p)
+scan:
+	for {
+		if s.done {
+			for _, c := range p {
+				if c != 0 {
+					return 0, errTrailingData
+				}
+			}
+			return n, nil
+		}
+		if s.skip > 0 {
+			k := min(s.skip, int64(len(p)))
+			s.skip -= k
+			p = p[k:]
+			if s.skip > 0 {
+				return n, nil
+			}
+		}
+
+		// Entropy-coded data runs until a marker other than a restart;
+		// 0xFF bytes in it are followed by 0x00
+		if s.entropy {
+			for i, c := range p {
+				if s.ff && c != 0 && c != 0xFF && (c < 0xD0 || c > 0xD7) {
+					s.entropy, s.ff = false, false
+					p = p[i+1:]
+					if err := s.jpegCode([]byte{c}); err != nil {
+						return 0, err
+					}
+					continue scan
+				}
+				s.ff = c == 0xFF
+			}
+			return n, nil
+		}
+
+		if len(s.buf) < s.need {
+			k := min(s.need-len(s.buf), len(p))
+			s.buf = append(s.buf, p[:k]...)
+			p = p[k:]
+			if len(s.buf) < s.need {
+				return n, nil
+			}
+		}
+		next := s.next
+		if next == nil {
+			return 0, errCorrupt
+		}
+		b := s.buf
+		s.buf, s.need, s.next = s.buf[:0], 0, nil
+		if err := next(b); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// finish reports whether the whole image has been seen.
+func (s *imageScanner) finish() error {
+	if !s.done {
+		return errCorrupt
+	}
+	return nil
+}
+
+// checkSize records the canvas size and checks it against the limits.
+func (s *imageScanner) checkSize(width, height int) error {
+	if width <= 0 || height <= 0 || width > s.limits.MaxDimension || height > s.limits.MaxDimension {
+		return reject(models.ErrInvalidInput, "image is %dx%d, at most %d pixels per side are allowed",
+			width, height, s.limits.MaxDimension)
+	}
+	s.width, s.height = width, height
+	return nil
+}
+
+// addFrame counts a frame and checks the total pixels against the limits.
+func (s *imageScanner) addFrame() error {
+	s.frames++
+	if pixels := s.width * s.height * s.frames; pixels > s.limits.MaxPixels {
+		return reject(models.ErrInvalidInput, "image has %d pixels, at most %d are allowed", pixels, s.limits.MaxPixels)
+	}
+	return nil
+}
+
+// PNG: signature, then chunks of length, type, data and CRC up to IEND.
+
+func (s *imageScanner) pngStart(b []byte) error {
+	if string(b) != "\x89PNG\r\n\x1a\n" {
+		return errCorrupt
+	}
+	return s.step(0, 8, s.pngChunk)
+}
+
+func (s *imageScanner) pngChunk(b []byte) error {
+	length := int64(binary.BigEndian.Uint32(b))
+	if length > 1<<31-1 {
+		return errCorrupt
+	}
+	switch string(b[4:8]) {
+	case "IHDR":
+		if length != 13 {
+			return errCorrupt
+		}
+		return s.step(0, 13, s.pngHeader)
+	case "IEND":
+		return s.step(length+4, 0, s.end)
+	}
+	return s.step(length+4, 8, s.pngChunk)
+}
+
+func (s *imageScanner) pngHeader(b []byte) error {
+	if err := s.checkSize(int(binary.BigEndian.Uint32(b)), int(binary.BigEndian.Uint32(b[4:]))); err != nil {
+		return err
+	}
+	if err := s.addFrame(); err != nil {
+		return err
+	}
+	return s.step(4, 8, s.pngChunk)
+}
+
+// GIF: header, color table, then extensions and images made of data
+// sub-blocks up to the trailer.
+
+func (s *imageScanner) gifHeader(b []byte) error {
+	if err := s.checkSize(int(binary.LittleEndian.Uint16(b[6:])), int(binary.LittleEndian.Uint16(b[8:]))); err != nil {
+		return err
+	}
+	var table int64
+	if flags := b[10]; flags&0x80 != 0 {
+		table = 3 << (flags&7 + 1)
+	}
+	return s.step(table, 1, s.gifBlock)
+}
+
+func (s *imageScanner) gifBlock(b []byte) error {
+	switch b[0] {
+	case 0x3B: // Trailer
+		return s.end(nil)
+	case 0x21: // Extension: label, then data sub-blocks
+		return s.step(1, 1, s.gifSubBlock)
+	case 0x2C: // Image descriptor
+		return s.step(0, 9, s.gifImage)
+	}
+	return errCorrupt
+}
+
+func (s *imageScanner) gifImage(b []byte) error {
+	if err := s.addFrame(); err != nil {
+		return err
+	}
+	skip := int64(1) // LZW code size
+	if flags := b[8]; flags&0x80 != 0 {
+		skip += 3 << (flags&7 + 1) // Local color table
+	}
+	return s.step(skip, 1, s.gifSubBlock)
+}
+
+func (s *imageScanner) gifSubBlock(b []byte) error {
+	if b[0] == 0 {
+		return s.step(0, 1, s.gifBlock)
+	}
+	return s.step(int64(b[0]), 1, s.gifSubBlock)
+}
+
+// JPEG: SOI, then markers with or without a length-prefixed segment up to
+// EOI. Each scan is followed by entropy-coded data.
+
+func (s *imageScanner) jpegStart(b []byte) error {
+	if b[0] != 0xFF || b[1] != 0xD8 {
+		return errCorrupt
+	}
+	return s.step(0, 1, s.jpegMarker)
+}
+
+func (s *imageScanner) jpegMarker(b []byte) error {
+	if b[0] != 0xFF {
+		return errCorrupt
+	}
+	return s.step(0, 1, s.jpegCode)
+}
+
+func (s *imageScanner) jpegCode(b []byte) error {
+	switch marker := b[0]; {
+	case marker == 0xFF: // Fill byte
+		return s.step(0, 1, s.jpegCode)
+	case marker == 0xD9: // EOI
+		return s.end(nil)
+	case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7: // No payload
+		return s.step(0, 1, s.jpegMarker)
+	default:
+		s.segment = marker
+		return s.step(0, 2, s.jpegLength)
+	}
+}
+
+func (s *imageScanner) jpegLength(b []byte) error {
+	n := int(binary.BigEndian.Uint16(b)) - 2
+	if n < 0 {
+		return errCorrupt
+	}
+	switch m := s.segment; {
+	case m == 0xE1 && s.orientation == 1:
+		return s.step(0, n, s.jpegExif)
+	case m >= 0xC0 && m <= 0xCF && m != 0xC4 && m != 0xC8 && m != 0xCC: // Start of frame
+		if n < 5 {
+			return errCorrupt
+		}
+		return s.step(0, n, s.jpegFrame)
+	}
+	return s.step(int64(n), 0, s.jpegSegmentEnd)
+}
+
+func (s *imageScanner) jpegExif(b []byte) error {
+	s.orientation = exifOrientation(b)
+	return s.jpegSegmentEnd(nil)
+}
+
+func (s *imageScanner) jpegFrame(b []byte) error {
+	if err := s.checkSize(int(binary.BigEndian.Uint16(b[3:])), int(binary.BigEndian.Uint16(b[1:]))); err != nil {
+		return err
+	}
+	if s.frames == 0 {
+		if err := s.addFrame(); err != nil {
+			return err
+		}
+	}
+	return s.jpegSegmentEnd(nil)
+}
+
+func (s *imageScanner) jpegSegmentEnd([]byte) error {
+	if s.segment == 0xDA { // Start of scan
+		s.entropy = true
+		return nil
+	}
+	return s.step(0, 1, s.jpegMarker)
+}
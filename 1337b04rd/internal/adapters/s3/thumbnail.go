@@ -0,0 +1,90 @@
+package s3
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// DefaultThumbnailSize is the longest side of a thumbnail, in pixels.
+const DefaultThumbnailSize = 250
+
+// thumbnailJPEGQuality is used for thumbnails of JPEG images. Other formats
+// get PNG thumbnails so that transparency survives.
+const thumbnailJPEGQuality = 80
+
+// thumbnail is an encoded thumbnail ready to be uploaded.
+type thumbnail struct {
+	data        []byte
+	contentType string
+	ext         string
+}
+
+// makeThumbnail scales src down so that its longest side is maxSize. It
+// returns nil if the image is already small enough to serve as its own
+// thumbnail.
+func makeThumbnail(src image.Image, format models.ImageFormat, maxSize int) (*thumbnail, error) {
+	if b := src.Bounds(); b.Dx() <= maxSize && b.Dy() <= maxSize {
+		return nil, nil
+	}
+
+	scaled := scaleDown(src, maxSize)
+	var buf bytes.Buffer
+	if format == models.ImageJPEG {
+		if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+			return nil, err
+		}
+		return &thumbnail{data: buf.Bytes(), contentType: "image/jpeg", ext: ".jpg"}, nil
+	}
+	if err := png.Encode(&buf, scaled); err != nil {
+		return nil, err
+	}
+	return &thumbnail{data: buf.Bytes(), contentType: "image/png", ext: ".png"}, nil
+}
+
+// scaleDown resizes src so that its longest side is maxSize, keeping the
+// aspect ratio. Every thumbnail pixel is the average of the source pixels it
+// covers, which avoids the aliasing of nearest-neighbour sampling.
+func scaleDown(src image.Image, maxSize int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := maxSize, max(1, h*maxSize/w)
+	if h > w {
+		tw, th = max(1, w*maxSize/h), maxSize
+	}
+
+	// Premultiplied RGBA averages correctly across transparent pixels
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := y * h / th
+		y1 := max((y+1)*h/th, y0+1)
+		for x := 0; x < tw; x++ {
+			x0 := x * w / tw
+			x1 := max((x+1)*w/tw, x0+1)
+
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += uint64(row[i])
+					sum[1] += uint64(row[i+1])
+					sum[2] += uint64(row[i+2])
+					sum[3] += uint64(row[i+3])
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			px := dst.Pix[y*dst.Stride+x*4:]
+			for i := range sum {
+				px[i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
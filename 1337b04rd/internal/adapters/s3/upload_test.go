@@ -0,0 +1,264 @@
+package s3_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"1337b04rd/internal/adapters/s3"
+	"1337b04rd/internal/app/domain/models"
+)
+
+func TestUploadImages_FormValues(t *testing.T) {
+	_, s3Server := newFakeTripleS(t)
+
+	// Поля до и после файла
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("name", "anon")
+	part, _ := writer.CreateFormFile("image", "a.png")
+	part.Write(encodePNG(20, 10))
+	writer.WriteField("comment", "hello")
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/?board=b", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	results, err := s3.NewAdapter(s3Server.URL, "http://public-url.com").UploadImages(req, "post", nil)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("unexpected result: %v, %v", results, err)
+	}
+	for key, want := range map[string]string{"name": "anon", "comment": "hello", "board": "b"} {
+		if got := req.FormValue(key); got != want {
+			t.Errorf("FormValue(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestUploadImages_CheckBeforeStoring(t *testing.T) {
+	storage, s3Server := newFakeTripleS(t)
+	adapter := s3.NewAdapter(s3Server.URL, "http://public-url.com")
+
+	// Поле после файла уже прочитано, а URL известны до сохранения
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", "a.png")
+	part.Write(encodePNG(20, 10))
+	writer.WriteField("comment", "hi")
+	writer.Close()
+
+	refused := errors.New("refused")
+	for _, verdict := range []error{refused, nil} {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body.Bytes()))
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		var seen []*models.UploadedImage
+		images, err := adapter.UploadImages(req, "post", func(images []*models.UploadedImage) error {
+			if req.FormValue("comment") != "hi" {
+				t.Error("form values are not readable in check")
+			}
+			seen = images
+			return verdict
+		})
+		if !errors.Is(err, verdict) {
+			t.Fatalf("error = %v, want %v", err, verdict)
+		}
+		if len(seen) != 1 || !strings.HasPrefix(seen[0].URL, "http://public-url.com/post-images/") {
+			t.Fatalf("check saw %+v, want the image with its URL", seen)
+		}
+		// Временный файл удаляется, под ключом изображения ничего не сохраняется
+		if objects := storage.stored(); verdict != nil && (images != nil || len(storage.puts) != 0 || len(objects) != 0) {
+			t.Errorf("refused batch: got %v, stored %v, left %d objects, want nothing stored", images, storage.puts, len(objects))
+		}
+	}
+	if objects := storage.stored(); len(storage.puts) != 1 || len(objects) != 1 {
+		t.Errorf("stored %v, left %d objects, want 1 once accepted", storage.puts, len(objects))
+	}
+}
+
+func TestUploadImages_NotMultipart(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("comment=hi")))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if _, err := s3.NewAdapter("", "http://public-url.com").UploadImages(req, "post", nil); err == nil {
+		t.Error("expected an error for a form that is not multipart")
+	}
+}
+
+func TestUploadImages_BodyLimit(t *testing.T) {
+	storage, s3Server := newFakeTripleS(t)
+
+	adapter := s3.NewAdapter(s3Server.URL, "http://public-url.com")
+	adapter.MaxImages = 1
+	adapter.Limits.MaxBytes = 1 << 10
+
+	// Файлы в других полях не сохраняются, но тело с ними всё равно
+	// ограничено
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", "a.png")
+	part.Write(encodePNG(4, 4))
+	part, _ = writer.CreateFormFile("other", "big.bin")
+	part.Write(make([]byte, 2<<20))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	if _, err := adapter.UploadImages(req, "post", nil); !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("got %v, want ErrInvalidInput", err)
+	}
+	if objects := storage.stored(); len(storage.puts) != 0 || len(objects) != 0 {
+		t.Errorf("stored %v, left %d objects, want nothing stored", storage.puts, len(objects))
+	}
+}
+
+// noisyPNG encodes a side x side grayscale image whose first rows are
+// random noise. More noise makes a larger file with the same number of
+// pixels.
+func noisyPNG(side, noisyRows int) []byte {
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	rng := rand.New(rand.NewSource(1))
+	rng.Read(img.Pix[:noisyRows*img.Stride])
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// imagesForm builds a multipart body with every file in the "image" field.
+func imagesForm(files ...[]byte) (body []byte, contentType string) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for i, data := range files {
+		part, _ := writer.CreateFormFile("image", fmt.Sprintf("%d.png", i))
+		part.Write(data)
+	}
+	writer.Close()
+	return buf.Bytes(), writer.FormDataContentType()
+}
+
+// recyclingTripleS stands in for TripleS when memory is measured. It keeps
+// the temporary objects uploads go through in buffers that it reuses once
+// they are deleted, and discards everything else. Once warmed up, it thus
+// allocates no more for a large file than for a small one, and what is
+// measured is the adapter.
+type recyclingTripleS struct {
+	mu      sync.Mutex
+	objects map[string]*bytes.Buffer
+	free    []*bytes.Buffer
+}
+
+func (f *recyclingTripleS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isStaged(r.URL.Path) {
+		io.Copy(io.Discard, r.Body)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		buf := new(bytes.Buffer)
+		if n := len(f.free); n > 0 {
+			buf, f.free = f.free[n-1], f.free[:n-1]
+		}
+		buf.Reset()
+		buf.ReadFrom(r.Body)
+		f.objects[r.URL.Path] = buf
+	case http.MethodGet:
+		w.Write(f.objects[r.URL.Path].Bytes())
+	case http.MethodDelete:
+		if buf, ok := f.objects[r.URL.Path]; ok {
+			f.free = append(f.free, buf)
+			delete(f.objects, r.URL.Path)
+		}
+	}
+}
+
+// newMeasuredAdapter returns an adapter pointed at a recyclingTripleS.
+func newMeasuredAdapter(tb testing.TB) *s3.Adapter {
+	s3Server := httptest.NewServer(&recyclingTripleS{objects: map[string]*bytes.Buffer{}})
+	tb.Cleanup(s3Server.Close)
+	return s3.NewAdapter(s3Server.URL, "http://public-url.com")
+}
+
+// allocatedPerUpload uploads body once to warm up, then n times, and
+// returns the bytes allocated per upload.
+func allocatedPerUpload(tb testing.TB, adapter *s3.Adapter, body []byte, contentType string, n int) int64 {
+	upload := func() {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if _, err := adapter.UploadImages(req, "post", nil); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	upload()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < n; i++ {
+		upload()
+	}
+	runtime.ReadMemStats(&after)
+	return int64(after.TotalAlloc-before.TotalAlloc) / int64(n)
+}
+
+func TestUploadImages_MemoryIndependentOfFileSize(t *testing.T) {
+	adapter := newMeasuredAdapter(t)
+
+	// Изображения одного размера в пикселях: однотонное сжимается в
+	// килобайт, шумное занимает мегабайт. Декодированное изображение у них
+	// одинаковое, а файлы идут потоком, поэтому памяти нужно столько же
+	const side = 1024
+	// Миниатюра шумного изображения тоже шумная и больше, но её размер
+	// ограничен ThumbnailSize, а не размером файла
+	adapter.ThumbnailSize = side
+	flat, noisy := noisyPNG(side, 0), noisyPNG(side, side)
+	flatBody, flatType := imagesForm(flat)
+	noisyBody, noisyType := imagesForm(noisy)
+
+	flatAlloc := allocatedPerUpload(t, adapter, flatBody, flatType, 3)
+	noisyAlloc := allocatedPerUpload(t, adapter, noisyBody, noisyType, 3)
+	if grown := noisyAlloc - flatAlloc; grown > int64(len(noisy))/8 {
+		t.Errorf("a %d byte file allocates %d bytes more than a %d byte file of the same size in pixels, want at most %d",
+			len(noisy), grown, len(flat), len(noisy)/8)
+	}
+}
+
+// BenchmarkUploadImages uploads PNG files of 2048x2048 pixels whose size
+// grows from a few kilobytes to 4 MiB with the share of random noise in
+// them. B/op stays the same for every file size: the files stream through,
+// and only the decoded image, which depends on the pixels alone, is held.
+func BenchmarkUploadImages(b *testing.B) {
+	adapter := newMeasuredAdapter(b)
+
+	const side = 2048
+	for _, noise := range []int{0, 4, 2, 1} {
+		rows := 0
+		if noise > 0 {
+			rows = side / noise
+		}
+		data := noisyPNG(side, rows)
+		body, contentType := imagesForm(data)
+
+		b.Run(fmt.Sprintf("%dKiB", len(data)>>10), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+				req.Header.Set("Content-Type", contentType)
+				if _, err := adapter.UploadImages(req, "post", nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
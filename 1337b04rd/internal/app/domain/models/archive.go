@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ArchivePolicy controls when inactive threads are moved to the archive.
+type ArchivePolicy struct {
+	NoReplyTTL time.Duration // Lifetime of a thread that never got a reply
+	ReplyTTL   time.Duration // Lifetime of a thread after its latest reply
+}
+
+// DefaultArchivePolicy archives threads without replies after 10 minutes and
+// threads with replies 15 minutes after the last one.
+var DefaultArchivePolicy = ArchivePolicy{
+	NoReplyTTL: 10 * time.Minute,
+	ReplyTTL:   15 * time.Minute,
+}
+
+// PurgePolicy controls when archived threads are deleted for good.
+type PurgePolicy struct {
+	Retention time.Duration // Time a thread stays in the archive, 0 keeps threads forever
+	DryRun    bool          // Only report what would be deleted
+}
+
+// PurgeSummary describes the threads removed by one purge run, or the ones
+// that would be removed in a dry run.
+type PurgeSummary struct {
+	ThreadIDs []int
+	Comments  int
+	Images    []string // Image and thumbnail URLs no longer used by any remaining post or comment
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Attachment is an image attached to a post or comment. Position orders
+// the attachments of one message, starting at 0.
+type Attachment struct {
+	ID           int       `json:"id"`
+	Position     int       `json:"position"`
+	URL          string    `json:"url"` // Ends in the content-addressed object key
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"` // Bytes of the stored file
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Name         string    `json:"name,omitempty"` // File name the image was uploaded with
+	Hash         string    `json:"-"`              // SHA-256 of the file as uploaded, for duplicate detection
+	CreatedAt    time.Time `json:"-"`
+}
+
+// NewAttachments turns a batch of uploads into attachments in upload order.
+func NewAttachments(images []*UploadedImage) []Attachment {
+	var attachments []Attachment
+	for i, image := range images {
+		attachments = append(attachments, Attachment{
+			Position:     i,
+			URL:          image.URL,
+			ThumbnailURL: image.ThumbnailURL,
+			ContentType:  image.Format.ContentType(),
+			Size:         image.Size,
+			Width:        image.Width,
+			Height:       image.Height,
+			Name:         image.Name,
+			Hash:         image.Hash,
+		})
+	}
+	return attachments
+}
+
+// Format is the image format of the attachment, from its MIME type.
+func (a *Attachment) Format() ImageFormat {
+	return ImageFormat(strings.TrimPrefix(a.ContentType, "image/"))
+}
+
+// Thumbnail returns the URL to show in place of the image: its thumbnail, or
+// the image itself when it has none.
+func (a *Attachment) Thumbnail() string {
+	if a.ThumbnailURL != "" {
+		return a.ThumbnailURL
+	}
+	return a.URL
+}
+
+// legacyAttachments returns the single image of a message stored before
+// attachments, or nil.
+func legacyAttachments(url, thumbnailURL, name string) []Attachment {
+	if url == "" {
+		return nil
+	}
+	return []Attachment{{URL: url, ThumbnailURL: thumbnailURL, Name: name}}
+}
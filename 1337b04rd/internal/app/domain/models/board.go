@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxBoardTitleLength       = 64
+	maxBoardDescriptionLength = 500
+)
+
+var boardSlugPattern = regexp.MustCompile(`^[a-z0-9]{1,16}$`)
+
+// reservedBoardSlugs are top-level paths served by other routes.
+var reservedBoardSlugs = map[string]bool{
+	"api": true, "archive": true, "archived": true, "create": true, "create-post": true,
+	"mod": true, "post": true, "posts": true, "report": true, "search": true, "static": true, "submit-comment": true,
+}
+
+// Board is a section of the site with its own catalog, archive and rules.
+type Board struct {
+	Slug        string        `json:"slug"` // URL path segment, e.g. "b" for /b/
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Archive     ArchivePolicy `json:"-"`           // Zero TTLs fall back to the global policy
+	MaxThreads  int           `json:"max_threads"` // Live threads kept on the board, 0 means no cap
+
+	RequireImage bool `json:"require_image"` // New threads must have an image
+	TextOnly     bool `json:"text_only"`     // No images in threads or replies
+	PosterIDs    bool `json:"poster_ids"`    // Show per-thread poster IDs
+
+	ImageFormats []ImageFormat `json:"image_formats"` // Formats allowed for upload, empty means all
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks the slug and the settings of a board.
+func (b *Board) Validate() error {
+	if !boardSlugPattern.MatchString(b.Slug) {
+		return fmt.Errorf("board slug must be 1-16 lowercase letters or digits: %w", ErrInvalidInput)
+	}
+	if reservedBoardSlugs[b.Slug] {
+		return fmt.Errorf("board slug %q is reserved: %w", b.Slug, ErrInvalidInput)
+	}
+	if b.Title == "" || utf8.RuneCountInString(b.Title) > maxBoardTitleLength {
+		return fmt.Errorf("board title must be 1-%d characters: %w", maxBoardTitleLength, ErrInvalidInput)
+	}
+	if utf8.RuneCountInString(b.Description) > maxBoardDescriptionLength {
+		return fmt.Errorf("board description longer than %d characters: %w", maxBoardDescriptionLength, ErrInvalidInput)
+	}
+	if b.Archive.NoReplyTTL < 0 || b.Archive.ReplyTTL < 0 || b.MaxThreads < 0 {
+		return fmt.Errorf("board TTLs and thread cap cannot be negative: %w", ErrInvalidInput)
+	}
+	if b.RequireImage && b.TextOnly {
+		return fmt.Errorf("a board cannot both require images and be text-only: %w", ErrInvalidInput)
+	}
+	for _, f := range b.ImageFormats {
+		if _, err := ParseImageFormat(string(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasImageFormat reports whether format is listed in ImageFormats.
+func (b *Board) HasImageFormat(format ImageFormat) bool {
+	return slices.Contains(b.ImageFormats, format)
+}
+
+// CheckImage returns an *ImageError if images of the given format cannot be
+// posted on the board.
+func (b *Board) CheckImage(format ImageFormat) error {
+	if b.TextOnly {
+		return fmt.Errorf("/%s/ is text-only: %w", b.Slug, ErrInvalidInput)
+	}
+	if len(b.ImageFormats) == 0 || b.HasImageFormat(format) {
+		return nil
+	}
+	return &ImageError{
+		Reason: fmt.Sprintf("%s images are not allowed on /%s/", strings.ToUpper(string(format)), b.Slug),
+		Err:    ErrUnsupportedMedia,
+	}
+}
+
+// ArchivePolicy returns the board's archive TTLs, taking unset ones from fallback.
+func (b *Board) ArchivePolicy(fallback ArchivePolicy) ArchivePolicy {
+	policy := b.Archive
+	if policy.NoReplyTTL == 0 {
+		policy.NoReplyTTL = fallback.NoReplyTTL
+	}
+	if policy.ReplyTTL == 0 {
+		policy.ReplyTTL = fallback.ReplyTTL
+	}
+	return policy
+}
+
+// Path is the URL of the board catalog.
+func (b *Board) Path() string {
+	return "/" + b.Slug + "/"
+}
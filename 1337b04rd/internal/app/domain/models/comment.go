@@ -5,13 +5,57 @@ import (
 )
 
 type Comment struct {
-	ID              int        `json:"id"`
-	PostID          int        `json:"post_id"`
-	ParentCommentID *int       `json:"parent_comment_id"` // Может быть NULL
-	UserName        string     `json:"user_name"`
-	UserAvatar      string     `json:"user_avatar"`
-	Text            string     `json:"text"`
-	ImageURL        string     `json:"image_url,omitempty"`
-	CreatedAt       time.Time  `json:"created_at"`
-	Replies         []*Comment `json:"replies"`
+	ID              int          `json:"id"`
+	PostID          int          `json:"post_id"`
+	ParentCommentID *int         `json:"parent_comment_id"` // Может быть NULL
+	UserName        string       `json:"user_name"`
+	Tripcode        string       `json:"tripcode,omitempty"`  // "!xxx" or "!!xxx" when posted as name#password
+	PosterID        string       `json:"poster_id,omitempty"` // Per-thread hash of the session
+	UserAvatar      string       `json:"user_avatar"`
+	Text            string       `json:"text"`
+	TextHTML        string       `json:"text_html,omitempty"`     // Text rendered by the markup renderer
+	ImageURL        string       `json:"image_url,omitempty"`     // First attachment, kept for older clients
+	ThumbnailURL    string       `json:"thumbnail_url,omitempty"` // Scaled-down copy of the image, if one was made
+	ImageName       string       `json:"image_name,omitempty"`    // File name the image was uploaded with
+	Attachments     []Attachment `json:"attachments,omitempty"`   // Every attached image, in order
+	Sage            bool         `json:"sage"`                    // Reply without bumping the thread
+	CreatedAt       time.Time    `json:"created_at"`
+	Depth           int          `json:"depth"`                    // Nesting level in the tree view
+	HiddenReplies   int          `json:"hidden_replies,omitempty"` // Replies cut off by CollapseAfter
+	Replies         []*Comment   `json:"replies"`
+	Backlinks       []Backlink   `json:"backlinks,omitempty"` // Comments quoting this one
+	QuotedIDs       []int        `json:"-"`                   // >>id references parsed from Text
+	NameField       string       `json:"-"`                   // Raw "name#password" input, resolved by CommentService
+	SessionID       string       `json:"-"`                   // Author session, used for the poster ID
+
+	Fingerprint `json:"-"` // Stored for duplicate detection only
+}
+
+// SetAttachments sets the attachments of a new comment and mirrors the
+// first one into ImageURL, ThumbnailURL, ImageName and ImageHash.
+func (c *Comment) SetAttachments(attachments []Attachment) {
+	c.Attachments = attachments
+	c.ImageURL, c.ThumbnailURL, c.ImageName, c.ImageHash = "", "", "", ""
+	if len(attachments) > 0 {
+		first := attachments[0]
+		c.ImageURL, c.ThumbnailURL, c.ImageName, c.ImageHash = first.URL, first.ThumbnailURL, first.Name, first.Hash
+	}
+}
+
+// Images returns the attachments to show, falling back to ImageURL for
+// comments made before attachments were stored separately.
+func (c *Comment) Images() []Attachment {
+	if len(c.Attachments) > 0 {
+		return c.Attachments
+	}
+	return legacyAttachments(c.ImageURL, c.ThumbnailURL, c.ImageName)
+}
+
+// Thumbnail returns the URL to show in place of the image: its thumbnail, or
+// the image itself when it has none.
+func (c *Comment) Thumbnail() string {
+	if c.ThumbnailURL != "" {
+		return c.ThumbnailURL
+	}
+	return c.ImageURL
 }
@@ -0,0 +1,31 @@
+package models
+
+// CommentViewMode selects how the comments of a thread are arranged.
+type CommentViewMode string
+
+const (
+	// CommentViewTree nests every reply under the comment it answers.
+	CommentViewTree CommentViewMode = "tree"
+	// CommentViewFlat lists all comments chronologically; replies link to
+	// their parent with a >>id quote instead of being nested.
+	CommentViewFlat CommentViewMode = "flat"
+)
+
+// CommentViewOptions controls how a comment tree is built.
+type CommentViewOptions struct {
+	Mode CommentViewMode
+	// MaxDepth caps the nesting level; deeper replies are attached to their
+	// ancestor at the deepest allowed level. 0 means unlimited.
+	MaxDepth int
+	// CollapseAfter keeps only the first N replies of each comment and
+	// records the rest in HiddenReplies. 0 disables collapsing.
+	CollapseAfter int
+}
+
+// ParseCommentViewMode returns the mode named by s, defaulting to the tree view.
+func ParseCommentViewMode(s string) CommentViewMode {
+	if CommentViewMode(s) == CommentViewFlat {
+		return CommentViewFlat
+	}
+	return CommentViewTree
+}
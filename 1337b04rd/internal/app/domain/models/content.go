@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// UploadedImage is an image stored by the S3 adapter.
+type UploadedImage struct {
+	URL          string
+	ThumbnailURL string      // Empty when the image is small enough to be its own thumbnail
+	Hash         string      // Hex SHA-256 of the file as uploaded by the client
+	Format       ImageFormat // Detected from the file contents
+	Name         string      // Client file name, for display only
+	Size         int64       // Bytes of the stored file
+	Width        int
+	Height       int
+}
+
+// DuplicateAction decides what happens to content that repeats recent posts.
+type DuplicateAction string
+
+const (
+	DuplicateReject DuplicateAction = "reject" // Refuse with ErrConflict
+	DuplicateFlag   DuplicateAction = "flag"   // Accept and file a report
+	DuplicateOff    DuplicateAction = "off"    // Only banned images are refused
+)
+
+// ParseDuplicateAction returns the action named by s, or fallback.
+func ParseDuplicateAction(s string, fallback DuplicateAction) DuplicateAction {
+	switch DuplicateAction(s) {
+	case DuplicateReject, DuplicateFlag, DuplicateOff:
+		return DuplicateAction(s)
+	default:
+		return fallback
+	}
+}
+
+// DuplicatePolicy controls duplicate detection for new posts and comments.
+type DuplicatePolicy struct {
+	Window time.Duration // How far back to look for the same text or image
+	Action DuplicateAction
+}
+
+// DefaultDuplicatePolicy rejects text or images seen in the last hour.
+var DefaultDuplicatePolicy = DuplicatePolicy{
+	Window: time.Hour,
+	Action: DuplicateReject,
+}
+
+// Fingerprint identifies the content of a post or comment.
+type Fingerprint struct {
+	TextHash    string   // Empty when the text is too short to compare
+	ImageHash   string   // First attachment, empty when there is none
+	ImageHashes []string // Every attachment; only checked, not stored
+}
+
+// BannedImage is an image hash that can never be posted again.
+type BannedImage struct {
+	Hash          string    `json:"hash"`
+	Reason        string    `json:"reason"`
+	ModeratorID   int       `json:"moderator_id"`
+	ModeratorName string    `json:"moderator_name"`
+	CreatedAt     time.Time `json:"created_at"`
+}
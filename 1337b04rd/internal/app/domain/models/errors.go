@@ -0,0 +1,15 @@
+package models
+
+import "errors"
+
+// Domain errors shared by services and adapters. Callers wrap them with
+// context and the interface layer maps them to HTTP status codes.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrInvalidInput     = errors.New("invalid input")
+	ErrForbidden        = errors.New("forbidden")
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrRateLimited      = errors.New("rate limited")
+	ErrConflict         = errors.New("conflict")
+	ErrUnsupportedMedia = errors.New("unsupported media type")
+)
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// FilterRuleKind selects what a filter rule does to submitted text.
+type FilterRuleKind string
+
+const (
+	FilterBanWord     FilterRuleKind = "ban_word"     // Reject text containing Pattern as a word
+	FilterReplace     FilterRuleKind = "replace"      // Replace regexp Pattern with Replacement
+	FilterStripURLs   FilterRuleKind = "strip_urls"   // Remove links, except allowed domains
+	FilterAllowDomain FilterRuleKind = "allow_domain" // Keep links to Pattern and its subdomains
+	FilterMinLength   FilterRuleKind = "min_length"   // Pattern is the minimum length in characters
+	FilterMaxLength   FilterRuleKind = "max_length"   // Pattern is the maximum length in characters
+)
+
+// FilterRuleKinds lists the kinds in display order.
+var FilterRuleKinds = []FilterRuleKind{FilterBanWord, FilterReplace, FilterStripURLs, FilterAllowDomain, FilterMinLength, FilterMaxLength}
+
+// Valid reports whether k is a known kind.
+func (k FilterRuleKind) Valid() bool {
+	for _, known := range FilterRuleKinds {
+		if k == known {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterRule is one entry of the text filter pipeline.
+type FilterRule struct {
+	ID          int            `json:"id"`
+	Kind        FilterRuleKind `json:"kind"`
+	Pattern     string         `json:"pattern"`
+	Replacement string         `json:"replacement,omitempty"`
+	CreatedBy   string         `json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
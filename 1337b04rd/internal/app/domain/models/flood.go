@@ -0,0 +1,44 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// FloodPolicy limits how often a single poster can submit content. Each
+// limit is applied per session and per client IP; zero disables it.
+type FloodPolicy struct {
+	PostInterval      time.Duration // Minimum gap between any two submissions
+	CommentsPerMinute int           // Maximum comments within one minute
+	ThreadCooldown    time.Duration // Minimum gap between two new threads
+}
+
+// DefaultFloodPolicy allows one submission every 10 seconds, 6 comments per
+// minute and one new thread every 2 minutes.
+var DefaultFloodPolicy = FloodPolicy{
+	PostInterval:      10 * time.Second,
+	CommentsPerMinute: 6,
+	ThreadCooldown:    2 * time.Minute,
+}
+
+// RateLimitError is returned when a flood limit is hit. It matches
+// ErrRateLimited with errors.Is.
+type RateLimitError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry in %ds", e.Reason, e.RetryAfterSeconds())
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds, as used by the
+// Retry-After header.
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
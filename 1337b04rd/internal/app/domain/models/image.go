@@ -0,0 +1,54 @@
+package models
+
+import "fmt"
+
+// ImageFormat is an image format accepted for upload.
+type ImageFormat string
+
+const (
+	ImageJPEG ImageFormat = "jpeg"
+	ImagePNG  ImageFormat = "png"
+	ImageGIF  ImageFormat = "gif"
+)
+
+// ImageFormats lists every format the upload pipeline can decode.
+var ImageFormats = []ImageFormat{ImageJPEG, ImagePNG, ImageGIF}
+
+// ParseImageFormat returns the format named s, as in ImageFormats.
+func ParseImageFormat(s string) (ImageFormat, error) {
+	for _, f := range ImageFormats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown image format %q: %w", s, ErrInvalidInput)
+}
+
+// ContentType is the MIME type images of this format are stored with.
+func (f ImageFormat) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Ext is the file extension of the format, with the dot.
+func (f ImageFormat) Ext() string {
+	if f == ImageJPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// ImageError is returned for an upload that is not an acceptable image. Err
+// is ErrUnsupportedMedia for files that are not images in an allowed format
+// and ErrInvalidInput for images that break the size limits.
+type ImageError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ImageError) Error() string {
+	return "image rejected: " + e.Reason
+}
+
+func (e *ImageError) Unwrap() error {
+	return e.Err
+}
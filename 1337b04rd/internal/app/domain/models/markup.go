@@ -0,0 +1,30 @@
+package models
+
+import "fmt"
+
+// QuoteTarget is a post or comment that a >>id quote can point to.
+type QuoteTarget struct {
+	ID       int
+	PostID   int  // Thread containing the target; equals ID for posts
+	IsPost   bool // Thread opener rather than a comment
+	Archived bool // The thread is in the archive
+}
+
+// Backlink is a comment that quotes a post or comment with >>id.
+type Backlink struct {
+	ID       int  `json:"id"`       // Quoting comment
+	PostID   int  `json:"post_id"`  // Thread of the quoting comment
+	Archived bool `json:"archived"` // That thread is in the archive
+}
+
+// URL links to the quoting comment from a page of thread currentPostID.
+func (b Backlink) URL(currentPostID int) string {
+	switch {
+	case b.PostID == currentPostID:
+		return fmt.Sprintf("#c%d", b.ID)
+	case b.Archived:
+		return fmt.Sprintf("/archived/post/%d#c%d", b.PostID, b.ID)
+	default:
+		return fmt.Sprintf("/post/%d#c%d", b.PostID, b.ID)
+	}
+}
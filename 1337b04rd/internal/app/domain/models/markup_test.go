@@ -0,0 +1,24 @@
+package models_test
+
+import (
+	"testing"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+func TestBacklinkURL(t *testing.T) {
+	tests := []struct {
+		link models.Backlink
+		want string
+	}{
+		{models.Backlink{ID: 12, PostID: 1}, "#c12"},
+		{models.Backlink{ID: 12, PostID: 1, Archived: true}, "#c12"},
+		{models.Backlink{ID: 25, PostID: 2}, "/post/2#c25"},
+		{models.Backlink{ID: 25, PostID: 2, Archived: true}, "/archived/post/2#c25"},
+	}
+	for _, tt := range tests {
+		if got := tt.link.URL(1); got != tt.want {
+			t.Errorf("%+v.URL(1) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// ModeratorRole grants access to moderation tools.
+type ModeratorRole string
+
+const (
+	RoleModerator ModeratorRole = "moderator"
+	RoleAdmin     ModeratorRole = "admin"
+)
+
+// Moderator is a staff account. Moderators log in with a name and password
+// and are tracked separately from anonymous user sessions.
+type Moderator struct {
+	ID           int           `json:"id"`
+	Name         string        `json:"name"`
+	Role         ModeratorRole `json:"role"`
+	PasswordHash string        `json:"-"`
+}
+
+// IsAdmin reports whether the moderator has the admin role.
+func (m *Moderator) IsAdmin() bool {
+	return m.Role == RoleAdmin
+}
+
+// ModActionType names a moderation action.
+type ModActionType string
+
+const (
+	ActionHidePost      ModActionType = "hide_post"
+	ActionUnhidePost    ModActionType = "unhide_post"
+	ActionDeletePost    ModActionType = "delete_post"
+	ActionLockPost      ModActionType = "lock_post"
+	ActionUnlockPost    ModActionType = "unlock_post"
+	ActionPinPost       ModActionType = "pin_post"
+	ActionUnpinPost     ModActionType = "unpin_post"
+	ActionDeleteComment ModActionType = "delete_comment"
+
+	// Image bans are logged like the actions above but are not applied
+	// through ModerationService.Act.
+	ActionBanImage   ModActionType = "ban_image"
+	ActionUnbanImage ModActionType = "unban_image"
+)
+
+// TargetType returns "post", "comment" or "image" depending on what the
+// action affects.
+func (a ModActionType) TargetType() string {
+	switch a {
+	case ActionDeleteComment:
+		return "comment"
+	case ActionBanImage, ActionUnbanImage:
+		return "image"
+	}
+	return "post"
+}
+
+// Valid reports whether a is an action on a post or comment.
+func (a ModActionType) Valid() bool {
+	switch a {
+	case ActionHidePost, ActionUnhidePost, ActionDeletePost, ActionLockPost,
+		ActionUnlockPost, ActionPinPost, ActionUnpinPost, ActionDeleteComment:
+		return true
+	}
+	return false
+}
+
+// ModAction is an entry of the moderation audit log.
+type ModAction struct {
+	ID            int           `json:"id"`
+	ModeratorID   int           `json:"moderator_id"`
+	ModeratorName string        `json:"moderator_name"`
+	Action        ModActionType `json:"action"`
+	TargetType    string        `json:"target_type"`
+	TargetID      int           `json:"target_id"`
+	ImageHash     string        `json:"image_hash,omitempty"` // Set by image bans, whose target is the post or comment the image came from, if any
+	Reason        string        `json:"reason"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// PostSort selects the column posts are ordered by. All orders are descending.
+type PostSort string
+
+const (
+	SortByBumpTime     PostSort = "bump"
+	SortByCreatedAt    PostSort = "created"
+	SortByCommentCount PostSort = "replies"
+	SortByArchivedAt   PostSort = "archived"
+)
+
+// ParsePostSort returns the sort named by s, or fallback if s is empty or unknown.
+func ParsePostSort(s string, fallback PostSort) PostSort {
+	switch PostSort(s) {
+	case SortByBumpTime, SortByCreatedAt, SortByCommentCount, SortByArchivedAt:
+		return PostSort(s)
+	default:
+		return fallback
+	}
+}
+
+// PageRequest describes one page of a keyset-paginated listing.
+type PageRequest struct {
+	Cursor string
+	Limit  int
+	Sort   PostSort
+}
+
+// Normalize clamps Limit to [1, MaxPageSize] and fills in the default sort.
+func (p PageRequest) Normalize(defaultSort PostSort) PageRequest {
+	if p.Limit <= 0 {
+		p.Limit = DefaultPageSize
+	}
+	if p.Limit > MaxPageSize {
+		p.Limit = MaxPageSize
+	}
+	if p.Sort == "" {
+		p.Sort = defaultSort
+	}
+	return p
+}
+
+// PostPage is one page of posts together with opaque cursors for its neighbours.
+// An empty cursor means there is no page in that direction.
+type PostPage struct {
+	Pinned     []*Post  `json:"pinned,omitempty"` // Only on the first page of the catalog
+	Posts      []*Post  `json:"posts"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	PrevCursor string   `json:"prev_cursor,omitempty"`
+	Sort       PostSort `json:"sort"`
+}
+
+// Cursor points at the boundary row of a page. Key is the value of the sort
+// column (Unix microseconds for timestamps, the count for comment counts) and
+// ID breaks ties. Sort is the order the page was listed in; a cursor only
+// makes sense in that order. Backward cursors fetch the page before the
+// boundary.
+type Cursor struct {
+	Sort     PostSort
+	Key      int64
+	ID       int
+	Backward bool
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c Cursor) Encode() string {
+	dir := "n"
+	if c.Backward {
+		dir = "p"
+	}
+	raw := fmt.Sprintf("%s:%s:%d:%d", dir, c.Sort, c.Key, c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("malformed cursor: %w", ErrInvalidInput)
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 4 || (parts[0] != "n" && parts[0] != "p") {
+		return Cursor{}, fmt.Errorf("malformed cursor: %w", ErrInvalidInput)
+	}
+
+	sort := ParsePostSort(parts[1], "")
+	if sort == "" {
+		return Cursor{}, fmt.Errorf("malformed cursor sort: %w", ErrInvalidInput)
+	}
+	key, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("malformed cursor key: %w", ErrInvalidInput)
+	}
+	id, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return Cursor{}, fmt.Errorf("malformed cursor id: %w", ErrInvalidInput)
+	}
+
+	return Cursor{Sort: sort, Key: key, ID: id, Backward: parts[0] == "p"}, nil
+}
+
+// DecodeCursor parses the cursor of the request, or returns nil if there is
+// none. A cursor from a listing in another order is rejected.
+func (p PageRequest) DecodeCursor() (*Cursor, error) {
+	if p.Cursor == "" {
+		return nil, nil
+	}
+	c, err := DecodeCursor(p.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if c.Sort != p.Sort {
+		return nil, fmt.Errorf("cursor is for sort %q, not %q: %w", c.Sort, p.Sort, ErrInvalidInput)
+	}
+	return &c, nil
+}
@@ -0,0 +1,81 @@
+package models_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	cursors := []models.Cursor{
+		{Sort: models.SortByBumpTime, Key: 1718000000123456, ID: 42},
+		{Sort: models.SortByCommentCount, Key: 0, ID: 1, Backward: true},
+		{Sort: models.SortByArchivedAt, Key: -5, ID: 7},
+	}
+	for _, c := range cursors {
+		got, err := models.DecodeCursor(c.Encode())
+		if err != nil {
+			t.Errorf("DecodeCursor(%+v) failed: %v", c, err)
+			continue
+		}
+		if got != c {
+			t.Errorf("round trip of %+v gave %+v", c, got)
+		}
+	}
+}
+
+func TestDecodeCursor_Malformed(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	inputs := map[string]string{
+		"not base64":        "!!!",
+		"old format":        encode("n:123:4"),
+		"unknown direction": encode("x:bump:123:4"),
+		"unknown sort":      encode("n:random:123:4"),
+		"bad key":           encode("n:bump:abc:4"),
+		"bad id":            encode("p:bump:123:"),
+	}
+	for name, s := range inputs {
+		if _, err := models.DecodeCursor(s); !errors.Is(err, models.ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
+
+func TestPageRequest_DecodeCursor(t *testing.T) {
+	cursor := models.Cursor{Sort: models.SortByCreatedAt, Key: 10, ID: 3}.Encode()
+
+	page := models.PageRequest{Cursor: cursor, Sort: models.SortByCreatedAt}
+	if c, err := page.DecodeCursor(); err != nil || c == nil || c.ID != 3 {
+		t.Errorf("DecodeCursor() = %+v, %v", c, err)
+	}
+
+	// A page listed by bump time cannot continue in creation order
+	page.Sort = models.SortByBumpTime
+	if _, err := page.DecodeCursor(); !errors.Is(err, models.ErrInvalidInput) {
+		t.Errorf("cursor of another sort: error = %v, want ErrInvalidInput", err)
+	}
+
+	if c, err := (models.PageRequest{Sort: models.SortByBumpTime}).DecodeCursor(); c != nil || err != nil {
+		t.Errorf("no cursor: DecodeCursor() = %+v, %v", c, err)
+	}
+}
+
+func TestPageRequest_Normalize(t *testing.T) {
+	tests := []struct {
+		in   models.PageRequest
+		want models.PageRequest
+	}{
+		{models.PageRequest{}, models.PageRequest{Limit: models.DefaultPageSize, Sort: models.SortByBumpTime}},
+		{models.PageRequest{Limit: 1000, Sort: models.SortByCreatedAt}, models.PageRequest{Limit: models.MaxPageSize, Sort: models.SortByCreatedAt}},
+		{models.PageRequest{Limit: 5}, models.PageRequest{Limit: 5, Sort: models.SortByBumpTime}},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Normalize(models.SortByBumpTime); got != tt.want {
+			t.Errorf("Normalize(%+v) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
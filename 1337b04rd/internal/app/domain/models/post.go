@@ -6,15 +6,60 @@ import (
 
 // models/post.go
 type Post struct {
-	ID         int       `json:"id"`
-	Title      string    `json:"title"`
-	Text       string    `json:"text"`
-	UserName   string    `json:"user_name"`
-	UserAvatar string    `json:"user_avatar"`
-	ImageURL   string    `json:"image_url"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	ArchivedAt *time.Time
-	IsHidden   bool `json:"is_hidden"`
-	Comments   []*Comment
+	ID           int          `json:"id"`
+	Board        string       `json:"board"` // Slug of the board the thread belongs to
+	Title        string       `json:"title"`
+	Text         string       `json:"text"`
+	TextHTML     string       `json:"text_html,omitempty"` // Text rendered by the markup renderer
+	UserName     string       `json:"user_name"`
+	Tripcode     string       `json:"tripcode,omitempty"`  // "!xxx" or "!!xxx" when posted as name#password
+	PosterID     string       `json:"poster_id,omitempty"` // Per-thread hash of the session
+	UserAvatar   string       `json:"user_avatar"`
+	ImageURL     string       `json:"image_url"`               // First attachment, kept for catalogs and older clients
+	ThumbnailURL string       `json:"thumbnail_url,omitempty"` // Scaled-down copy of the image, if one was made
+	ImageName    string       `json:"image_name,omitempty"`    // File name the image was uploaded with
+	Attachments  []Attachment `json:"attachments,omitempty"`   // Every attached image, in order
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	BumpedAt     time.Time    `json:"bumped_at"` // Last reply that bumped the thread
+	ArchivedAt   *time.Time   `json:"archived_at,omitempty"`
+	IsHidden     bool         `json:"is_hidden"`
+	IsLocked     bool         `json:"is_locked"` // No new replies allowed
+	IsPinned     bool         `json:"is_pinned"` // Shown above other threads, never archived
+	ReplyCount   int          `json:"reply_count"`
+	ImageCount   int          `json:"image_count"`      // Images in the thread, those of the opening post included
+	Teaser       string       `json:"teaser,omitempty"` // Plain-text start of Text for catalog views
+	Comments     []*Comment   `json:"comments,omitempty"`
+	Backlinks    []Backlink   `json:"backlinks,omitempty"` // Comments quoting this post
+
+	Fingerprint `json:"-"` // Stored for duplicate detection only
+}
+
+// SetAttachments sets the attachments of a new post and mirrors the first
+// one into ImageURL, ThumbnailURL, ImageName and ImageHash.
+func (p *Post) SetAttachments(attachments []Attachment) {
+	p.Attachments = attachments
+	p.ImageURL, p.ThumbnailURL, p.ImageName, p.ImageHash = "", "", "", ""
+	if len(attachments) > 0 {
+		first := attachments[0]
+		p.ImageURL, p.ThumbnailURL, p.ImageName, p.ImageHash = first.URL, first.ThumbnailURL, first.Name, first.Hash
+	}
+}
+
+// Images returns the attachments to show, falling back to ImageURL for
+// posts made before attachments were stored separately.
+func (p *Post) Images() []Attachment {
+	if len(p.Attachments) > 0 {
+		return p.Attachments
+	}
+	return legacyAttachments(p.ImageURL, p.ThumbnailURL, p.ImageName)
+}
+
+// Thumbnail returns the URL to show in place of the image: its thumbnail, or
+// the image itself when it has none.
+func (p *Post) Thumbnail() string {
+	if p.ThumbnailURL != "" {
+		return p.ThumbnailURL
+	}
+	return p.ImageURL
 }
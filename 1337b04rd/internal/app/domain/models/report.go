@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// ReportCategory is the reason class a user picks when reporting content.
+type ReportCategory string
+
+const (
+	ReportSpam     ReportCategory = "spam"
+	ReportAbuse    ReportCategory = "abuse"
+	ReportIllegal  ReportCategory = "illegal"
+	ReportOffTopic ReportCategory = "off_topic"
+	ReportOther    ReportCategory = "other"
+)
+
+// ReportCategories lists the categories in display order.
+var ReportCategories = []ReportCategory{ReportSpam, ReportAbuse, ReportIllegal, ReportOffTopic, ReportOther}
+
+// Valid reports whether c is a known category.
+func (c ReportCategory) Valid() bool {
+	for _, known := range ReportCategories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportStatus tracks whether a report still needs attention.
+type ReportStatus string
+
+const (
+	ReportOpen      ReportStatus = "open"
+	ReportDismissed ReportStatus = "dismissed"
+	ReportActioned  ReportStatus = "actioned"
+)
+
+// Report flags a post or comment for moderator review.
+type Report struct {
+	ID         int            `json:"id"`
+	TargetType string         `json:"target_type"` // post | comment
+	TargetID   int            `json:"target_id"`
+	PostID     int            `json:"post_id"` // Thread containing the target
+	SessionID  string         `json:"-"`
+	Category   ReportCategory `json:"category"`
+	Text       string         `json:"text"`
+	Status     ReportStatus   `json:"status"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// ReportGroup collects the open reports against a single target.
+type ReportGroup struct {
+	TargetType     string           `json:"target_type"`
+	TargetID       int              `json:"target_id"`
+	PostID         int              `json:"post_id"`
+	Count          int              `json:"count"`
+	Categories     []ReportCategory `json:"categories"`
+	FirstReportAt  time.Time        `json:"first_report_at"`
+	LatestReportAt time.Time        `json:"latest_report_at"`
+	Reports        []*Report        `json:"reports"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxSearchQueryLength limits the search text, in characters.
+const MaxSearchQueryLength = 200
+
+// MaxSearchOffset is the last result offset a search can page to. Every
+// page has to rank all matches up to its offset.
+const MaxSearchOffset = 1000
+
+// Snippets returned by a SearchRepository mark matched words with these
+// runes from the Unicode private use area, which real text hardly ever
+// contains. They are turned into <mark> tags after escaping.
+const (
+	HighlightStart = "\ue000"
+	HighlightStop  = "\ue001"
+)
+
+// SearchScope selects live threads, archived threads or both.
+type SearchScope string
+
+const (
+	SearchAll      SearchScope = "all"
+	SearchLive     SearchScope = "live"
+	SearchArchived SearchScope = "archived"
+)
+
+// ParseSearchScope returns the scope named by s. An empty s means SearchAll.
+func ParseSearchScope(s string) (SearchScope, error) {
+	switch SearchScope(s) {
+	case "":
+		return SearchAll, nil
+	case SearchAll, SearchLive, SearchArchived:
+		return SearchScope(s), nil
+	default:
+		return "", fmt.Errorf("unknown search scope %q: %w", s, ErrInvalidInput)
+	}
+}
+
+// SearchQuery is a full-text query over thread titles, thread text and
+// comments, with optional filters.
+type SearchQuery struct {
+	Text     string
+	Board    string      // Empty searches every board
+	Scope    SearchScope // Threads are live or archived as a whole, comments follow their thread
+	From     time.Time   // Matches created at or after From; zero means no lower bound
+	To       time.Time   // Matches created before To; zero means no upper bound
+	HasImage bool        // Only posts and comments with an image
+	Limit    int
+	Offset   int
+}
+
+// SearchResult is one matching thread or comment.
+type SearchResult struct {
+	PostID      int       `json:"post_id"`
+	CommentID   int       `json:"comment_id,omitempty"` // 0 when the thread itself matched
+	Board       string    `json:"board"`
+	Title       string    `json:"title"`        // Title of the thread
+	Snippet     string    `json:"-"`            // Matched text with Highlight marks, not escaped
+	SnippetHTML string    `json:"snippet_html"` // Escaped snippet with <mark> around matches
+	Rank        float64   `json:"rank"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Archived    bool      `json:"archived"`
+}
+
+// URL links to the matched thread or comment.
+func (r SearchResult) URL() string {
+	url := fmt.Sprintf("/post/%d", r.PostID)
+	if r.Archived {
+		url = fmt.Sprintf("/archived/post/%d", r.PostID)
+	}
+	if r.CommentID != 0 {
+		url += fmt.Sprintf("#c%d", r.CommentID)
+	}
+	return url
+}
+
+// SearchPage is one page of search results, best match first.
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextOffset int            `json:"next_offset,omitempty"` // 0 when there are no more results
+}
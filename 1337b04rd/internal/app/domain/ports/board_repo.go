@@ -0,0 +1,17 @@
+package ports
+
+import "1337b04rd/internal/app/domain/models"
+
+// BoardRepository stores boards and their settings.
+type BoardRepository interface {
+	GetBoards() ([]*models.Board, error)
+	// GetBoard returns nil without an error if no board has that slug.
+	GetBoard(slug string) (*models.Board, error)
+	// GetBoardByPostID returns the board of a thread, or nil if the thread
+	// does not exist.
+	GetBoardByPostID(postID int) (*models.Board, error)
+	// SaveBoard creates the board or updates the one with the same slug.
+	SaveBoard(board *models.Board) error
+	// DeleteBoard removes an empty board.
+	DeleteBoard(slug string) error
+}
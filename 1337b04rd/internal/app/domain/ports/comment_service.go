@@ -4,4 +4,5 @@ import "1337b04rd/internal/app/domain/models"
 
 type CommentService interface {
 	GetCommentsByPostID(postID int) ([]*models.Comment, error)
+	GetCommentsView(postID int, opts models.CommentViewOptions) ([]*models.Comment, error)
 }
@@ -0,0 +1,26 @@
+package ports
+
+import (
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// ContentRepository looks up content fingerprints and banned images.
+type ContentRepository interface {
+	// HasRecentText reports whether a post or comment with the given text
+	// hash was created at or after since.
+	HasRecentText(textHash string, since time.Time) (bool, error)
+	// HasRecentImage is HasRecentText for image hashes.
+	HasRecentImage(imageHash string, since time.Time) (bool, error)
+	// GetImageHash returns the hash of the first image of a post or comment,
+	// or "" if it has none. A missing target returns ErrNotFound.
+	GetImageHash(targetType string, targetID int) (string, error)
+
+	IsImageBanned(imageHash string) (bool, error)
+	// BanImage and UnbanImage change the ban list and record action in the
+	// moderation audit log together.
+	BanImage(ban *models.BannedImage, action *models.ModAction) error
+	UnbanImage(imageHash string, action *models.ModAction) error
+	GetBannedImages(limit int) ([]*models.BannedImage, error)
+}
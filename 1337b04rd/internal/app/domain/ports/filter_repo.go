@@ -0,0 +1,10 @@
+package ports
+
+import "1337b04rd/internal/app/domain/models"
+
+// FilterRuleRepository stores the rules of the text filter pipeline.
+type FilterRuleRepository interface {
+	GetFilterRules() ([]*models.FilterRule, error)
+	CreateFilterRule(rule *models.FilterRule) error
+	DeleteFilterRule(id int) error
+}
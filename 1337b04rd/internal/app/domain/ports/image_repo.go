@@ -0,0 +1,28 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// ImageRepository keeps the queue of stored images waiting to be deleted.
+// Image keys are content hashes, so an object one message gave up may be
+// stored again for another at any time; the queue is how the two meet.
+type ImageRepository interface {
+	// ScheduleDeletions queues urls for deletion at due. URLs already queued
+	// keep the later of the two times.
+	ScheduleDeletions(ctx context.Context, urls []string, due time.Time) error
+	// CancelDeletions takes urls off the queue. If a sweep is deleting one
+	// of them, it waits until the sweep is done, so that an object stored
+	// after it returns is not deleted.
+	CancelDeletions(ctx context.Context, urls []string) error
+	// SweepDeletions calls remove for up to limit queued URLs that are due
+	// at now and that no post, comment or attachment refers to, and takes
+	// those removed off the queue. URLs that are in use again are taken off
+	// without being removed. Returns how many URLs were removed.
+	SweepDeletions(ctx context.Context, now time.Time, limit int, remove func(ctx context.Context, url string) error) (int, error)
+	// LockImageMigration keeps other processes from migrating image keys
+	// until unlock is called. If another process holds the lock,
+	// ErrConflict is returned.
+	LockImageMigration(ctx context.Context) (unlock func(), err error)
+}
@@ -0,0 +1,24 @@
+package ports
+
+import (
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// ModeratorRepository stores moderator accounts and their login sessions.
+type ModeratorRepository interface {
+	GetModeratorByName(name string) (*models.Moderator, error)
+	UpsertModerator(moderator *models.Moderator) error
+	CreateModSession(token string, moderatorID int, expiresAt time.Time) error
+	GetModeratorBySession(token string, now time.Time) (*models.Moderator, error)
+	DeleteModSession(token string) error
+}
+
+// ModerationRepository applies moderation actions to posts and comments.
+type ModerationRepository interface {
+	// ApplyAction performs the action and records it in the audit log in a
+	// single transaction.
+	ApplyAction(action *models.ModAction) error
+	GetAuditLog(limit int) ([]*models.ModAction, error)
+}
@@ -1,14 +1,41 @@
 // /internal/app/domain/ports/post_repository.go
 package ports
 
-import "1337b04rd/internal/app/domain/models"
+import (
+	"context"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+)
 
 // Интерфейс репозитория для работы с постами
 type PostRepository interface {
-	CreatePost(post *models.Post) (*models.Post, error)
-	GetAllPosts() ([]*models.Post, error)
+	// CreatePost inserts a thread. With maxThreads > 0 it also archives, in the
+	// same transaction, the least recently bumped threads past that cap.
+	CreatePost(post *models.Post, maxThreads int) (*models.Post, error)
+	// NextPostID reserves an ID for a post that needs it before the insert.
+	// CreatePost uses post.ID when it is set.
+	NextPostID() (int, error)
+	GetAllPosts(board string, page models.PageRequest) (*models.PostPage, error)
 	GetPostByID(id string) (*models.Post, error)
 	ArchivePost(postID int) error
-	GetArchivedPosts() ([]*models.Post, error)
+	// ArchiveExpiredPosts archives all live posts of a board whose TTL under
+	// policy has run out at now and returns how many were archived.
+	ArchiveExpiredPosts(ctx context.Context, board string, now time.Time, policy models.ArchivePolicy) (int, error)
+	// PruneThreads archives the least recently bumped unpinned, visible live
+	// threads of a board beyond the first maxThreads and returns how many were
+	// archived.
+	PruneThreads(ctx context.Context, board string, maxThreads int, now time.Time) (int, error)
+	GetArchivedPosts(board string, page models.PageRequest) (*models.PostPage, error)
 	GetArchivedPostByID(id string) (*models.Post, error)
+	// PurgeArchivedPosts deletes the threads archived before archivedBefore
+	// with everything attached to them. With dryRun nothing is deleted, but the
+	// summary still lists what would be.
+	PurgeArchivedPosts(ctx context.Context, archivedBefore time.Time, dryRun bool) (*models.PurgeSummary, error)
+	// LegacyImageURLs lists the image URLs of posts and comments whose
+	// object keys are client file names rather than content hashes.
+	LegacyImageURLs(ctx context.Context) ([]string, error)
+	// ReplaceImageURL moves every post and comment from oldURL to image and
+	// returns the thumbnail URLs they used to have.
+	ReplaceImageURL(ctx context.Context, oldURL string, image *models.UploadedImage) ([]string, error)
 }
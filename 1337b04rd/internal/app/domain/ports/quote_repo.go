@@ -0,0 +1,10 @@
+package ports
+
+import "1337b04rd/internal/app/domain/models"
+
+// QuoteRepository resolves >>id quotes that point outside the current thread.
+type QuoteRepository interface {
+	// ResolveQuotes returns every visible post and comment whose ID is in
+	// ids. Both a post and a comment may be returned for the same ID.
+	ResolveQuotes(ids []int) ([]models.QuoteTarget, error)
+}
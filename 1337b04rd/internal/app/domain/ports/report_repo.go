@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+type ReportRepository interface {
+	// CreateReport stores a report and fills in its ID and PostID. It fails
+	// with models.ErrNotFound if the target does not exist, and with
+	// models.ErrRateLimited if the session has filed rateLimit reports since
+	// since; 0 disables the limit. Reports of one session are counted and
+	// inserted one at a time, so concurrent requests cannot exceed the limit.
+	CreateReport(report *models.Report, rateLimit int, since time.Time) error
+	GetOpenReportGroups(limit int) ([]*models.ReportGroup, error)
+	// ResolveReports closes all open reports against a target and returns
+	// how many were closed.
+	ResolveReports(targetType string, targetID int, status models.ReportStatus, moderatorID int, at time.Time) (int, error)
+}
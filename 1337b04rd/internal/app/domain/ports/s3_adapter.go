@@ -1,7 +1,29 @@
 package ports
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+
+	"1337b04rd/internal/app/domain/models"
+)
 
 type S3Adapter interface {
-	UploadImage(r *http.Request, imageType string) (string, error)
+	// UploadImages reads the multipart form of r, validating and storing
+	// the "image" files as they stream in, and returns their public URLs,
+	// formats and content hashes in form order, or nil if the request has
+	// no image. The other fields are left for r.FormValue. If any file is
+	// not an acceptable image, or there are too many, nothing is stored and
+	// a *models.ImageError is returned. check, unless nil, sees the whole
+	// batch, and may refuse it, after the form is read and before any image
+	// is stored under its URL; r.FormValue already works then. If storing fails part way,
+	// the images that may have been stored are returned with the error.
+	UploadImages(r *http.Request, imageType string, check func([]*models.UploadedImage) error) ([]*models.UploadedImage, error)
+	// RekeyImage copies an image stored under its client file name to a
+	// content-addressed key and returns the new URLs. The old object stays.
+	// check, unless nil, sees the new image before it is stored, as in
+	// UploadImages.
+	RekeyImage(ctx context.Context, imageURL string, check func(*models.UploadedImage) error) (*models.UploadedImage, error)
+	// DeleteImage removes the object behind a URL returned by UploadImages.
+	// An object that is already gone is not an error.
+	DeleteImage(ctx context.Context, imageURL string) error
 }
@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// SearchRepository runs full-text queries over threads and comments.
+type SearchRepository interface {
+	// Search returns at most query.Limit results after skipping query.Offset,
+	// ordered by rank, then newest first. Hidden threads and their comments
+	// are never returned. Snippets mark matches with models.HighlightStart
+	// and models.HighlightStop.
+	Search(ctx context.Context, query models.SearchQuery) ([]models.SearchResult, error)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// BoardService looks up boards and lets admins manage them.
+type BoardService struct {
+	BoardRepo   ports.BoardRepository
+	DefaultSlug string // Board used by the routes that predate boards
+}
+
+// NewBoardService creates a new instance of BoardService.
+func NewBoardService(repo ports.BoardRepository, defaultSlug string) *BoardService {
+	return &BoardService{BoardRepo: repo, DefaultSlug: defaultSlug}
+}
+
+// GetBoards returns all boards.
+func (s *BoardService) GetBoards() ([]*models.Board, error) {
+	return s.BoardRepo.GetBoards()
+}
+
+// GetBoard returns the board with the given slug; an empty slug means the
+// default board.
+func (s *BoardService) GetBoard(slug string) (*models.Board, error) {
+	if slug == "" {
+		slug = s.DefaultSlug
+	}
+	board, err := s.BoardRepo.GetBoard(slug)
+	if err != nil {
+		return nil, err
+	}
+	if board == nil {
+		return nil, fmt.Errorf("board /%s/ does not exist: %w", slug, models.ErrNotFound)
+	}
+	return board, nil
+}
+
+// GetBoardForPost returns the board of a thread.
+func (s *BoardService) GetBoardForPost(postID int) (*models.Board, error) {
+	board, err := s.BoardRepo.GetBoardByPostID(postID)
+	if err != nil {
+		return nil, err
+	}
+	if board == nil {
+		return nil, fmt.Errorf("post with id %d does not exist: %w", postID, models.ErrNotFound)
+	}
+	return board, nil
+}
+
+// SaveBoard validates and stores a board. Only admins may create or change boards.
+func (s *BoardService) SaveBoard(moderator *models.Moderator, board *models.Board) error {
+	if err := requireAdmin(moderator); err != nil {
+		return err
+	}
+	board.Slug = strings.ToLower(strings.TrimSpace(board.Slug))
+	board.Title = strings.TrimSpace(board.Title)
+	board.Description = strings.TrimSpace(board.Description)
+	if err := board.Validate(); err != nil {
+		return err
+	}
+	if board.CreatedAt.IsZero() {
+		board.CreatedAt = time.Now()
+	}
+	if err := s.BoardRepo.SaveBoard(board); err != nil {
+		return err
+	}
+	slog.Info("Board saved", "slug", board.Slug, "moderator", moderator.Name)
+	return nil
+}
+
+// DeleteBoard removes a board without threads. The default board cannot be deleted.
+func (s *BoardService) DeleteBoard(moderator *models.Moderator, slug string) error {
+	if err := requireAdmin(moderator); err != nil {
+		return err
+	}
+	if slug == s.DefaultSlug {
+		return fmt.Errorf("the default board cannot be deleted: %w", models.ErrConflict)
+	}
+	if err := s.BoardRepo.DeleteBoard(slug); err != nil {
+		return err
+	}
+	slog.Info("Board deleted", "slug", slug, "moderator", moderator.Name)
+	return nil
+}
+
+func requireAdmin(moderator *models.Moderator) error {
+	if moderator == nil {
+		return fmt.Errorf("moderator required: %w", models.ErrUnauthorized)
+	}
+	if !moderator.IsAdmin() {
+		return fmt.Errorf("admin role required: %w", models.ErrForbidden)
+	}
+	return nil
+}
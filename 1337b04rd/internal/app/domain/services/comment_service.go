@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"strconv"
 	"time"
@@ -10,41 +11,130 @@ import (
 	"1337b04rd/internal/app/domain/ports"
 )
 
+// DefaultBumpLimit is the number of replies after which a thread stops bumping.
+const DefaultBumpLimit = 300
+
+// maxQuotedIDs caps the >>id references stored for one comment.
+const maxQuotedIDs = 50
+
 // CommentService provides methods to work with comments.
 type CommentService struct {
 	CommentRepo ports.CommentRepository
+	BumpLimit   int               // 0 disables the limit
+	Duplicates  *DuplicateService // nil disables duplicate detection
+	Filters     *FilterService    // nil stores text as submitted
+	Tripcodes   *Tripcoder        // nil ignores NameField
+	PosterIDs   *PosterIDs        // Used on boards with poster IDs enabled
+	Boards      *BoardService     // nil skips board rules and poster IDs
 }
 
 // NewCommentService creates a new instance of CommentService.
 func NewCommentService(repo ports.CommentRepository) *CommentService {
 	return &CommentService{
 		CommentRepo: repo,
+		BumpLimit:   DefaultBumpLimit,
 	}
 }
 
-// CreateComment validates and creates a new comment.
+// CreateComment validates and creates a new comment. Attachments are
+// expected to be set by the caller with SetAttachments, and UserName to
+// the session character, which NameField overrides when given. SessionID
+// is needed for the poster ID.
 func (s *CommentService) CreateComment(comment models.Comment) (*models.Comment, error) {
 	if comment.PostID == 0 || comment.UserName == "" || comment.Text == "" {
 		slog.Warn("Missing required fields in comment creation", "PostID", comment.PostID, "UserName", comment.UserName, "Text", comment.Text)
-		return nil, errors.New("missing required fields (PostID, UserName, Text)")
+		return nil, fmt.Errorf("missing required fields (PostID, UserName, Text): %w", models.ErrInvalidInput)
 	}
 
 	if comment.CreatedAt.IsZero() {
 		comment.CreatedAt = time.Now()
 	}
 
-	createdComment, err := s.CommentRepo.CreateComment(comment)
+	if s.Boards != nil {
+		board, err := s.Boards.GetBoardForPost(comment.PostID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range comment.Attachments {
+			if err := board.CheckImage(comment.Attachments[i].Format()); err != nil {
+				return nil, err
+			}
+		}
+		if s.PosterIDs != nil && board.PosterIDs && comment.SessionID != "" {
+			comment.PosterID = s.PosterIDs.For(comment.SessionID, comment.PostID)
+		}
+	}
+
+	if s.Tripcodes != nil && comment.NameField != "" {
+		var err error
+		if comment.UserName, comment.Tripcode, err = s.Tripcodes.Identity(comment.NameField, comment.UserName); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Filters != nil {
+		var err error
+		if comment.Text, err = s.Filters.Apply(comment.Text); err != nil {
+			return nil, err
+		}
+	}
+
+	comment.QuotedIDs = ExtractQuoteIDs(comment.Text)
+	if len(comment.QuotedIDs) > maxQuotedIDs {
+		comment.QuotedIDs = comment.QuotedIDs[:maxQuotedIDs]
+	}
+
+	flagged := false
+	if s.Duplicates != nil {
+		comment.Fingerprint = s.Duplicates.Fingerprint(comment.Text, comment.Attachments)
+		var err error
+		if flagged, err = s.Duplicates.Check(comment.Fingerprint); err != nil {
+			return nil, err
+		}
+	}
+
+	createdComment, err := s.CommentRepo.CreateComment(comment, s.BumpLimit)
 	if err != nil {
 		slog.Error("Failed to create comment", "error", err)
 		return nil, err
 	}
+	if flagged {
+		s.Duplicates.Flag("comment", createdComment.ID)
+	}
 
 	slog.Info("Comment created successfully", "CommentID", createdComment.ID)
 	return createdComment, nil
 }
 
-// GetCommentsByPostID returns all comments associated with a specific post ID.
+// CheckImages applies the image rules of the board of thread postID, bans
+// and duplicate detection to the images of a new reply, so that uploads it
+// would refuse are not stored. CreateComment applies them again.
+func (s *CommentService) CheckImages(postID int, images []*models.UploadedImage) error {
+	if len(images) == 0 {
+		return nil
+	}
+	if s.Boards != nil {
+		board, err := s.Boards.GetBoardForPost(postID)
+		if err != nil {
+			return err
+		}
+		if err := checkImages(board, images); err != nil {
+			return err
+		}
+	}
+	if s.Duplicates != nil {
+		return s.Duplicates.CheckImages(images)
+	}
+	return nil
+}
+
+// GetCommentsByPostID returns the comment tree of a post with unlimited depth.
 func (s *CommentService) GetCommentsByPostID(postID int) ([]*models.Comment, error) {
+	return s.GetCommentsView(postID, models.CommentViewOptions{Mode: models.CommentViewTree})
+}
+
+// GetCommentsView returns the comments of a post arranged according to opts.
+func (s *CommentService) GetCommentsView(postID int, opts models.CommentViewOptions) ([]*models.Comment, error) {
 	if postID == 0 {
 		slog.Warn("Invalid post ID provided for comment retrieval")
 		return nil, errors.New("invalid PostID")
@@ -57,7 +147,7 @@ func (s *CommentService) GetCommentsByPostID(postID int) ([]*models.Comment, err
 	}
 
 	slog.Info("Comments retrieved successfully", "PostID", postID, "Count", len(comments))
-	return comments, nil
+	return BuildCommentView(comments, opts), nil
 }
 
 // DeleteComment deletes a comment by its ID.
@@ -0,0 +1,78 @@
+package services_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+func TestCreateComment_QuotedIDs(t *testing.T) {
+	comments := &fakeComments{}
+	service := services.NewCommentService(comments)
+
+	_, err := service.CreateComment(models.Comment{PostID: 1, UserName: "Rick", Text: ">>7 see >>3\n```\n>>9\n```\n>>7 again"})
+	if err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+	if got, want := comments.created[0].QuotedIDs, []int{7, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("QuotedIDs = %v, want %v", got, want)
+	}
+
+	// A wall of quotes stores only the first fifty
+	var text strings.Builder
+	for id := 1; id <= 80; id++ {
+		fmt.Fprintf(&text, ">>%d ", id)
+	}
+	if _, err := service.CreateComment(models.Comment{PostID: 1, UserName: "Rick", Text: text.String()}); err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+	if ids := comments.created[1].QuotedIDs; len(ids) != 50 || ids[0] != 1 || ids[49] != 50 {
+		t.Errorf("stored %d quoted IDs, want the first 50", len(ids))
+	}
+}
+
+func TestCreateComment_BoardRules(t *testing.T) {
+	boards := newFakeBoards(&models.Board{Slug: "text", TextOnly: true}, &models.Board{Slug: "jpg", ImageFormats: []models.ImageFormat{models.ImageJPEG}})
+	boards.postBoards[1] = "text"
+	boards.postBoards[2] = "jpg"
+	comments := &fakeComments{}
+	service := services.NewCommentService(comments)
+	service.Boards = services.NewBoardService(boards, "b")
+	png := []*models.UploadedImage{{URL: "/img/a.png", Format: models.ImagePNG}}
+
+	tests := []struct {
+		name   string
+		postID int
+		images []*models.UploadedImage
+		want   error
+	}{
+		{"image on a text-only board", 1, png, models.ErrInvalidInput},
+		{"text on a text-only board", 1, nil, nil},
+		{"format the board excludes", 2, png, models.ErrUnsupportedMedia},
+		{"any format on /b/", 3, png, nil},
+	}
+	for _, tt := range tests {
+		if err := service.CheckImages(tt.postID, tt.images); !errors.Is(err, tt.want) {
+			t.Errorf("%s: CheckImages error = %v, want %v", tt.name, err, tt.want)
+		}
+		comment := models.Comment{PostID: tt.postID, UserName: "Rick", Text: "reply"}
+		comment.SetAttachments(models.NewAttachments(tt.images))
+		if _, err := service.CreateComment(comment); !errors.Is(err, tt.want) {
+			t.Errorf("%s: CreateComment error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if len(comments.created) != 2 {
+		t.Errorf("%d comments stored, want the 2 allowed", len(comments.created))
+	}
+
+	// Without boards there are no board rules
+	service.Boards = nil
+	if err := service.CheckImages(1, png); err != nil {
+		t.Errorf("CheckImages without boards: error = %v, want none", err)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import "1337b04rd/internal/app/domain/models"
+
+// BuildCommentView arranges a thread's comments, given in chronological
+// order, according to opts. In the tree view it returns the top-level
+// comments with their replies nested to any depth; in the flat view it
+// returns every comment in order with no nesting.
+func BuildCommentView(comments []*models.Comment, opts models.CommentViewOptions) []*models.Comment {
+	for _, c := range comments {
+		c.Replies = nil
+		c.Depth = 0
+		c.HiddenReplies = 0
+	}
+	if opts.Mode == models.CommentViewFlat {
+		return comments
+	}
+
+	byID := make(map[int]*models.Comment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+
+	// parentOf returns the comment c replies to, or nil for top-level
+	// comments and replies whose parent is not part of this thread.
+	parentOf := func(c *models.Comment) *models.Comment {
+		if c.ParentCommentID == nil {
+			return nil
+		}
+		return byID[*c.ParentCommentID]
+	}
+
+	// Depths are computed up front so that replies stored before their
+	// parent still land in the right place.
+	depths := make(map[int]int, len(comments))
+	var depthOf func(c *models.Comment, guard int) int
+	depthOf = func(c *models.Comment, guard int) int {
+		if d, ok := depths[c.ID]; ok {
+			return d
+		}
+		parent := parentOf(c)
+		d := 0
+		if parent != nil && guard < len(comments) {
+			d = depthOf(parent, guard+1) + 1
+		}
+		depths[c.ID] = d
+		return d
+	}
+
+	var roots []*models.Comment
+	for _, c := range comments {
+		depth := depthOf(c, 0)
+		parent := parentOf(c)
+		if parent == nil || depth == 0 {
+			roots = append(roots, c)
+			continue
+		}
+
+		// Attach replies past MaxDepth to their deepest allowed ancestor
+		if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+			for parent != nil && depths[parent.ID] > opts.MaxDepth-1 {
+				parent = parentOf(parent)
+			}
+			depth = opts.MaxDepth
+		}
+		if parent == nil {
+			roots = append(roots, c)
+			continue
+		}
+
+		c.Depth = depth
+		parent.Replies = append(parent.Replies, c)
+	}
+
+	if opts.CollapseAfter > 0 {
+		for _, c := range comments {
+			if len(c.Replies) > opts.CollapseAfter {
+				c.HiddenReplies = len(c.Replies) - opts.CollapseAfter
+				c.Replies = c.Replies[:opts.CollapseAfter]
+			}
+		}
+	}
+
+	return roots
+}
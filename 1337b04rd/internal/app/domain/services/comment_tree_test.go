@@ -0,0 +1,123 @@
+package services_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+// thread builds comments from "id" or "id>parent" specs, in the given order.
+func thread(specs ...string) []*models.Comment {
+	var comments []*models.Comment
+	for _, spec := range specs {
+		var id, parent int
+		c := &models.Comment{}
+		if n, _ := fmt.Sscanf(spec, "%d>%d", &id, &parent); n == 2 {
+			c.ParentCommentID = &parent
+		}
+		c.ID = id
+		comments = append(comments, c)
+	}
+	return comments
+}
+
+// shape renders a view as "id[depth](replies) ..." with "+n" for hidden
+// replies, e.g. "1[0](2[1] 3[1]) 4[0]".
+func shape(comments []*models.Comment) string {
+	var parts []string
+	for _, c := range comments {
+		s := fmt.Sprintf("%d[%d]", c.ID, c.Depth)
+		if len(c.Replies) > 0 {
+			s += "(" + shape(c.Replies) + ")"
+		}
+		if c.HiddenReplies > 0 {
+			s += fmt.Sprintf("+%d", c.HiddenReplies)
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestBuildCommentView(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []*models.Comment
+		opts     models.CommentViewOptions
+		want     string
+	}{
+		{
+			name:     "tree",
+			comments: thread("1", "2>1", "3", "4>2", "5>1"),
+			want:     "1[0](2[1](4[2]) 5[1]) 3[0]",
+		},
+		{
+			name:     "flat",
+			comments: thread("1", "2>1", "3", "4>2"),
+			opts:     models.CommentViewOptions{Mode: models.CommentViewFlat},
+			want:     "1[0] 2[0] 3[0] 4[0]",
+		},
+		{
+			name:     "depth capped",
+			comments: thread("1", "2>1", "3>2", "4>3", "5>4"),
+			opts:     models.CommentViewOptions{MaxDepth: 2},
+			want:     "1[0](2[1](3[2] 4[2] 5[2]))",
+		},
+		{
+			name:     "depth 1 keeps every reply under its top-level comment",
+			comments: thread("1", "2>1", "3>2", "4", "5>4", "6>5"),
+			opts:     models.CommentViewOptions{MaxDepth: 1},
+			want:     "1[0](2[1] 3[1]) 4[0](5[1] 6[1])",
+		},
+		{
+			name:     "collapsed",
+			comments: thread("1", "2>1", "3>1", "4>1", "5>4", "6>4"),
+			opts:     models.CommentViewOptions{CollapseAfter: 1},
+			want:     "1[0](2[1])+2",
+		},
+		{
+			name:     "missing parent becomes top-level",
+			comments: thread("1", "2>99", "3>2"),
+			want:     "1[0] 2[0](3[1])",
+		},
+		{
+			name:     "reply stored before its parent",
+			comments: thread("1", "3>2", "2>1"),
+			want:     "1[0](2[1](3[2]))",
+		},
+		{
+			name: "empty",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shape(services.BuildCommentView(tt.comments, tt.opts)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildCommentView_Rebuild(t *testing.T) {
+	comments := thread("1", "2>1", "3>2")
+	services.BuildCommentView(comments, models.CommentViewOptions{CollapseAfter: 1})
+
+	// A second view of the same comments starts from scratch
+	if got := shape(services.BuildCommentView(comments, models.CommentViewOptions{Mode: models.CommentViewFlat})); got != "1[0] 2[0] 3[0]" {
+		t.Errorf("flat view after tree view = %q", got)
+	}
+	if got := shape(services.BuildCommentView(comments, models.CommentViewOptions{})); got != "1[0](2[1](3[2]))" {
+		t.Errorf("tree view after flat view = %q", got)
+	}
+}
+
+func TestParseCommentViewMode(t *testing.T) {
+	for in, want := range map[string]models.CommentViewMode{"flat": models.CommentViewFlat, "tree": models.CommentViewTree, "": models.CommentViewTree, "other": models.CommentViewTree} {
+		if got := models.ParseCommentViewMode(in); got != want {
+			t.Errorf("ParseCommentViewMode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// SystemSessionID is the reporter recorded on reports filed automatically.
+const SystemSessionID = "system"
+
+// DuplicateService detects reposted text and images and manages the list of
+// banned image hashes.
+type DuplicateService struct {
+	ContentRepo ports.ContentRepository
+	ReportRepo  ports.ReportRepository
+	Policy      models.DuplicatePolicy
+}
+
+// NewDuplicateService creates a new instance of DuplicateService with the
+// default policy.
+func NewDuplicateService(contentRepo ports.ContentRepository, reportRepo ports.ReportRepository) *DuplicateService {
+	return &DuplicateService{
+		ContentRepo: contentRepo,
+		ReportRepo:  reportRepo,
+		Policy:      models.DefaultDuplicatePolicy,
+	}
+}
+
+// Fingerprint computes the fingerprint of new content.
+func (s *DuplicateService) Fingerprint(text string, attachments []models.Attachment) models.Fingerprint {
+	fp := models.Fingerprint{TextHash: TextFingerprint(text)}
+	for _, attachment := range attachments {
+		fp.ImageHashes = append(fp.ImageHashes, attachment.Hash)
+	}
+	if len(fp.ImageHashes) > 0 {
+		fp.ImageHash = fp.ImageHashes[0]
+	}
+	return fp
+}
+
+// Check refuses banned images and, depending on the policy, content seen
+// within the policy window. Every image of a message is checked. It returns
+// true when the content is a duplicate that should be accepted but flagged
+// for moderators.
+func (s *DuplicateService) Check(fp models.Fingerprint) (bool, error) {
+	hashes := fp.ImageHashes
+	if len(hashes) == 0 && fp.ImageHash != "" {
+		hashes = []string{fp.ImageHash}
+	}
+	if err := s.checkBanned(hashes); err != nil {
+		return false, err
+	}
+
+	if s.Policy.Action == models.DuplicateOff || s.Policy.Window <= 0 {
+		return false, nil
+	}
+
+	since := time.Now().Add(-s.Policy.Window)
+	what := ""
+	if fp.TextHash != "" {
+		dup, err := s.ContentRepo.HasRecentText(fp.TextHash, since)
+		if err != nil {
+			return false, err
+		}
+		if dup {
+			what = "text"
+		}
+	}
+	for _, hash := range hashes {
+		if what != "" {
+			break
+		}
+		dup, err := s.ContentRepo.HasRecentImage(hash, since)
+		if err != nil {
+			return false, err
+		}
+		if dup {
+			what = "image"
+		}
+	}
+	if what == "" {
+		return false, nil
+	}
+
+	if s.Policy.Action == models.DuplicateFlag {
+		slog.Info("Duplicate content flagged", "kind", what)
+		return true, nil
+	}
+	slog.Warn("Duplicate content rejected", "kind", what)
+	return false, fmt.Errorf("duplicate %s, the same content was posted recently: %w", what, models.ErrConflict)
+}
+
+// CheckImages refuses uploaded images before they are stored: banned ones
+// and, when the policy rejects duplicates, those posted within the window.
+// Duplicates the policy only flags are left to Check, which sees the created
+// message. Check runs again on the whole message.
+func (s *DuplicateService) CheckImages(images []*models.UploadedImage) error {
+	var fp models.Fingerprint
+	for _, image := range images {
+		fp.ImageHashes = append(fp.ImageHashes, image.Hash)
+	}
+	if s.Policy.Action != models.DuplicateReject {
+		return s.checkBanned(fp.ImageHashes)
+	}
+	_, err := s.Check(fp)
+	return err
+}
+
+// checkBanned refuses the first banned image hash.
+func (s *DuplicateService) checkBanned(hashes []string) error {
+	for _, hash := range hashes {
+		banned, err := s.ContentRepo.IsImageBanned(hash)
+		if err != nil {
+			return err
+		}
+		if banned {
+			slog.Warn("Banned image rejected", "hash", hash)
+			return fmt.Errorf("this image is banned: %w", models.ErrForbidden)
+		}
+	}
+	return nil
+}
+
+// Flag files a report against content accepted by Check as a duplicate.
+// Failures are logged; the content has already been created.
+func (s *DuplicateService) Flag(targetType string, targetID int) {
+	report := &models.Report{
+		TargetType: targetType,
+		TargetID:   targetID,
+		SessionID:  SystemSessionID,
+		Category:   models.ReportSpam,
+		Text:       "Automatic: duplicate of recent content",
+		Status:     models.ReportOpen,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.ReportRepo.CreateReport(report, 0, time.Time{}); err != nil {
+		slog.Error("Failed to flag duplicate content", "targetType", targetType, "targetID", targetID, "error", err)
+	}
+}
+
+// BanImage permanently bans an image hash. The hash can be given directly or
+// taken from a post or comment.
+func (s *DuplicateService) BanImage(moderator *models.Moderator, hash, targetType string, targetID int, reason string) (*models.BannedImage, error) {
+	if moderator == nil {
+		return nil, fmt.Errorf("moderator required: %w", models.ErrUnauthorized)
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("reason is required: %w", models.ErrInvalidInput)
+	}
+
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if hash == "" && targetID > 0 {
+		var err error
+		hash, err = s.ContentRepo.GetImageHash(targetType, targetID)
+		if err != nil {
+			return nil, err
+		}
+		if hash == "" {
+			return nil, fmt.Errorf("%s %d has no image: %w", targetType, targetID, models.ErrInvalidInput)
+		}
+	}
+	if !isSHA256Hex(hash) {
+		return nil, fmt.Errorf("invalid image hash %q: %w", hash, models.ErrInvalidInput)
+	}
+
+	ban := &models.BannedImage{
+		Hash:          hash,
+		Reason:        reason,
+		ModeratorID:   moderator.ID,
+		ModeratorName: moderator.Name,
+		CreatedAt:     time.Now(),
+	}
+	action := imageAction(moderator, models.ActionBanImage, hash, reason, ban.CreatedAt)
+	if targetID > 0 {
+		action.TargetType, action.TargetID = targetType, targetID
+	}
+	if err := s.ContentRepo.BanImage(ban, action); err != nil {
+		return nil, err
+	}
+	slog.Info("Image banned", "hash", hash, "moderator", moderator.Name)
+	return ban, nil
+}
+
+// UnbanImage lifts a ban. Like bans, unbans need a reason for the audit log.
+func (s *DuplicateService) UnbanImage(moderator *models.Moderator, hash, reason string) error {
+	if moderator == nil {
+		return fmt.Errorf("moderator required: %w", models.ErrUnauthorized)
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return fmt.Errorf("reason is required: %w", models.ErrInvalidInput)
+	}
+	hash = strings.ToLower(strings.TrimSpace(hash))
+
+	action := imageAction(moderator, models.ActionUnbanImage, hash, reason, time.Now())
+	if err := s.ContentRepo.UnbanImage(hash, action); err != nil {
+		return err
+	}
+	slog.Info("Image unbanned", "hash", hash, "moderator", moderator.Name)
+	return nil
+}
+
+// imageAction builds the audit log entry of an image ban or unban.
+func imageAction(moderator *models.Moderator, actionType models.ModActionType, hash, reason string, at time.Time) *models.ModAction {
+	return &models.ModAction{
+		ModeratorID:   moderator.ID,
+		ModeratorName: moderator.Name,
+		Action:        actionType,
+		TargetType:    actionType.TargetType(),
+		ImageHash:     hash,
+		Reason:        reason,
+		CreatedAt:     at,
+	}
+}
+
+// GetBannedImages returns the most recent bans.
+func (s *DuplicateService) GetBannedImages(limit int) ([]*models.BannedImage, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.ContentRepo.GetBannedImages(limit)
+}
+
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package services_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+var (
+	testModerator = &models.Moderator{ID: 7, Name: "mod", Role: models.RoleModerator}
+	hashA         = strings.Repeat("a", 64)
+	hashB         = strings.Repeat("b", 64)
+)
+
+func TestBanImage_Audited(t *testing.T) {
+	content := newFakeContent()
+	content.imageHash["post:5"] = hashB
+	dups := services.NewDuplicateService(content, &fakeReports{})
+
+	if _, err := dups.BanImage(testModerator, strings.ToUpper(hashA), "", 0, "gore"); err != nil {
+		t.Fatalf("ban by hash failed: %v", err)
+	}
+	if _, err := dups.BanImage(testModerator, "", "post", 5, "spam"); err != nil {
+		t.Fatalf("ban by post failed: %v", err)
+	}
+	if err := dups.UnbanImage(testModerator, hashA, "appeal"); err != nil {
+		t.Fatalf("unban failed: %v", err)
+	}
+
+	want := []models.ModAction{
+		{Action: models.ActionBanImage, TargetType: "image", ImageHash: hashA, Reason: "gore"},
+		{Action: models.ActionBanImage, TargetType: "post", TargetID: 5, ImageHash: hashB, Reason: "spam"},
+		{Action: models.ActionUnbanImage, TargetType: "image", ImageHash: hashA, Reason: "appeal"},
+	}
+	if len(content.audit) != len(want) {
+		t.Fatalf("%d audit entries, want %d", len(content.audit), len(want))
+	}
+	for i, w := range want {
+		got := content.audit[i]
+		if got.Action != w.Action || got.TargetType != w.TargetType || got.TargetID != w.TargetID ||
+			got.ImageHash != w.ImageHash || got.Reason != w.Reason || got.ModeratorName != "mod" || got.CreatedAt.IsZero() {
+			t.Errorf("audit entry %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if content.banned[hashA] || !content.banned[hashB] {
+		t.Errorf("ban list = %v, want only %s", content.banned, hashB)
+	}
+}
+
+func TestBanImage_Rejected(t *testing.T) {
+	content := newFakeContent()
+	content.imageHash["comment:9"] = ""
+	content.banned[hashA] = true
+	dups := services.NewDuplicateService(content, &fakeReports{})
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no moderator", ignoreBan(dups.BanImage(nil, hashA, "", 0, "spam")), models.ErrUnauthorized},
+		{"no reason", ignoreBan(dups.BanImage(testModerator, hashA, "", 0, " ")), models.ErrInvalidInput},
+		{"bad hash", ignoreBan(dups.BanImage(testModerator, "abc", "", 0, "spam")), models.ErrInvalidInput},
+		{"target without image", ignoreBan(dups.BanImage(testModerator, "", "comment", 9, "spam")), models.ErrInvalidInput},
+		{"missing target", ignoreBan(dups.BanImage(testModerator, "", "post", 404, "spam")), models.ErrNotFound},
+		{"unban without moderator", dups.UnbanImage(nil, hashA, "appeal"), models.ErrUnauthorized},
+		{"unban without reason", dups.UnbanImage(testModerator, hashA, ""), models.ErrInvalidInput},
+		{"unban of an unknown hash", dups.UnbanImage(testModerator, hashB, "appeal"), models.ErrNotFound},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+	if len(content.audit) != 0 {
+		t.Errorf("rejected requests were audited: %+v", content.audit)
+	}
+}
+
+func ignoreBan(_ *models.BannedImage, err error) error {
+	return err
+}
+
+func TestCheckImages(t *testing.T) {
+	content := newFakeContent()
+	content.banned[hashA] = true
+	content.recent[hashB] = true
+	dups := services.NewDuplicateService(content, &fakeReports{})
+	dups.Policy = models.DuplicatePolicy{Action: models.DuplicateReject, Window: time.Hour}
+	banned := []*models.UploadedImage{{Hash: strings.Repeat("c", 64)}, {Hash: hashA}}
+	recent := []*models.UploadedImage{{Hash: hashB}}
+
+	if err := dups.CheckImages(banned); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("banned image: error = %v, want ErrForbidden", err)
+	}
+	if err := dups.CheckImages(recent); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("recent image under the reject policy: error = %v, want ErrConflict", err)
+	}
+
+	// Flagged duplicates are stored and reported once the message exists
+	dups.Policy.Action = models.DuplicateFlag
+	if err := dups.CheckImages(recent); err != nil {
+		t.Errorf("recent image under the flag policy: error = %v, want none", err)
+	}
+	if err := dups.CheckImages(banned); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("banned image under the flag policy: error = %v, want ErrForbidden", err)
+	}
+
+	// Threads and replies run the same check before their uploads are stored
+	posts, _ := newTestPostService()
+	posts.Duplicates = dups
+	if err := posts.CheckImages("b", banned); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("PostService.CheckImages: error = %v, want ErrForbidden", err)
+	}
+	comments := services.NewCommentService(&fakeComments{})
+	comments.Duplicates = dups
+	if err := comments.CheckImages(1, banned); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("CommentService.CheckImages: error = %v, want ErrForbidden", err)
+	}
+}
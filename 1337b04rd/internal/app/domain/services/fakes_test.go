@@ -0,0 +1,304 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// fakeContent keeps banned images, recent hashes and the audit log in
+// memory. Methods the services under test do not use are left to the
+// embedded interface and panic if called.
+type fakeContent struct {
+	ports.ContentRepository
+	banned    map[string]bool
+	recent    map[string]bool // Text and image hashes seen within the window
+	imageHash map[string]string
+	audit     []*models.ModAction
+}
+
+func newFakeContent() *fakeContent {
+	return &fakeContent{banned: map[string]bool{}, recent: map[string]bool{}, imageHash: map[string]string{}}
+}
+
+func (f *fakeContent) HasRecentText(textHash string, since time.Time) (bool, error) {
+	return f.recent[textHash], nil
+}
+
+func (f *fakeContent) HasRecentImage(imageHash string, since time.Time) (bool, error) {
+	return f.recent[imageHash], nil
+}
+
+func (f *fakeContent) GetImageHash(targetType string, targetID int) (string, error) {
+	hash, ok := f.imageHash[targetType+":"+strconv.Itoa(targetID)]
+	if !ok {
+		return "", models.ErrNotFound
+	}
+	return hash, nil
+}
+
+func (f *fakeContent) IsImageBanned(imageHash string) (bool, error) {
+	return f.banned[imageHash], nil
+}
+
+func (f *fakeContent) BanImage(ban *models.BannedImage, action *models.ModAction) error {
+	if f.banned[ban.Hash] {
+		return nil
+	}
+	f.banned[ban.Hash] = true
+	f.audit = append(f.audit, action)
+	return nil
+}
+
+func (f *fakeContent) UnbanImage(imageHash string, action *models.ModAction) error {
+	if !f.banned[imageHash] {
+		return models.ErrNotFound
+	}
+	delete(f.banned, imageHash)
+	f.audit = append(f.audit, action)
+	return nil
+}
+
+// fakeReports records the reports filed by the services.
+type fakeReports struct {
+	ports.ReportRepository
+	reports []*models.Report
+}
+
+func (f *fakeReports) CreateReport(report *models.Report, rateLimit int, since time.Time) error {
+	report.ID = len(f.reports) + 1
+	f.reports = append(f.reports, report)
+	return nil
+}
+
+// fakeFilterRules keeps filter rules in memory. err, when set, fails every
+// read.
+type fakeFilterRules struct {
+	rules []*models.FilterRule
+	err   error
+}
+
+func (f *fakeFilterRules) GetFilterRules() ([]*models.FilterRule, error) {
+	return f.rules, f.err
+}
+
+func (f *fakeFilterRules) CreateFilterRule(rule *models.FilterRule) error {
+	rule.ID = len(f.rules) + 1
+	f.rules = append(f.rules, rule)
+	return nil
+}
+
+func (f *fakeFilterRules) DeleteFilterRule(id int) error {
+	for i, rule := range f.rules {
+		if rule.ID == id {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+// fakePosts stores created threads in memory. legacy lists the images to
+// migrate, with the thumbnails of each in thumbnails; replacing those in
+// replaceFails fails. purge is the result of every purge.
+type fakePosts struct {
+	ports.PostRepository
+	created      []*models.Post
+	nextID       int
+	legacy       []string
+	thumbnails   map[string][]string
+	replaceFails map[string]bool
+	replaced     map[string]string
+	purge        *models.PurgeSummary
+}
+
+func (f *fakePosts) CreatePost(post *models.Post, maxThreads int) (*models.Post, error) {
+	if post.ID == 0 {
+		post.ID = f.reserve()
+	}
+	f.created = append(f.created, post)
+	return post, nil
+}
+
+func (f *fakePosts) NextPostID() (int, error) {
+	return f.reserve(), nil
+}
+
+func (f *fakePosts) reserve() int {
+	f.nextID++
+	return f.nextID
+}
+
+func (f *fakePosts) LegacyImageURLs(ctx context.Context) ([]string, error) {
+	return f.legacy, nil
+}
+
+func (f *fakePosts) ReplaceImageURL(ctx context.Context, oldURL string, image *models.UploadedImage) ([]string, error) {
+	if f.replaceFails[oldURL] {
+		return nil, errors.New("connection lost")
+	}
+	if f.replaced == nil {
+		f.replaced = map[string]string{}
+	}
+	f.replaced[oldURL] = image.URL
+	return f.thumbnails[oldURL], nil
+}
+
+func (f *fakePosts) PurgeArchivedPosts(ctx context.Context, archivedBefore time.Time, dryRun bool) (*models.PurgeSummary, error) {
+	return f.purge, nil
+}
+
+// fakeBoards serves a fixed set of boards; threads live on the board named
+// by postBoards, or on "b".
+type fakeBoards struct {
+	ports.BoardRepository
+	boards     map[string]*models.Board
+	postBoards map[int]string
+}
+
+func newFakeBoards(boards ...*models.Board) *fakeBoards {
+	f := &fakeBoards{boards: map[string]*models.Board{"b": {Slug: "b", Title: "Random"}}, postBoards: map[int]string{}}
+	for _, b := range boards {
+		f.boards[b.Slug] = b
+	}
+	return f
+}
+
+func (f *fakeBoards) GetBoards() ([]*models.Board, error) {
+	var list []*models.Board
+	for _, b := range f.boards {
+		list = append(list, b)
+	}
+	return list, nil
+}
+
+func (f *fakeBoards) GetBoard(slug string) (*models.Board, error) {
+	return f.boards[slug], nil
+}
+
+func (f *fakeBoards) GetBoardByPostID(postID int) (*models.Board, error) {
+	if slug, ok := f.postBoards[postID]; ok {
+		return f.boards[slug], nil
+	}
+	return f.boards["b"], nil
+}
+
+// fakeSessions knows every session ID it was given.
+type fakeSessions map[string]models.UserData
+
+func (f fakeSessions) GetSessionData(sessionID string) (models.UserData, bool) {
+	data, ok := f[sessionID]
+	return data, ok
+}
+
+func (f fakeSessions) SetSessionData(sessionID string, data models.UserData) error {
+	f[sessionID] = data
+	return nil
+}
+
+// fakeComments records the comments it is asked to store.
+type fakeComments struct {
+	ports.CommentRepository
+	created []models.Comment
+}
+
+func (f *fakeComments) CreateComment(comment models.Comment, bumpLimit int) (*models.Comment, error) {
+	comment.ID = 1000 + len(f.created)
+	f.created = append(f.created, comment)
+	return &comment, nil
+}
+
+// fakeStorage rekeys images to "/img/sha-<name>" and records deletions.
+type fakeStorage struct {
+	ports.S3Adapter
+	rekeyed []string
+	deleted []string
+}
+
+func (f *fakeStorage) RekeyImage(ctx context.Context, imageURL string, check func(*models.UploadedImage) error) (*models.UploadedImage, error) {
+	url := strings.Replace(imageURL, "/img/", "/img/sha-", 1)
+	image := &models.UploadedImage{URL: url, ThumbnailURL: url + "-thumb"}
+	if check != nil {
+		if err := check(image); err != nil {
+			return nil, err
+		}
+	}
+	f.rekeyed = append(f.rekeyed, imageURL)
+	return image, nil
+}
+
+func (f *fakeStorage) DeleteImage(ctx context.Context, imageURL string) error {
+	f.deleted = append(f.deleted, imageURL)
+	return nil
+}
+
+// fakeImageQueue keeps the deletion queue in memory. It knows nothing of
+// references: every due URL is removed.
+type fakeImageQueue struct {
+	queued map[string]time.Time
+	locked bool
+}
+
+func newFakeImageQueue() *fakeImageQueue {
+	return &fakeImageQueue{queued: map[string]time.Time{}}
+}
+
+func (f *fakeImageQueue) ScheduleDeletions(ctx context.Context, urls []string, due time.Time) error {
+	for _, url := range urls {
+		if url != "" && due.After(f.queued[url]) {
+			f.queued[url] = due
+		}
+	}
+	return nil
+}
+
+func (f *fakeImageQueue) CancelDeletions(ctx context.Context, urls []string) error {
+	for _, url := range urls {
+		delete(f.queued, url)
+	}
+	return nil
+}
+
+func (f *fakeImageQueue) SweepDeletions(ctx context.Context, now time.Time, limit int, remove func(ctx context.Context, url string) error) (int, error) {
+	var due []string
+	for url, at := range f.queued {
+		if !at.After(now) {
+			due = append(due, url)
+		}
+	}
+	sort.Strings(due)
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, url := range due {
+		if err := remove(ctx, url); err != nil {
+			return 0, err
+		}
+		delete(f.queued, url)
+	}
+	return len(due), nil
+}
+
+func (f *fakeImageQueue) LockImageMigration(ctx context.Context) (func(), error) {
+	if f.locked {
+		return nil, models.ErrConflict
+	}
+	f.locked = true
+	return func() { f.locked = false }, nil
+}
+
+// urls lists the queued URLs in order.
+func (f *fakeImageQueue) urls() []string {
+	var urls []string
+	for url := range f.queued {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
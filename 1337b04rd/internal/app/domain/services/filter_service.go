@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// FilterService applies the moderator-defined text filter pipeline to new
+// posts and comments. Rules live in the database and are reloaded after
+// every edit and periodically, so changes apply without a restart.
+type FilterService struct {
+	FilterRepo ports.FilterRuleRepository
+
+	mu       sync.RWMutex
+	pipeline FilterPipeline
+	titles   FilterPipeline // pipeline without the length limits
+}
+
+// NewFilterService creates a new instance of FilterService with an empty
+// pipeline. Call Reload to load the stored rules.
+func NewFilterService(repo ports.FilterRuleRepository) *FilterService {
+	return &FilterService{FilterRepo: repo}
+}
+
+// Apply runs text through the current pipeline. Text that the filters
+// reduce to nothing is rejected.
+func (s *FilterService) Apply(text string) (string, error) {
+	s.mu.RLock()
+	pipeline := s.pipeline
+	s.mu.RUnlock()
+	return applyPipeline(pipeline, text)
+}
+
+// ApplyTitle is Apply for thread titles. The length limits of the rules are
+// meant for the text and do not apply.
+func (s *FilterService) ApplyTitle(title string) (string, error) {
+	s.mu.RLock()
+	pipeline := s.titles
+	s.mu.RUnlock()
+	return applyPipeline(pipeline, title)
+}
+
+func applyPipeline(pipeline FilterPipeline, text string) (string, error) {
+	filtered, err := pipeline.Filter(text)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(filtered) == "" && strings.TrimSpace(text) != "" {
+		return "", fmt.Errorf("text is empty after filtering: %w", models.ErrInvalidInput)
+	}
+	return filtered, nil
+}
+
+// Reload rebuilds the pipeline from the stored rules. On error the previous
+// pipeline stays in place.
+func (s *FilterService) Reload() error {
+	rules, err := s.FilterRepo.GetFilterRules()
+	if err != nil {
+		return err
+	}
+	pipeline, err := BuildFilterPipeline(rules)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.pipeline, s.titles = pipeline, pipeline.WithoutLengthLimits()
+	s.mu.Unlock()
+	return nil
+}
+
+// StartReloader runs Reload every interval in the background until ctx is
+// cancelled. It picks up edits made by other instances.
+func (s *FilterService) StartReloader(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		slog.Info("Filter reloader started", "Interval", interval)
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("Filter reloader stopped")
+				return
+			case <-ticker.C:
+				if err := s.Reload(); err != nil {
+					slog.Error("Failed to reload filter rules, keeping previous pipeline", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// GetRules returns the stored rules.
+func (s *FilterService) GetRules() ([]*models.FilterRule, error) {
+	return s.FilterRepo.GetFilterRules()
+}
+
+// AddRule validates and stores a rule, then reloads the pipeline.
+func (s *FilterService) AddRule(moderator *models.Moderator, kind models.FilterRuleKind, pattern, replacement string) (*models.FilterRule, error) {
+	if moderator == nil {
+		return nil, fmt.Errorf("moderator required: %w", models.ErrUnauthorized)
+	}
+	rule := &models.FilterRule{
+		Kind:        kind,
+		Pattern:     strings.TrimSpace(pattern),
+		Replacement: replacement,
+		CreatedBy:   moderator.Name,
+		CreatedAt:   time.Now(),
+	}
+	if err := ValidateFilterRule(rule); err != nil {
+		return nil, err
+	}
+	if err := s.FilterRepo.CreateFilterRule(rule); err != nil {
+		return nil, err
+	}
+	slog.Info("Filter rule added", "ruleID", rule.ID, "kind", rule.Kind, "moderator", moderator.Name)
+	return rule, s.Reload()
+}
+
+// DeleteRule removes a rule and reloads the pipeline.
+func (s *FilterService) DeleteRule(moderator *models.Moderator, id int) error {
+	if moderator == nil {
+		return fmt.Errorf("moderator required: %w", models.ErrUnauthorized)
+	}
+	if err := s.FilterRepo.DeleteFilterRule(id); err != nil {
+		return err
+	}
+	slog.Info("Filter rule deleted", "ruleID", id, "moderator", moderator.Name)
+	return s.Reload()
+}
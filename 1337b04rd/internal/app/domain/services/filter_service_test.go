@@ -0,0 +1,69 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+func TestFilterService_ApplyTitle(t *testing.T) {
+	repo := &fakeFilterRules{rules: []*models.FilterRule{
+		rule(models.FilterBanWord, "scam", ""),
+		rule(models.FilterMinLength, "20", ""),
+		rule(models.FilterStripURLs, "", ""),
+	}}
+	filters := services.NewFilterService(repo)
+	if err := filters.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if got, err := filters.ApplyTitle("Short title"); err != nil || got != "Short title" {
+		t.Errorf("short title = %q, %v; the length limits are for the text", got, err)
+	}
+	if _, err := filters.ApplyTitle("Free scam"); !errors.Is(err, models.ErrInvalidInput) {
+		t.Errorf("title with a banned word: error = %v", err)
+	}
+	if _, err := filters.ApplyTitle("https://evil.com"); !errors.Is(err, models.ErrInvalidInput) {
+		t.Errorf("title made of a stripped link: error = %v", err)
+	}
+	if _, err := filters.Apply("Short text"); !errors.Is(err, models.ErrInvalidInput) {
+		t.Errorf("short text: error = %v", err)
+	}
+}
+
+func TestFilterService_ReloadKeepsPipelineOnError(t *testing.T) {
+	repo := &fakeFilterRules{rules: []*models.FilterRule{rule(models.FilterBanWord, "scam", "")}}
+	filters := services.NewFilterService(repo)
+	if err := filters.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	repo.err = errors.New("database is down")
+	if err := filters.Reload(); err == nil {
+		t.Fatal("Reload hid a repository error")
+	}
+	repo.err = nil
+	repo.rules = append(repo.rules, rule(models.FilterReplace, "(", ""))
+	if err := filters.Reload(); err == nil {
+		t.Fatal("Reload accepted an invalid rule")
+	}
+
+	if _, err := filters.Apply("a scam"); !errors.Is(err, models.ErrInvalidInput) {
+		t.Errorf("previous rules were dropped: %v", err)
+	}
+}
+
+func TestFilterService_AddRuleAppliesAtOnce(t *testing.T) {
+	filters := services.NewFilterService(&fakeFilterRules{})
+	if _, err := filters.AddRule(testModerator, models.FilterReplace, "heck", "h*ck"); err != nil {
+		t.Fatalf("AddRule failed: %v", err)
+	}
+	if got, _ := filters.Apply("heck"); got != "h*ck" {
+		t.Errorf("Apply after AddRule = %q", got)
+	}
+	if _, err := filters.AddRule(nil, models.FilterReplace, "a", "b"); !errors.Is(err, models.ErrUnauthorized) {
+		t.Errorf("AddRule without a moderator: error = %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// minFingerprintLength is the shortest normalized text that is checked for
+// duplicates. Shorter replies ("+1", "lol") repeat legitimately.
+const minFingerprintLength = 10
+
+// NormalizeText lowercases text and drops everything except letters and
+// digits, so that changes in case, spacing or punctuation do not hide a
+// repost.
+func NormalizeText(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// TextFingerprint returns the hex SHA-256 of the normalized text, or "" if
+// the text is too short to compare.
+func TextFingerprint(text string) string {
+	normalized := NormalizeText(text)
+	if len([]rune(normalized)) < minFingerprintLength {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// Clock abstracts time.Now so that limits can be tested with a fake clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// FloodGuard enforces a models.FloodPolicy in memory. Every submission is
+// checked against a set of keys (for example the session and the client IP)
+// and only recorded when all of them pass. Submissions that then fail are
+// taken back with FloodReservation.Cancel.
+type FloodGuard struct {
+	mu     sync.Mutex
+	clock  Clock
+	policy models.FloodPolicy
+
+	any      *slidingWindow // PostInterval, threads and comments
+	comments *slidingWindow // CommentsPerMinute
+	threads  *slidingWindow // ThreadCooldown
+
+	lastSweep time.Time
+}
+
+// NewFloodGuard creates a FloodGuard. A nil clock uses the system time.
+func NewFloodGuard(policy models.FloodPolicy, clock Clock) *FloodGuard {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &FloodGuard{
+		clock:    clock,
+		policy:   policy,
+		any:      newSlidingWindow(1, policy.PostInterval),
+		comments: newSlidingWindow(policy.CommentsPerMinute, time.Minute),
+		threads:  newSlidingWindow(1, policy.ThreadCooldown),
+	}
+}
+
+// AllowThread records a new thread for keys, or returns a
+// *models.RateLimitError if any key is over its limit.
+func (g *FloodGuard) AllowThread(keys ...string) error {
+	_, err := g.ReserveThread(keys...)
+	return err
+}
+
+// AllowComment records a new comment for keys, or returns a
+// *models.RateLimitError if any key is over its limit.
+func (g *FloodGuard) AllowComment(keys ...string) error {
+	_, err := g.ReserveComment(keys...)
+	return err
+}
+
+// ReserveThread is AllowThread for a thread that may still fail to be
+// created. The thread counts against the limits right away, so that
+// parallel submissions cannot slip through, until the reservation is
+// cancelled.
+func (g *FloodGuard) ReserveThread(keys ...string) (*FloodReservation, error) {
+	return g.allow(keys, []limitCheck{
+		{g.any, "posting too fast"},
+		{g.threads, "new thread cooldown"},
+	})
+}
+
+// ReserveComment is ReserveThread for comments.
+func (g *FloodGuard) ReserveComment(keys ...string) (*FloodReservation, error) {
+	return g.allow(keys, []limitCheck{
+		{g.any, "posting too fast"},
+		{g.comments, "too many comments per minute"},
+	})
+}
+
+// FloodReservation is a submission recorded by ReserveThread or
+// ReserveComment.
+type FloodReservation struct {
+	guard  *FloodGuard
+	keys   []string
+	checks []limitCheck
+	at     time.Time
+}
+
+// Cancel takes back a submission that was rejected or failed, so that it
+// does not count against the limits. Calling it again has no effect.
+func (r *FloodReservation) Cancel() {
+	r.guard.mu.Lock()
+	defer r.guard.mu.Unlock()
+	for _, check := range r.checks {
+		for _, key := range r.keys {
+			check.window.remove(key, r.at)
+		}
+	}
+	r.checks = nil
+}
+
+type limitCheck struct {
+	window *slidingWindow
+	reason string
+}
+
+func (g *FloodGuard) allow(keys []string, checks []limitCheck) (*FloodReservation, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+	g.sweep(now)
+
+	var worst *models.RateLimitError
+	for _, check := range checks {
+		for _, key := range keys {
+			if wait := check.window.wait(key, now); wait > 0 && (worst == nil || wait > worst.RetryAfter) {
+				worst = &models.RateLimitError{Reason: check.reason, RetryAfter: wait}
+			}
+		}
+	}
+	if worst != nil {
+		return nil, worst
+	}
+
+	for _, check := range checks {
+		for _, key := range keys {
+			check.window.add(key, now)
+		}
+	}
+	return &FloodReservation{guard: g, keys: keys, checks: checks, at: now}, nil
+}
+
+// sweep drops expired entries about once a minute so idle keys do not
+// accumulate.
+func (g *FloodGuard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < time.Minute {
+		return
+	}
+	g.lastSweep = now
+	g.any.prune(now)
+	g.comments.prune(now)
+	g.threads.prune(now)
+}
+
+// slidingWindow allows at most limit events per key within size.
+type slidingWindow struct {
+	limit int
+	size  time.Duration
+	hits  map[string][]time.Time
+}
+
+func newSlidingWindow(limit int, size time.Duration) *slidingWindow {
+	return &slidingWindow{limit: limit, size: size, hits: make(map[string][]time.Time)}
+}
+
+func (w *slidingWindow) enabled() bool {
+	return w.limit > 0 && w.size > 0
+}
+
+// wait returns how long key has to wait before the next event, or zero.
+func (w *slidingWindow) wait(key string, now time.Time) time.Duration {
+	if !w.enabled() {
+		return 0
+	}
+	hits := w.expire(key, now)
+	if len(hits) < w.limit {
+		return 0
+	}
+	return hits[len(hits)-w.limit].Add(w.size).Sub(now)
+}
+
+func (w *slidingWindow) add(key string, now time.Time) {
+	if !w.enabled() {
+		return
+	}
+	w.hits[key] = append(w.hits[key], now)
+}
+
+// remove drops one event of key that happened at at, if there still is one.
+func (w *slidingWindow) remove(key string, at time.Time) {
+	hits := w.hits[key]
+	for i := len(hits) - 1; i >= 0; i-- {
+		if hits[i].Equal(at) {
+			hits = append(hits[:i], hits[i+1:]...)
+			break
+		}
+	}
+	if len(hits) == 0 {
+		delete(w.hits, key)
+		return
+	}
+	w.hits[key] = hits
+}
+
+// expire removes events of key that fell out of the window.
+func (w *slidingWindow) expire(key string, now time.Time) []time.Time {
+	hits := w.hits[key]
+	i := 0
+	for i < len(hits) && !hits[i].Add(w.size).After(now) {
+		i++
+	}
+	hits = hits[i:]
+	if len(hits) == 0 {
+		delete(w.hits, key)
+		return nil
+	}
+	w.hits[key] = hits
+	return hits
+}
+
+func (w *slidingWindow) prune(now time.Time) {
+	for key := range w.hits {
+		w.expire(key, now)
+	}
+}
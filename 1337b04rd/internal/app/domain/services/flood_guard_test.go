@@ -0,0 +1,167 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+// fakeClock is moved forward by the test instead of by real time.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestGuard(policy models.FloodPolicy) (*services.FloodGuard, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return services.NewFloodGuard(policy, clock), clock
+}
+
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var limitErr *models.RateLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected *models.RateLimitError, got %v", err)
+	}
+	if !errors.Is(err, models.ErrRateLimited) {
+		t.Fatalf("expected error to match ErrRateLimited")
+	}
+	return limitErr.RetryAfter
+}
+
+func TestFloodGuard_PostInterval(t *testing.T) {
+	guard, clock := newTestGuard(models.FloodPolicy{PostInterval: 10 * time.Second})
+
+	if err := guard.AllowComment("session:a"); err != nil {
+		t.Fatalf("first comment rejected: %v", err)
+	}
+
+	clock.Advance(4 * time.Second)
+	if got := retryAfter(t, guard.AllowComment("session:a")); got != 6*time.Second {
+		t.Errorf("RetryAfter = %v, want 6s", got)
+	}
+	// The interval covers threads as well as comments
+	retryAfter(t, guard.AllowThread("session:a"))
+
+	// Other posters are not affected
+	if err := guard.AllowComment("session:b"); err != nil {
+		t.Errorf("other session rejected: %v", err)
+	}
+
+	clock.Advance(6 * time.Second)
+	if err := guard.AllowComment("session:a"); err != nil {
+		t.Errorf("comment after interval rejected: %v", err)
+	}
+}
+
+func TestFloodGuard_CommentsPerMinute(t *testing.T) {
+	guard, clock := newTestGuard(models.FloodPolicy{CommentsPerMinute: 3})
+
+	for i := 0; i < 3; i++ {
+		if err := guard.AllowComment("session:a"); err != nil {
+			t.Fatalf("comment %d rejected: %v", i+1, err)
+		}
+		clock.Advance(10 * time.Second)
+	}
+
+	// Comments were at 0s, 10s and 20s; now is 30s, so the first one
+	// leaves the window at 60s.
+	if got := retryAfter(t, guard.AllowComment("session:a")); got != 30*time.Second {
+		t.Errorf("RetryAfter = %v, want 30s", got)
+	}
+	// Threads are not counted against the comment limit
+	if err := guard.AllowThread("session:a"); err != nil {
+		t.Errorf("thread rejected by comment limit: %v", err)
+	}
+
+	clock.Advance(30 * time.Second)
+	if err := guard.AllowComment("session:a"); err != nil {
+		t.Errorf("comment after window rejected: %v", err)
+	}
+}
+
+func TestFloodGuard_ThreadCooldown(t *testing.T) {
+	guard, clock := newTestGuard(models.FloodPolicy{ThreadCooldown: 2 * time.Minute})
+
+	if err := guard.AllowThread("session:a"); err != nil {
+		t.Fatalf("first thread rejected: %v", err)
+	}
+	if err := guard.AllowComment("session:a"); err != nil {
+		t.Errorf("comment rejected by thread cooldown: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if got := retryAfter(t, guard.AllowThread("session:a")); got != time.Minute {
+		t.Errorf("RetryAfter = %v, want 1m", got)
+	}
+
+	clock.Advance(time.Minute)
+	if err := guard.AllowThread("session:a"); err != nil {
+		t.Errorf("thread after cooldown rejected: %v", err)
+	}
+}
+
+func TestFloodGuard_KeysAreCheckedTogether(t *testing.T) {
+	guard, clock := newTestGuard(models.FloodPolicy{PostInterval: 10 * time.Second})
+
+	if err := guard.AllowThread("ip:10.0.0.1", "session:a"); err != nil {
+		t.Fatalf("first thread rejected: %v", err)
+	}
+
+	// A fresh session from the same IP is still limited
+	clock.Advance(time.Second)
+	retryAfter(t, guard.AllowThread("ip:10.0.0.1", "session:b"))
+
+	// The rejected request must not have been recorded for session:b
+	if err := guard.AllowThread("ip:10.0.0.2", "session:b"); err != nil {
+		t.Errorf("rejected request was recorded: %v", err)
+	}
+}
+
+func TestFloodGuard_CancelledReservation(t *testing.T) {
+	guard, clock := newTestGuard(models.FloodPolicy{PostInterval: 10 * time.Second, CommentsPerMinute: 2})
+
+	reservation, err := guard.ReserveComment("ip:10.0.0.1", "session:a")
+	if err != nil {
+		t.Fatalf("first comment rejected: %v", err)
+	}
+	// Until the submission is known to have failed it blocks the next one
+	retryAfter(t, guard.AllowComment("session:a"))
+
+	reservation.Cancel()
+	reservation.Cancel()
+	if err := guard.AllowComment("ip:10.0.0.1", "session:a"); err != nil {
+		t.Fatalf("comment after a cancelled one rejected: %v", err)
+	}
+
+	// Only the comment that went through counts towards the minute
+	clock.Advance(10 * time.Second)
+	if err := guard.AllowComment("session:a"); err != nil {
+		t.Fatalf("second comment rejected: %v", err)
+	}
+	clock.Advance(10 * time.Second)
+	retryAfter(t, guard.AllowComment("session:a"))
+}
+
+func TestFloodGuard_ZeroPolicyDisablesLimits(t *testing.T) {
+	guard, _ := newTestGuard(models.FloodPolicy{})
+
+	for i := 0; i < 100; i++ {
+		if err := guard.AllowComment("session:a"); err != nil {
+			t.Fatalf("comment %d rejected with limits disabled: %v", i+1, err)
+		}
+	}
+}
+
+func TestRateLimitError_RetryAfterSeconds(t *testing.T) {
+	err := &models.RateLimitError{Reason: "slow down", RetryAfter: 1500 * time.Millisecond}
+	if got := err.RetryAfterSeconds(); got != 2 {
+		t.Errorf("RetryAfterSeconds() = %d, want 2", got)
+	}
+}
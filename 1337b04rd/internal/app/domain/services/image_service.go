@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// DefaultDeleteGrace is how long images wait in the deletion queue.
+const DefaultDeleteGrace = 10 * time.Minute
+
+// sweepBatch is the number of queued images handled per transaction.
+const sweepBatch = 100
+
+// ImageService deletes stored images once nothing refers to them. Image keys
+// are content hashes, so an object one message gives up may be uploaded for
+// another at the same moment. Deletions therefore wait in a queue for Grace,
+// and the database is checked again just before an object goes.
+type ImageService struct {
+	Storage ports.S3Adapter
+	Repo    ports.ImageRepository
+	Grace   time.Duration // Longer than any upload takes from storing to saving its message
+}
+
+// NewImageService creates a new instance of ImageService.
+func NewImageService(storage ports.S3Adapter, repo ports.ImageRepository) *ImageService {
+	return &ImageService{
+		Storage: storage,
+		Repo:    repo,
+		Grace:   DefaultDeleteGrace,
+	}
+}
+
+// Delete queues the objects behind urls for deletion once Grace has passed.
+func (s *ImageService) Delete(ctx context.Context, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	if err := s.Repo.ScheduleDeletions(ctx, urls, time.Now().Add(s.Grace)); err != nil {
+		slog.Error("Failed to queue image deletions", "Count", len(urls), "error", err)
+		return fmt.Errorf("failed to queue image deletions: %w", err)
+	}
+	return nil
+}
+
+// Keep takes images that are about to be stored off the deletion queue. It
+// must be called before they are stored: it waits for a sweep that is
+// deleting the same objects, which would otherwise remove them after the
+// upload.
+func (s *ImageService) Keep(ctx context.Context, images []*models.UploadedImage) error {
+	urls := imageURLs(images)
+	if len(urls) == 0 {
+		return nil
+	}
+	if err := s.Repo.CancelDeletions(ctx, urls); err != nil {
+		slog.Error("Failed to take images off the deletion queue", "Count", len(urls), "error", err)
+		return fmt.Errorf("failed to keep images: %w", err)
+	}
+	return nil
+}
+
+// Discard queues the images and thumbnails of a message that was not
+// created for deletion. Another message may use the same objects, which the
+// sweep finds. Failures are logged and leave the objects in storage.
+func (s *ImageService) Discard(ctx context.Context, images []*models.UploadedImage) {
+	if err := s.Delete(ctx, imageURLs(images)); err == nil && len(images) > 0 {
+		slog.Info("Images of a refused message discarded", "Count", len(images))
+	}
+}
+
+// imageURLs lists the URLs and thumbnail URLs of images.
+func imageURLs(images []*models.UploadedImage) []string {
+	var urls []string
+	for _, image := range images {
+		urls = append(urls, image.URL)
+		if image.ThumbnailURL != "" {
+			urls = append(urls, image.ThumbnailURL)
+		}
+	}
+	return urls
+}
+
+// Sweep deletes the queued images that are due and unused, and returns how
+// many were deleted.
+func (s *ImageService) Sweep(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		deleted, err := s.Repo.SweepDeletions(ctx, time.Now(), sweepBatch, s.Storage.DeleteImage)
+		total += deleted
+		if err != nil {
+			slog.Error("Failed to delete queued images", "error", err)
+			return total, fmt.Errorf("failed to delete queued images: %w", err)
+		}
+		if deleted < sweepBatch {
+			break
+		}
+	}
+	if total > 0 {
+		slog.Info("Queued images deleted", "Count", total)
+	}
+	return total, nil
+}
@@ -0,0 +1,91 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+func TestMigrateImageKeys(t *testing.T) {
+	service, posts := newTestPostService()
+	posts.legacy = []string{"/img/a.png", "/img/b.png"}
+	posts.thumbnails = map[string][]string{"/img/a.png": {"/img/a_thumb.png"}}
+	posts.replaceFails = map[string]bool{"/img/b.png": true}
+	storage, queue := &fakeStorage{}, newFakeImageQueue()
+	service.Images = services.NewImageService(storage, queue)
+
+	migrated, err := service.MigrateImageKeys(context.Background())
+	if err != nil || migrated != 1 {
+		t.Fatalf("MigrateImageKeys = %d, %v, want 1 image migrated", migrated, err)
+	}
+	if posts.replaced["/img/a.png"] != "/img/sha-a.png" {
+		t.Errorf("posts point at %q, want /img/sha-a.png", posts.replaced["/img/a.png"])
+	}
+
+	// The old objects of a and the unused copy of b wait for the sweep
+	want := []string{"/img/a.png", "/img/a_thumb.png", "/img/sha-b.png", "/img/sha-b.png-thumb"}
+	if got := queue.urls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("queued %q, want %q", got, want)
+	}
+	if len(storage.deleted) != 0 {
+		t.Errorf("deleted %q before the grace period", storage.deleted)
+	}
+	if queue.locked {
+		t.Error("migration lock not released")
+	}
+}
+
+func TestMigrateImageKeys_OneProcessAtATime(t *testing.T) {
+	service, posts := newTestPostService()
+	posts.legacy = []string{"/img/a.png"}
+	storage, queue := &fakeStorage{}, newFakeImageQueue()
+	service.Images = services.NewImageService(storage, queue)
+
+	queue.locked = true
+	if _, err := service.MigrateImageKeys(context.Background()); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("error = %v, want ErrConflict while another process migrates", err)
+	}
+	if len(storage.rekeyed) != 0 {
+		t.Errorf("rekeyed %q without the lock", storage.rekeyed)
+	}
+}
+
+func TestPurgeArchivedPosts_QueuesImages(t *testing.T) {
+	service, posts := newTestPostService()
+	posts.purge = &models.PurgeSummary{ThreadIDs: []int{1}, Images: []string{"/img/a.png", "/img/a-thumb.png"}}
+	storage, queue := &fakeStorage{}, newFakeImageQueue()
+	service.Images = services.NewImageService(storage, queue)
+
+	start := time.Now()
+	if _, err := service.PurgeArchivedPosts(context.Background(), models.PurgePolicy{Retention: time.Hour}); err != nil {
+		t.Fatalf("PurgeArchivedPosts failed: %v", err)
+	}
+	if len(storage.deleted) != 0 {
+		t.Errorf("deleted %q before the grace period", storage.deleted)
+	}
+	for _, url := range posts.purge.Images {
+		if due := queue.queued[url]; due.Before(start.Add(services.DefaultDeleteGrace)) {
+			t.Errorf("%s due at %v, want after the grace period", url, due)
+		}
+	}
+}
+
+func TestImageSweep(t *testing.T) {
+	storage, queue := &fakeStorage{}, newFakeImageQueue()
+	images := services.NewImageService(storage, queue)
+	queue.queued["/img/due.png"] = time.Now().Add(-time.Minute)
+	queue.queued["/img/later.png"] = time.Now().Add(time.Minute)
+
+	deleted, err := images.Sweep(context.Background())
+	if err != nil || deleted != 1 {
+		t.Fatalf("Sweep = %d, %v, want 1 image deleted", deleted, err)
+	}
+	if !reflect.DeepEqual(storage.deleted, []string{"/img/due.png"}) || !reflect.DeepEqual(queue.urls(), []string{"/img/later.png"}) {
+		t.Errorf("deleted %q and kept %q, want only the due image deleted", storage.deleted, queue.urls())
+	}
+}
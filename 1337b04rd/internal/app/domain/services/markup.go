@@ -0,0 +1,198 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// QuoteLinker returns the URL for a >>id quote, or false if the target does
+// not exist.
+type QuoteLinker func(id int) (href string, ok bool)
+
+var (
+	// inlineToken matches quote links and spoiler tags inside a line
+	inlineToken = regexp.MustCompile(`(?i)>>(\d{1,10})|\[spoiler\]|\[/spoiler\]`)
+	// leadingQuote tells a quote line (">>123 ...") apart from greentext
+	leadingQuote = regexp.MustCompile(`^>>\d`)
+)
+
+// RenderMarkup turns imageboard markup into HTML:
+//
+//   - lines starting with ">" become greentext
+//   - >>123 links to the quoted post or comment via link
+//   - [spoiler]...[/spoiler] hides text until hovered
+//   - lines between ``` fences are preformatted
+//
+// Everything else is escaped, so the result is safe to embed as HTML.
+// Newlines are kept as "\n"; the container is expected to preserve them.
+func RenderMarkup(text string, link QuoteLinker) string {
+	var b strings.Builder
+	first := true
+
+	walkMarkupLines(text, func(line string) {
+		if !first {
+			b.WriteByte('\n')
+		}
+		first = false
+
+		if strings.HasPrefix(line, ">") && !leadingQuote.MatchString(line) {
+			b.WriteString(`<span class="greentext">`)
+			renderInline(&b, line, link)
+			b.WriteString(`</span>`)
+			return
+		}
+		renderInline(&b, line, link)
+	}, func(code []string) {
+		b.WriteString(`<pre><code>`)
+		b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+		b.WriteString(`</code></pre>`)
+		// The block already ends the line
+		first = true
+	})
+
+	return b.String()
+}
+
+// ExtractQuoteIDs returns the distinct IDs quoted with >>id outside code
+// blocks, in order of appearance.
+func ExtractQuoteIDs(text string) []int {
+	var ids []int
+	seen := make(map[int]bool)
+	walkMarkupLines(text, func(line string) {
+		for _, m := range inlineToken.FindAllStringSubmatch(line, -1) {
+			if m[1] == "" {
+				continue
+			}
+			if id, err := strconv.Atoi(m[1]); err == nil && id > 0 && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}, func([]string) {})
+	return ids
+}
+
+// MarkupTeaser returns the start of text as plain text for previews such as
+// the catalog. Spoilers stay hidden behind "[spoiler]", code blocks are
+// dropped and whitespace is collapsed. Longer results are cut to at most
+// limit characters, at a word boundary when there is one, and "…" is added.
+func MarkupTeaser(text string, limit int) string {
+	var b strings.Builder
+	walkMarkupLines(text, func(line string) {
+		openSpoilers := 0
+		last := 0
+		for _, m := range inlineToken.FindAllStringIndex(line, -1) {
+			token := line[m[0]:m[1]]
+			switch {
+			case strings.EqualFold(token, "[spoiler]"):
+				if openSpoilers == 0 {
+					b.WriteString(line[last:m[0]])
+					b.WriteString(" [spoiler] ")
+				}
+				openSpoilers++
+			case strings.EqualFold(token, "[/spoiler]") && openSpoilers > 0:
+				openSpoilers--
+			case openSpoilers == 0:
+				// Quote links and stray closing tags are kept as typed
+				b.WriteString(line[last:m[1]])
+			}
+			last = m[1]
+		}
+		if openSpoilers == 0 {
+			b.WriteString(line[last:])
+		}
+		b.WriteByte(' ')
+	}, func([]string) {})
+
+	teaser := strings.Join(strings.Fields(b.String()), " ")
+	runes := []rune(teaser)
+	if len(runes) <= limit {
+		return teaser
+	}
+	cut := string(runes[:limit])
+	// Unless the cut falls between words, drop the partial last word
+	if runes[limit] != ' ' {
+		if i := strings.LastIndexByte(cut, ' '); i > len(cut)/2 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " ") + "…"
+}
+
+// walkMarkupLines splits text into lines and calls onLine for normal lines
+// and onCode once per fenced code block. An unterminated fence runs to the
+// end of the text.
+func walkMarkupLines(text string, onLine func(line string), onCode func(code []string)) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var code []string
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inCode {
+				onCode(code)
+				code = nil
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			code = append(code, line)
+			continue
+		}
+		onLine(line)
+	}
+	if inCode {
+		onCode(code)
+	}
+}
+
+// renderInline escapes line and renders quote links and spoilers. Spoilers
+// left open are closed at the end of the line so the output stays balanced.
+func renderInline(b *strings.Builder, line string, link QuoteLinker) {
+	openSpoilers := 0
+	last := 0
+	for _, m := range inlineToken.FindAllStringSubmatchIndex(line, -1) {
+		b.WriteString(html.EscapeString(line[last:m[0]]))
+		last = m[1]
+		token := line[m[0]:m[1]]
+
+		switch {
+		case m[2] >= 0:
+			renderQuote(b, line[m[2]:m[3]], link)
+		case strings.EqualFold(token, "[spoiler]"):
+			b.WriteString(`<span class="spoiler">`)
+			openSpoilers++
+		case openSpoilers > 0:
+			b.WriteString(`</span>`)
+			openSpoilers--
+		default:
+			// Stray closing tag
+			b.WriteString(html.EscapeString(token))
+		}
+	}
+	b.WriteString(html.EscapeString(line[last:]))
+	for ; openSpoilers > 0; openSpoilers-- {
+		b.WriteString(`</span>`)
+	}
+}
+
+func renderQuote(b *strings.Builder, digits string, link QuoteLinker) {
+	id, err := strconv.Atoi(digits)
+	label := "&gt;&gt;" + html.EscapeString(digits)
+	if err == nil && id > 0 && link != nil {
+		if href, ok := link(id); ok {
+			b.WriteString(`<a class="quotelink" href="`)
+			b.WriteString(html.EscapeString(href))
+			b.WriteString(`">`)
+			b.WriteString(label)
+			b.WriteString(`</a>`)
+			return
+		}
+	}
+	b.WriteString(`<span class="deadlink">`)
+	b.WriteString(label)
+	b.WriteString(`</span>`)
+}
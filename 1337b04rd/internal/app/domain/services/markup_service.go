@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// MarkupService renders the text of a thread, resolving quotes to other
+// threads with one lookup per page.
+type MarkupService struct {
+	QuoteRepo ports.QuoteRepository
+}
+
+// NewMarkupService creates a new instance of MarkupService.
+func NewMarkupService(quoteRepo ports.QuoteRepository) *MarkupService {
+	return &MarkupService{QuoteRepo: quoteRepo}
+}
+
+// RenderThread fills TextHTML of post (which may be nil) and of comments,
+// including nested replies. Quotes resolve in this order: a comment shown on
+// the page, the thread opener, another thread, a comment in another thread.
+// Quotes that cannot be resolved are rendered as dead links.
+func (s *MarkupService) RenderThread(threadID int, post *models.Post, comments []*models.Comment) {
+	var all []*models.Comment
+	var collect func([]*models.Comment)
+	collect = func(list []*models.Comment) {
+		for _, c := range list {
+			all = append(all, c)
+			collect(c.Replies)
+		}
+	}
+	collect(comments)
+
+	local := make(map[int]bool, len(all))
+	for _, c := range all {
+		local[c.ID] = true
+	}
+
+	// Quotes that cannot be answered from the page itself
+	var remote []int
+	seen := make(map[int]bool)
+	addRemote := func(text string) {
+		for _, id := range ExtractQuoteIDs(text) {
+			if !local[id] && id != threadID && !seen[id] {
+				seen[id] = true
+				remote = append(remote, id)
+			}
+		}
+	}
+	if post != nil {
+		addRemote(post.Text)
+	}
+	for _, c := range all {
+		addRemote(c.Text)
+	}
+
+	posts := make(map[int]models.QuoteTarget)
+	others := make(map[int]models.QuoteTarget)
+	if len(remote) > 0 && s.QuoteRepo != nil {
+		targets, err := s.QuoteRepo.ResolveQuotes(remote)
+		if err != nil {
+			slog.Error("Failed to resolve quotes", "threadID", threadID, "error", err)
+		}
+		for _, t := range targets {
+			if t.IsPost {
+				posts[t.ID] = t
+			} else {
+				others[t.ID] = t
+			}
+		}
+	}
+
+	link := func(id int) (string, bool) {
+		switch {
+		case local[id]:
+			return fmt.Sprintf("#c%d", id), true
+		case id == threadID:
+			return fmt.Sprintf("#p%d", id), true
+		}
+		if t, ok := posts[id]; ok {
+			return threadURL(t), true
+		}
+		if t, ok := others[id]; ok {
+			if t.PostID == threadID {
+				return fmt.Sprintf("#c%d", id), true
+			}
+			return fmt.Sprintf("%s#c%d", threadURL(t), id), true
+		}
+		return "", false
+	}
+
+	if post != nil {
+		post.TextHTML = RenderMarkup(post.Text, link)
+	}
+	for _, c := range all {
+		c.TextHTML = RenderMarkup(c.Text, link)
+	}
+}
+
+func threadURL(t models.QuoteTarget) string {
+	if t.Archived {
+		return fmt.Sprintf("/archived/post/%d", t.PostID)
+	}
+	return fmt.Sprintf("/post/%d", t.PostID)
+}
@@ -0,0 +1,162 @@
+package services_test
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"1337b04rd/internal/app/domain/services"
+)
+
+// evenLinker resolves even IDs as comments in this thread and IDs divisible
+// by 3 as other threads; everything else is a dead link.
+func evenLinker(id int) (string, bool) {
+	switch {
+	case id%2 == 0:
+		return fmt.Sprintf("#c%d", id), true
+	case id%3 == 0:
+		return fmt.Sprintf("/post/%d", id), true
+	default:
+		return "", false
+	}
+}
+
+func TestRenderMarkup(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "hello", "hello"},
+		{"escapes html", `<script>alert("x")</script>`, `&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;`},
+		{"greentext", ">be me\nnormal", `<span class="greentext">&gt;be me</span>` + "\nnormal"},
+		{"quote link", ">>2 yes", `<a class="quotelink" href="#c2">&gt;&gt;2</a> yes`},
+		{"cross-thread quote", "see >>9", `see <a class="quotelink" href="/post/9">&gt;&gt;9</a>`},
+		{"dead quote", ">>7", `<span class="deadlink">&gt;&gt;7</span>`},
+		{"quote in greentext", ">agree with >>4", `<span class="greentext">&gt;agree with <a class="quotelink" href="#c4">&gt;&gt;4</a></span>`},
+		{"spoiler", "a [spoiler]b[/spoiler] c", `a <span class="spoiler">b</span> c`},
+		{"spoiler case", "[SPOILER]b[/Spoiler]", `<span class="spoiler">b</span>`},
+		{"unclosed spoiler", "[spoiler]b", `<span class="spoiler">b</span>`},
+		{"stray close", "b[/spoiler]", `b[/spoiler]`},
+		{"code block", "x\n```go\n<b> >>2\n```\ny", "x<pre><code>&lt;b&gt; &gt;&gt;2</code></pre>y"},
+		{"unterminated code", "```\n>a", `<pre><code>&gt;a</code></pre>`},
+		{"crlf", "a\r\n>b", "a\n" + `<span class="greentext">&gt;b</span>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := services.RenderMarkup(tt.in, evenLinker); got != tt.want {
+				t.Errorf("RenderMarkup(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractQuoteIDs(t *testing.T) {
+	got := services.ExtractQuoteIDs(">>5 and >>3\n```\n>>8\n```\n>>5 >>0")
+	if want := []int{5, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractQuoteIDs = %v, want %v", got, want)
+	}
+}
+
+func TestMarkupTeaser(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		limit int
+		want  string
+	}{
+		{"plain", "hello world", 100, "hello world"},
+		{"collapses whitespace", "a\n\n  b\tc", 100, "a b c"},
+		{"hides spoiler", "the end: [spoiler]he dies[/spoiler] wow", 100, "the end: [spoiler] wow"},
+		{"unclosed spoiler", "x [spoiler]secret\ny", 100, "x [spoiler] y"},
+		{"stray close", "b[/spoiler]", 100, "b[/spoiler]"},
+		{"keeps quotes", ">>12 >green", 100, ">>12 >green"},
+		{"drops code", "look\n```\nsecret code\n```\ndone", 100, "look done"},
+		{"exact length", "one two", 7, "one two"},
+		{"cuts at word", "one two three four", 10, "one two…"},
+		{"cuts long word", "abcdefghijklmnop", 10, "abcdefghij…"},
+		{"counts runes", "привет мир и все", 10, "привет мир…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := services.MarkupTeaser(tt.in, tt.limit); got != tt.want {
+				t.Errorf("MarkupTeaser(%q, %d) = %q, want %q", tt.in, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+var (
+	// allowedTag matches every tag RenderMarkup may emit
+	allowedTag = regexp.MustCompile(`^(?:<span class="(?:greentext|spoiler|deadlink)">|</span>|<a class="quotelink" href="(?:#c\d+|/post/\d+)">|</a>|<pre><code>|</code></pre>)`)
+	// entity matches the escapes produced by html.EscapeString
+	entity = regexp.MustCompile(`^&(?:amp|lt|gt|#34|#39);`)
+)
+
+// checkSafeHTML fails if out contains anything but the renderer's own,
+// balanced tags and escaped text.
+func checkSafeHTML(t *testing.T, in, out string) {
+	t.Helper()
+	var stack []string
+	for i := 0; i < len(out); {
+		switch out[i] {
+		case '<':
+			tag := allowedTag.FindString(out[i:])
+			if tag == "" {
+				t.Fatalf("unexpected tag at %d in %q (input %q)", i, out, in)
+			}
+			switch {
+			case tag == "</span>" || tag == "</a>" || tag == "</code></pre>":
+				if len(stack) == 0 || stack[len(stack)-1] != tag {
+					t.Fatalf("unbalanced %s at %d in %q (input %q)", tag, i, out, in)
+				}
+				stack = stack[:len(stack)-1]
+			case strings.HasPrefix(tag, "<span"):
+				stack = append(stack, "</span>")
+			case strings.HasPrefix(tag, "<a"):
+				stack = append(stack, "</a>")
+			default:
+				stack = append(stack, "</code></pre>")
+			}
+			i += len(tag)
+		case '&':
+			e := entity.FindString(out[i:])
+			if e == "" {
+				t.Fatalf("unescaped & at %d in %q (input %q)", i, out, in)
+			}
+			i += len(e)
+		case '>', '"', '\'':
+			t.Fatalf("unescaped %q at %d in %q (input %q)", out[i], i, out, in)
+		default:
+			i++
+		}
+	}
+	if len(stack) > 0 {
+		t.Fatalf("unclosed tags %v in %q (input %q)", stack, out, in)
+	}
+}
+
+func FuzzRenderMarkup(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		">greentext",
+		">>12 >>13 >>14",
+		"[spoiler][spoiler]x[/spoiler]",
+		"```\n<b>\n```",
+		`<img src=x onerror="alert(1)">`,
+		"&lt; &amp; &#x3c;",
+		"[spoiler]<a href='javascript:alert(1)'>[/spoiler]",
+		">>99999999999999999999",
+		">\n>>\n```",
+		"\r\n>\r\n",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		checkSafeHTML(t, in, services.RenderMarkup(in, evenLinker))
+		checkSafeHTML(t, in, services.RenderMarkup(in, nil))
+	})
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// ModSessionTTL is how long a moderator stays logged in.
+const ModSessionTTL = 12 * time.Hour
+
+// ModerationService authenticates moderators and applies moderation actions.
+type ModerationService struct {
+	ModeratorRepo  ports.ModeratorRepository
+	ModerationRepo ports.ModerationRepository
+}
+
+// NewModerationService creates a new instance of ModerationService.
+func NewModerationService(moderatorRepo ports.ModeratorRepository, moderationRepo ports.ModerationRepository) *ModerationService {
+	return &ModerationService{
+		ModeratorRepo:  moderatorRepo,
+		ModerationRepo: moderationRepo,
+	}
+}
+
+// EnsureModerator creates or updates a moderator account. It is used to
+// bootstrap the first admin from configuration.
+func (s *ModerationService) EnsureModerator(name, password string, role models.ModeratorRole) error {
+	if name == "" || password == "" {
+		return fmt.Errorf("moderator name and password are required: %w", models.ErrInvalidInput)
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.ModeratorRepo.UpsertModerator(&models.Moderator{Name: name, Role: role, PasswordHash: hash})
+}
+
+// Login checks the credentials and returns a new session token.
+func (s *ModerationService) Login(name, password string) (string, error) {
+	moderator, err := s.ModeratorRepo.GetModeratorByName(name)
+	if err != nil {
+		return "", err
+	}
+	if moderator == nil || !CheckPassword(password, moderator.PasswordHash) {
+		slog.Warn("Failed moderator login", "name", name)
+		return "", fmt.Errorf("invalid moderator credentials: %w", models.ErrUnauthorized)
+	}
+
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := hex.EncodeToString(raw[:])
+
+	if err := s.ModeratorRepo.CreateModSession(token, moderator.ID, time.Now().Add(ModSessionTTL)); err != nil {
+		return "", err
+	}
+	slog.Info("Moderator logged in", "name", moderator.Name)
+	return token, nil
+}
+
+// Logout ends a moderator session.
+func (s *ModerationService) Logout(token string) error {
+	return s.ModeratorRepo.DeleteModSession(token)
+}
+
+// Authenticate returns the moderator owning token, if the session is valid.
+func (s *ModerationService) Authenticate(token string) (*models.Moderator, bool) {
+	if token == "" {
+		return nil, false
+	}
+	moderator, err := s.ModeratorRepo.GetModeratorBySession(token, time.Now())
+	if err != nil || moderator == nil {
+		return nil, false
+	}
+	return moderator, true
+}
+
+// Act applies a moderation action on behalf of moderator and records it in
+// the audit log. A reason is required.
+func (s *ModerationService) Act(moderator *models.Moderator, actionType models.ModActionType, targetID int, reason string) (*models.ModAction, error) {
+	if moderator == nil {
+		return nil, fmt.Errorf("moderator required: %w", models.ErrUnauthorized)
+	}
+	if !actionType.Valid() {
+		return nil, fmt.Errorf("unknown action %q: %w", actionType, models.ErrInvalidInput)
+	}
+	reason = strings.TrimSpace(reason)
+	if targetID <= 0 || reason == "" {
+		return nil, fmt.Errorf("target and reason are required: %w", models.ErrInvalidInput)
+	}
+
+	action := &models.ModAction{
+		ModeratorID:   moderator.ID,
+		ModeratorName: moderator.Name,
+		Action:        actionType,
+		TargetType:    actionType.TargetType(),
+		TargetID:      targetID,
+		Reason:        reason,
+		CreatedAt:     time.Now(),
+	}
+	if err := s.ModerationRepo.ApplyAction(action); err != nil {
+		slog.Error("Failed to apply moderation action", "action", actionType, "targetID", targetID, "error", err)
+		return nil, err
+	}
+	return action, nil
+}
+
+// GetAuditLog returns the latest moderation actions.
+func (s *ModerationService) GetAuditLog(limit int) ([]*models.ModAction, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.ModerationRepo.GetAuditLog(limit)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"time"
@@ -9,57 +10,178 @@ import (
 	"1337b04rd/internal/app/domain/ports"
 )
 
+// catalogTeaserLength is the length of the text preview in listings.
+const catalogTeaserLength = 150
+
 // PostService provides operations for managing posts.
 type PostService struct {
 	PostRepository ports.PostRepository
 	SessionRepo    ports.SessionRepository
+	Boards         *BoardService     // Needed to create threads; nil archives and prunes nothing
+	Duplicates     *DuplicateService // nil disables duplicate detection
+	Filters        *FilterService    // nil stores text as submitted
+	Tripcodes      *Tripcoder        // nil ignores the name field
+	PosterIDs      *PosterIDs        // Used on boards with poster IDs enabled
+	Images         *ImageService     // nil leaves the images of purged threads in storage
 }
 
 // NewPostService creates a new instance of PostService.
-func NewPostService(postRepo ports.PostRepository, sessionRepo ports.SessionRepository) *PostService {
+func NewPostService(postRepo ports.PostRepository, sessionRepo ports.SessionRepository, boards *BoardService) *PostService {
 	return &PostService{
 		PostRepository: postRepo,
 		SessionRepo:    sessionRepo,
+		Boards:         boards,
 	}
 }
 
-// CreatePost creates a new post using session data.
-func (s *PostService) CreatePost(sessionID, title, text, imageURL string) (*models.Post, error) {
+// CreatePost creates a new thread on a board using session data. An empty
+// boardSlug means the default board. name is the optional "name#password"
+// field of the form. images may be empty unless the board requires an image;
+// each one is checked against the image rules of the board, and they become
+// the attachments of the thread in the given order.
+func (s *PostService) CreatePost(sessionID, boardSlug, name, title, text string, images []*models.UploadedImage) (*models.Post, error) {
 	userData, ok := s.SessionRepo.GetSessionData(sessionID)
 	if !ok {
 		slog.Warn("Session not found", "SessionID", sessionID)
 		return nil, fmt.Errorf("session not found")
 	}
 
+	board, err := s.getBoard(boardSlug)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkThreadImages(board, images); err != nil {
+		return nil, err
+	}
+
+	userName, tripcode := userData.Name, ""
+	if s.Tripcodes != nil && name != "" {
+		var err error
+		if userName, tripcode, err = s.Tripcodes.Identity(name, userData.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Filters != nil {
+		var err error
+		if title, err = s.Filters.ApplyTitle(title); err != nil {
+			return nil, err
+		}
+		if text, err = s.Filters.Apply(text); err != nil {
+			return nil, err
+		}
+	}
+
 	post := &models.Post{
+		Board:      board.Slug,
 		Title:      title,
 		Text:       text,
-		UserName:   userData.Name,
+		UserName:   userName,
+		Tripcode:   tripcode,
 		UserAvatar: userData.Avatar,
-		ImageURL:   imageURL,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
+	post.SetAttachments(models.NewAttachments(images))
+
+	flagged := false
+	if s.Duplicates != nil {
+		post.Fingerprint = s.Duplicates.Fingerprint(text, post.Attachments)
+		var err error
+		if flagged, err = s.Duplicates.Check(post.Fingerprint); err != nil {
+			return nil, err
+		}
+	}
 
-	createdPost, err := s.PostRepository.CreatePost(post)
+	if s.PosterIDs != nil && board.PosterIDs {
+		// The poster ID depends on the thread ID, so it is reserved up front
+		id, err := s.PostRepository.NextPostID()
+		if err != nil {
+			return nil, err
+		}
+		post.ID = id
+		post.PosterID = s.PosterIDs.For(sessionID, id)
+	}
+
+	createdPost, err := s.PostRepository.CreatePost(post, board.MaxThreads)
 	if err != nil {
 		slog.Error("Failed to create post", "error", err)
 		return nil, err
 	}
+	if flagged {
+		s.Duplicates.Flag("post", createdPost.ID)
+	}
 
 	slog.Info("Post created successfully", "PostID", createdPost.ID, "UserName", post.UserName)
 	return createdPost, nil
 }
 
-// GetAllPosts retrieves all posts.
-func (s *PostService) GetAllPosts() ([]*models.Post, error) {
-	posts, err := s.PostRepository.GetAllPosts()
+// CheckImages applies the image rules of a board, bans and duplicate
+// detection to the images of a new thread, so that uploads it would refuse
+// are not stored. It is called with no images too, for boards that require
+// one. CreatePost applies the rules again.
+func (s *PostService) CheckImages(boardSlug string, images []*models.UploadedImage) error {
+	board, err := s.getBoard(boardSlug)
+	if err != nil {
+		return err
+	}
+	if err := checkThreadImages(board, images); err != nil {
+		return err
+	}
+	if s.Duplicates != nil && len(images) > 0 {
+		return s.Duplicates.CheckImages(images)
+	}
+	return nil
+}
+
+// getBoard looks up the board a new thread goes to.
+func (s *PostService) getBoard(slug string) (*models.Board, error) {
+	if s.Boards == nil {
+		slog.Error("Post service has no boards to post to")
+		return nil, fmt.Errorf("no boards to post to")
+	}
+	return s.Boards.GetBoard(slug)
+}
+
+// checkThreadImages applies checkImages to the images of a new thread and
+// refuses threads without one on boards that require it.
+func checkThreadImages(board *models.Board, images []*models.UploadedImage) error {
+	if board.RequireImage && len(images) == 0 {
+		return fmt.Errorf("threads on /%s/ need an image: %w", board.Slug, models.ErrInvalidInput)
+	}
+	return checkImages(board, images)
+}
+
+// checkImages refuses images the formats of board exclude, or any image on a
+// text-only board.
+func checkImages(board *models.Board, images []*models.UploadedImage) error {
+	for _, image := range images {
+		if err := board.CheckImage(image.Format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAllPosts retrieves one page of the active posts of a board.
+func (s *PostService) GetAllPosts(board string, page models.PageRequest) (*models.PostPage, error) {
+	result, err := s.PostRepository.GetAllPosts(board, page)
 	if err != nil {
 		slog.Error("Failed to fetch posts", "error", err)
 		return nil, fmt.Errorf("failed to fetch posts: %w", err)
 	}
-	slog.Info("All posts retrieved", "Count", len(posts))
-	return posts, nil
+	setTeasers(result)
+	slog.Info("Posts page retrieved", "Count", len(result.Posts))
+	return result, nil
+}
+
+// setTeasers fills in the text previews of a listing.
+func setTeasers(page *models.PostPage) {
+	for _, posts := range [][]*models.Post{page.Pinned, page.Posts} {
+		for _, post := range posts {
+			post.Teaser = MarkupTeaser(post.Text, catalogTeaserLength)
+		}
+	}
 }
 
 // GetPostByID retrieves a post by its ID.
@@ -73,68 +195,195 @@ func (s *PostService) GetPostByID(id string) (*models.Post, error) {
 	return post, nil
 }
 
-// StartArchiver runs a background job that periodically checks posts for archiving.
-func (s *PostService) StartArchiver() {
-	go func() {
-		ticker := time.NewTicker(1 * time.Minute)
-		defer ticker.Stop()
+// ArchiveExpiredPosts archives every live thread whose TTL has run out and
+// returns how many threads were archived. Each board uses its own TTLs;
+// those it leaves unset come from fallback.
+func (s *PostService) ArchiveExpiredPosts(ctx context.Context, fallback models.ArchivePolicy) (int, error) {
+	if s.Boards == nil {
+		return 0, nil
+	}
+	boards, err := s.Boards.GetBoards()
+	if err != nil {
+		slog.Error("Failed to list boards for archiving", "error", err)
+		return 0, fmt.Errorf("failed to archive expired posts: %w", err)
+	}
 
-		for {
-			<-ticker.C
+	total := 0
+	now := time.Now()
+	for _, board := range boards {
+		archived, err := s.PostRepository.ArchiveExpiredPosts(ctx, board.Slug, now, board.ArchivePolicy(fallback))
+		if err != nil {
+			slog.Error("Failed to archive expired posts", "Board", board.Slug, "error", err)
+			return total, fmt.Errorf("failed to archive expired posts: %w", err)
+		}
+		if archived > 0 {
+			slog.Info("Expired posts archived", "Board", board.Slug, "Count", archived)
+		}
+		total += archived
+	}
+	return total, nil
+}
 
-			posts, err := s.PostRepository.GetAllPosts()
-			if err != nil {
-				slog.Error("Error fetching posts for archiving", "error", err)
-				continue
-			}
+// PruneBoards archives the least recently bumped threads of every board that
+// holds more live threads than its MaxThreads and returns how many threads
+// were archived. New threads prune their board as they are created; this
+// catches up boards whose cap was lowered.
+func (s *PostService) PruneBoards(ctx context.Context) (int, error) {
+	if s.Boards == nil {
+		return 0, nil
+	}
+	boards, err := s.Boards.GetBoards()
+	if err != nil {
+		slog.Error("Failed to list boards for pruning", "error", err)
+		return 0, fmt.Errorf("failed to prune boards: %w", err)
+	}
 
-			now := time.Now()
+	total := 0
+	now := time.Now()
+	for _, board := range boards {
+		if board.MaxThreads <= 0 {
+			continue
+		}
+		pruned, err := s.PostRepository.PruneThreads(ctx, board.Slug, board.MaxThreads, now)
+		if err != nil {
+			slog.Error("Failed to prune threads", "Board", board.Slug, "error", err)
+			return total, fmt.Errorf("failed to prune boards: %w", err)
+		}
+		if pruned > 0 {
+			slog.Info("Threads over the board cap archived", "Board", board.Slug, "Count", pruned)
+		}
+		total += pruned
+	}
+	return total, nil
+}
 
-			for _, post := range posts {
-				if post.ArchivedAt != nil {
-					continue
-				}
+// PurgeArchivedPosts deletes the threads that have been in the archive longer
+// than policy.Retention, queues the images nothing else uses for deletion,
+// and logs a summary. In a dry run only the summary is produced. A zero
+// Retention keeps archived threads forever.
+func (s *PostService) PurgeArchivedPosts(ctx context.Context, policy models.PurgePolicy) (*models.PurgeSummary, error) {
+	if policy.Retention <= 0 {
+		return &models.PurgeSummary{}, nil
+	}
 
-				shouldArchive := false
-
-				if len(post.Comments) == 0 {
-					if post.CreatedAt.Add(10 * time.Minute).Before(now) {
-						shouldArchive = true
-					}
-				} else {
-					var lastCommentTime time.Time
-					for _, c := range post.Comments {
-						if c.CreatedAt.After(lastCommentTime) {
-							lastCommentTime = c.CreatedAt
-						}
-					}
-					if lastCommentTime.Add(15 * time.Minute).Before(now) {
-						shouldArchive = true
-					}
-				}
+	summary, err := s.PostRepository.PurgeArchivedPosts(ctx, time.Now().Add(-policy.Retention), policy.DryRun)
+	if err != nil {
+		slog.Error("Failed to purge archived posts", "error", err)
+		return nil, fmt.Errorf("failed to purge archived posts: %w", err)
+	}
+	if len(summary.ThreadIDs) == 0 {
+		return summary, nil
+	}
 
-				if shouldArchive {
-					err := s.PostRepository.ArchivePost(post.ID)
-					if err != nil {
-						slog.Error("Failed to archive post", "PostID", post.ID, "error", err)
-					} else {
-						slog.Info("Post archived", "PostID", post.ID)
-					}
+	if policy.DryRun {
+		slog.Info("Archive purge dry run", "Threads", len(summary.ThreadIDs), "Comments", summary.Comments,
+			"Images", len(summary.Images), "ThreadIDs", summary.ThreadIDs, "ImageURLs", summary.Images)
+		return summary, nil
+	}
+
+	queued := 0
+	if s.Images != nil {
+		// The rows are gone already; a failure only leaves orphaned objects
+		if err := s.Images.Delete(ctx, summary.Images); err == nil {
+			queued = len(summary.Images)
+		}
+	}
+	slog.Info("Archive purged", "Threads", len(summary.ThreadIDs), "Comments", summary.Comments,
+		"ImagesQueued", queued)
+	return summary, nil
+}
+
+// MigrateImageKeys moves the images of posts and comments that are still
+// stored under client file names to content-addressed keys, one image at a
+// time, and queues the old objects and thumbnails for deletion. Images that
+// cannot be moved are logged and left as they are, so running it again
+// retries them. Only one process migrates at a time; the others get
+// ErrConflict. Returns how many images were moved.
+func (s *PostService) MigrateImageKeys(ctx context.Context) (int, error) {
+	if s.Images == nil {
+		return 0, nil
+	}
+	unlock, err := s.Images.Repo.LockImageMigration(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start image key migration: %w", err)
+	}
+	defer unlock()
+
+	urls, err := s.PostRepository.LegacyImageURLs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list images to migrate: %w", err)
+	}
+	slog.Info("Migrating image keys", "Images", len(urls))
+
+	migrated, failed := 0, 0
+	for _, url := range urls {
+		if ctx.Err() != nil {
+			break
+		}
+		// The new key may already be queued for deletion, for instance by a
+		// refused upload of the same image
+		image, err := s.Images.Storage.RekeyImage(ctx, url, func(image *models.UploadedImage) error {
+			return s.Images.Keep(ctx, []*models.UploadedImage{image})
+		})
+		if err != nil {
+			slog.Error("Failed to rekey image", "URL", url, "error", err)
+			failed++
+			continue
+		}
+		thumbnails, err := s.PostRepository.ReplaceImageURL(ctx, url, image)
+		if err != nil {
+			slog.Error("Failed to update image URL", "URL", url, "error", err)
+			s.Images.Delete(ctx, []string{image.URL, image.ThumbnailURL})
+			failed++
+			continue
+		}
+
+		// Nothing should refer to the old objects any more; the sweep checks
+		s.Images.Delete(ctx, append([]string{url}, thumbnails...))
+		migrated++
+	}
+
+	slog.Info("Image keys migrated", "Migrated", migrated, "Failed", failed)
+	return migrated, ctx.Err()
+}
+
+// StartArchiver runs ArchiveExpiredPosts, PruneBoards, PurgeArchivedPosts
+// and the sweep of queued image deletions every interval in the background
+// until ctx is cancelled. policy applies to boards without their own TTLs.
+func (s *PostService) StartArchiver(ctx context.Context, policy models.ArchivePolicy, purge models.PurgePolicy, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		slog.Info("Archiver started", "Interval", interval, "NoReplyTTL", policy.NoReplyTTL, "ReplyTTL", policy.ReplyTTL,
+			"Retention", purge.Retention, "PurgeDryRun", purge.DryRun)
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("Archiver stopped")
+				return
+			case <-ticker.C:
+				s.ArchiveExpiredPosts(ctx, policy)
+				s.PruneBoards(ctx)
+				s.PurgeArchivedPosts(ctx, purge)
+				if s.Images != nil {
+					s.Images.Sweep(ctx)
 				}
 			}
 		}
 	}()
 }
 
-// GetArchivedPosts returns all archived posts.
-func (s *PostService) GetArchivedPosts() ([]*models.Post, error) {
-	posts, err := s.PostRepository.GetArchivedPosts()
+// GetArchivedPosts returns one page of the archived posts of a board.
+func (s *PostService) GetArchivedPosts(board string, page models.PageRequest) (*models.PostPage, error) {
+	result, err := s.PostRepository.GetArchivedPosts(board, page)
 	if err != nil {
 		slog.Error("Failed to fetch archived posts", "error", err)
 		return nil, err
 	}
-	slog.Info("Archived posts retrieved", "Count", len(posts))
-	return posts, nil
+	setTeasers(result)
+	slog.Info("Archived posts page retrieved", "Count", len(result.Posts))
+	return result, nil
 }
 
 // GetArchivedPostByID retrieves an archived post by its ID.
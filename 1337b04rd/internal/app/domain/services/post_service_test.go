@@ -0,0 +1,103 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+// newTestPostService returns a PostService for session "s1" on the given
+// boards besides /b/.
+func newTestPostService(boards ...*models.Board) (*services.PostService, *fakePosts) {
+	posts := &fakePosts{}
+	sessions := fakeSessions{"s1": {Name: "Rick", Avatar: "rick.png"}}
+	return services.NewPostService(posts, sessions, services.NewBoardService(newFakeBoards(boards...), "b")), posts
+}
+
+func TestCreatePost_FiltersTitle(t *testing.T) {
+	service, posts := newTestPostService()
+	service.Filters = services.NewFilterService(&fakeFilterRules{rules: []*models.FilterRule{
+		rule(models.FilterBanWord, "scam", ""),
+		rule(models.FilterReplace, "heck", "h*ck"),
+	}})
+	if err := service.Filters.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	post, err := service.CreatePost("s1", "", "", "what the heck", "some text", nil)
+	if err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+	if post.Title != "what the h*ck" {
+		t.Errorf("title = %q, want the replacement applied", post.Title)
+	}
+
+	if _, err := service.CreatePost("s1", "", "", "free scam", "some text", nil); !errors.Is(err, models.ErrInvalidInput) {
+		t.Errorf("title with a banned word: error = %v, want ErrInvalidInput", err)
+	}
+	if len(posts.created) != 1 {
+		t.Errorf("%d posts stored, want 1", len(posts.created))
+	}
+}
+
+func TestCreatePost_BoardRules(t *testing.T) {
+	service, posts := newTestPostService(
+		&models.Board{Slug: "text", TextOnly: true},
+		&models.Board{Slug: "pics", RequireImage: true},
+		&models.Board{Slug: "jpg", ImageFormats: []models.ImageFormat{models.ImageJPEG}},
+	)
+	png := []*models.UploadedImage{{URL: "/img/a.png", Format: models.ImagePNG}}
+	jpeg := []*models.UploadedImage{{URL: "/img/a.jpg", Format: models.ImageJPEG}}
+
+	tests := []struct {
+		name   string
+		board  string
+		images []*models.UploadedImage
+		want   error
+	}{
+		{"image on a text-only board", "text", png, models.ErrInvalidInput},
+		{"text on a text-only board", "text", nil, nil},
+		{"thread without the required image", "pics", nil, models.ErrInvalidInput},
+		{"thread with the required image", "pics", png, nil},
+		{"format the board excludes", "jpg", png, models.ErrUnsupportedMedia},
+		{"format the board allows", "jpg", jpeg, nil},
+		{"unknown board", "nope", nil, models.ErrNotFound},
+	}
+	for _, tt := range tests {
+		// The handlers run CheckImages before storing the uploads, and
+		// CreatePost applies the same rules
+		if err := service.CheckImages(tt.board, tt.images); !errors.Is(err, tt.want) {
+			t.Errorf("%s: CheckImages error = %v, want %v", tt.name, err, tt.want)
+		}
+		if _, err := service.CreatePost("s1", tt.board, "", "title", "some text", tt.images); !errors.Is(err, tt.want) {
+			t.Errorf("%s: CreatePost error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if len(posts.created) != 3 {
+		t.Errorf("%d posts stored, want the 3 allowed", len(posts.created))
+	}
+}
+
+func TestPostService_NoBoards(t *testing.T) {
+	service, posts := newTestPostService()
+	service.Boards = nil
+
+	if err := service.CheckImages("b", nil); err == nil {
+		t.Error("CheckImages without boards succeeded")
+	}
+	if _, err := service.CreatePost("s1", "b", "", "title", "some text", nil); err == nil {
+		t.Error("CreatePost without boards succeeded")
+	}
+	if len(posts.created) != 0 {
+		t.Errorf("%d posts stored without boards", len(posts.created))
+	}
+	if n, err := service.ArchiveExpiredPosts(context.Background(), models.ArchivePolicy{}); n != 0 || err != nil {
+		t.Errorf("ArchiveExpiredPosts = %d, %v, want nothing archived", n, err)
+	}
+	if n, err := service.PruneBoards(context.Background()); n != 0 || err != nil {
+		t.Errorf("PruneBoards = %d, %v, want nothing pruned", n, err)
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+const posterIDLength = 8
+
+// PosterIDs derives the short per-thread ID shown next to posts and comments.
+// The same session gets the same ID within a thread and unrelated IDs in
+// different threads, so replies can be told apart without linking sessions
+// across the board.
+type PosterIDs struct {
+	secret []byte
+}
+
+// NewPosterIDs creates a PosterIDs. An empty secret is replaced by a random
+// one; IDs already stored keep working, but a session posting in the same
+// thread after a restart gets a new ID.
+func NewPosterIDs(secret string) *PosterIDs {
+	if secret == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			panic(fmt.Sprintf("poster ID secret: %v", err))
+		}
+		slog.Warn("POSTER_ID_SALT is not set, poster IDs will change after a restart")
+		return &PosterIDs{secret: random}
+	}
+	return &PosterIDs{secret: []byte(secret)}
+}
+
+// For returns the poster ID of a session in a thread.
+func (p *PosterIDs) For(sessionID string, threadID int) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(strconv.Itoa(threadID)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:posterIDLength]
+}
@@ -0,0 +1,87 @@
+package services_test
+
+import (
+	"testing"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+func TestPosterIDs_For(t *testing.T) {
+	ids := services.NewPosterIDs("secret")
+
+	id := ids.For("s1", 1)
+	if len(id) != 8 {
+		t.Errorf("poster ID %q has %d characters, want 8", id, len(id))
+	}
+	if ids.For("s1", 1) != id {
+		t.Error("same session and thread give different IDs")
+	}
+	if ids.For("s2", 1) == id {
+		t.Error("two sessions share an ID in one thread")
+	}
+	if ids.For("s1", 2) == id {
+		t.Error("a session keeps its ID across threads")
+	}
+	if services.NewPosterIDs("other secret").For("s1", 1) == id {
+		t.Error("ID does not depend on the secret")
+	}
+	// The separator keeps "1"+"2x" and "12"+"x" apart
+	if ids.For("2x", 1) == ids.For("x", 12) {
+		t.Error("thread and session run together")
+	}
+}
+
+func TestPosterIDs_ThreadAndReplies(t *testing.T) {
+	boardRepo := newFakeBoards(&models.Board{Slug: "int", Title: "International", PosterIDs: true})
+	boards := services.NewBoardService(boardRepo, "b")
+	posterIDs := services.NewPosterIDs("secret")
+
+	posts := &fakePosts{}
+	postService := services.NewPostService(posts, fakeSessions{"s1": {Name: "Rick"}}, boards)
+	postService.PosterIDs = posterIDs
+	comments := &fakeComments{}
+	commentService := services.NewCommentService(comments)
+	commentService.Boards = boards
+	commentService.PosterIDs = posterIDs
+
+	post, err := postService.CreatePost("s1", "int", "", "thread", "text", nil)
+	if err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+	if post.ID == 0 || post.PosterID != posterIDs.For("s1", post.ID) {
+		t.Errorf("thread %d has poster ID %q, want the one of s1 in it", post.ID, post.PosterID)
+	}
+	boardRepo.postBoards[post.ID] = "int"
+
+	reply := func(sessionID string) string {
+		t.Helper()
+		c, err := commentService.CreateComment(models.Comment{PostID: post.ID, UserName: "Rick", Text: "reply", SessionID: sessionID})
+		if err != nil {
+			t.Fatalf("CreateComment failed: %v", err)
+		}
+		return c.PosterID
+	}
+	if got := reply("s1"); got != post.PosterID {
+		t.Errorf("reply of the thread author has ID %q, want %q", got, post.PosterID)
+	}
+	if got := reply("s2"); got == post.PosterID || got == "" {
+		t.Errorf("reply of another session has ID %q", got)
+	}
+
+	// Boards without poster IDs show none
+	plain, err := postService.CreatePost("s1", "b", "", "thread", "text", nil)
+	if err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+	if plain.PosterID != "" {
+		t.Errorf("thread on /b/ has poster ID %q", plain.PosterID)
+	}
+	c, err := commentService.CreateComment(models.Comment{PostID: plain.ID, UserName: "Rick", Text: "reply", SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+	if c.PosterID != "" {
+		t.Errorf("reply on /b/ has poster ID %q", c.PosterID)
+	}
+}
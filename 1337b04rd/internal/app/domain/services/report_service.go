@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+const maxReportTextLength = 1000
+
+// ReportService lets users flag content and moderators work the report queue.
+type ReportService struct {
+	ReportRepo        ports.ReportRepository
+	ModerationService *ModerationService
+
+	// At most RateLimit reports per session within RateWindow
+	RateLimit  int
+	RateWindow time.Duration
+}
+
+// NewReportService creates a new instance of ReportService with a limit of
+// 5 reports per session every 10 minutes.
+func NewReportService(repo ports.ReportRepository, moderationService *ModerationService) *ReportService {
+	return &ReportService{
+		ReportRepo:        repo,
+		ModerationService: moderationService,
+		RateLimit:         5,
+		RateWindow:        10 * time.Minute,
+	}
+}
+
+// SubmitReport files a report against a post or comment for a session.
+func (s *ReportService) SubmitReport(sessionID, targetType string, targetID int, category models.ReportCategory, text string) (*models.Report, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("session required: %w", models.ErrUnauthorized)
+	}
+	if (targetType != "post" && targetType != "comment") || targetID <= 0 {
+		return nil, fmt.Errorf("invalid report target: %w", models.ErrInvalidInput)
+	}
+	if !category.Valid() {
+		return nil, fmt.Errorf("unknown report category %q: %w", category, models.ErrInvalidInput)
+	}
+	text = strings.TrimSpace(text)
+	if len(text) > maxReportTextLength {
+		return nil, fmt.Errorf("report text longer than %d bytes: %w", maxReportTextLength, models.ErrInvalidInput)
+	}
+
+	now := time.Now()
+	report := &models.Report{
+		TargetType: targetType,
+		TargetID:   targetID,
+		SessionID:  sessionID,
+		Category:   category,
+		Text:       text,
+		Status:     models.ReportOpen,
+		CreatedAt:  now,
+	}
+	if err := s.ReportRepo.CreateReport(report, s.RateLimit, now.Add(-s.RateWindow)); err != nil {
+		if errors.Is(err, models.ErrRateLimited) {
+			slog.Warn("Report rate limit reached", "sessionID", sessionID)
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
+// GetQueue returns open reports grouped by target.
+func (s *ReportService) GetQueue(limit int) ([]*models.ReportGroup, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.ReportRepo.GetOpenReportGroups(limit)
+}
+
+// Dismiss closes all open reports against a target without acting on it.
+func (s *ReportService) Dismiss(moderator *models.Moderator, targetType string, targetID int) (int, error) {
+	if moderator == nil {
+		return 0, fmt.Errorf("moderator required: %w", models.ErrUnauthorized)
+	}
+	n, err := s.ReportRepo.ResolveReports(targetType, targetID, models.ReportDismissed, moderator.ID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	slog.Info("Reports dismissed", "targetType", targetType, "targetID", targetID, "count", n, "moderator", moderator.Name)
+	return n, nil
+}
+
+// Act applies a moderation action to a reported target and closes its reports.
+func (s *ReportService) Act(moderator *models.Moderator, targetType string, targetID int, action models.ModActionType, reason string) (*models.ModAction, error) {
+	if action.TargetType() != targetType {
+		return nil, fmt.Errorf("action %s does not apply to a %s: %w", action, targetType, models.ErrInvalidInput)
+	}
+
+	applied, err := s.ModerationService.Act(moderator, action, targetID, reason)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ReportRepo.ResolveReports(targetType, targetID, models.ReportActioned, moderator.ID, applied.CreatedAt); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// SearchService runs full-text searches over live and archived threads.
+type SearchService struct {
+	SearchRepo ports.SearchRepository
+}
+
+// NewSearchService creates a new instance of SearchService.
+func NewSearchService(searchRepo ports.SearchRepository) *SearchService {
+	return &SearchService{SearchRepo: searchRepo}
+}
+
+// Search validates query, runs it and returns one page of results with
+// HTML snippets. Limit is clamped to [1, models.MaxPageSize]; offsets past
+// models.MaxSearchOffset are rejected.
+func (s *SearchService) Search(ctx context.Context, query models.SearchQuery) (*models.SearchPage, error) {
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" {
+		return nil, fmt.Errorf("search query is empty: %w", models.ErrInvalidInput)
+	}
+	if utf8.RuneCountInString(query.Text) > models.MaxSearchQueryLength {
+		return nil, fmt.Errorf("search query longer than %d characters: %w", models.MaxSearchQueryLength, models.ErrInvalidInput)
+	}
+	// The highlight runes are reserved for the snippets
+	query.Text = strings.NewReplacer(models.HighlightStart, "", models.HighlightStop, "").Replace(query.Text)
+	if query.Scope == "" {
+		query.Scope = models.SearchAll
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, fmt.Errorf("search date range is empty: %w", models.ErrInvalidInput)
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+	if query.Offset > models.MaxSearchOffset {
+		return nil, fmt.Errorf("search offset past %d: %w", models.MaxSearchOffset, models.ErrInvalidInput)
+	}
+	if query.Limit <= 0 {
+		query.Limit = models.DefaultPageSize
+	}
+	if query.Limit > models.MaxPageSize {
+		query.Limit = models.MaxPageSize
+	}
+
+	// One extra result tells whether there is a next page
+	limit := query.Limit
+	query.Limit++
+	results, err := s.SearchRepo.Search(ctx, query)
+	if err != nil {
+		slog.Error("Search failed", "Query", query.Text, "error", err)
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	page := &models.SearchPage{Results: results}
+	if len(results) > limit {
+		page.Results = results[:limit]
+		if next := query.Offset + limit; next <= models.MaxSearchOffset {
+			page.NextOffset = next
+		}
+	}
+	for i := range page.Results {
+		page.Results[i].SnippetHTML = highlightSnippet(page.Results[i].Snippet)
+	}
+	if page.Results == nil {
+		page.Results = []models.SearchResult{}
+	}
+
+	slog.Info("Search completed", "Query", query.Text, "Count", len(page.Results))
+	return page, nil
+}
+
+// highlightSnippet escapes a snippet and turns its highlight runes into
+// <mark> tags. Stray runes are dropped, so the tags always balance.
+func highlightSnippet(snippet string) string {
+	var b strings.Builder
+	open := false
+	for {
+		i := strings.IndexAny(snippet, models.HighlightStart+models.HighlightStop)
+		if i < 0 {
+			break
+		}
+		b.WriteString(html.EscapeString(snippet[:i]))
+		r, size := utf8.DecodeRuneInString(snippet[i:])
+		switch {
+		case string(r) == models.HighlightStart && !open:
+			b.WriteString("<mark>")
+			open = true
+		case string(r) == models.HighlightStop && open:
+			b.WriteString("</mark>")
+			open = false
+		}
+		snippet = snippet[i+size:]
+	}
+	b.WriteString(html.EscapeString(snippet))
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
@@ -0,0 +1,179 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/adapters/memory"
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+var searchEpoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestSearch fills an in-memory repository with two boards:
+//
+//	1 /b/ live      "Golang generics"       with image, comments 10 and 11
+//	2 /b/ archived  "Old thread about go"   comment 20 (with image)
+//	3 /g/ live      "Rust vs Go"
+//	4 /b/ hidden    "Hidden go thread"
+func newTestSearch() *services.SearchService {
+	archivedAt := searchEpoch.Add(48 * time.Hour)
+	repo := memory.NewSearchRepositoryMem()
+	repo.AddPost(models.Post{ID: 1, Board: "b", Title: "Golang generics", Text: "Are generics in go worth it?", ImageURL: "http://img/1.png", CreatedAt: searchEpoch})
+	repo.AddPost(models.Post{ID: 2, Board: "b", Title: "Old thread about go", Text: "<script>alert(1)</script> go away", CreatedAt: searchEpoch.Add(-24 * time.Hour), ArchivedAt: &archivedAt})
+	repo.AddPost(models.Post{ID: 3, Board: "g", Title: "Rust vs Go", Text: "Fight!", CreatedAt: searchEpoch.Add(time.Hour)})
+	repo.AddPost(models.Post{ID: 4, Board: "b", Title: "Hidden go thread", Text: "go", CreatedAt: searchEpoch, IsHidden: true})
+	repo.AddComment(models.Comment{ID: 10, PostID: 1, Text: "I write go every day", CreatedAt: searchEpoch.Add(2 * time.Hour)})
+	repo.AddComment(models.Comment{ID: 11, PostID: 1, Text: "Python is fine too", CreatedAt: searchEpoch.Add(3 * time.Hour)})
+	repo.AddComment(models.Comment{ID: 20, PostID: 2, Text: "go go go", ImageURL: "http://img/20.png", CreatedAt: searchEpoch.Add(-23 * time.Hour)})
+	return services.NewSearchService(repo)
+}
+
+// resultIDs lists results as "post" or "post#comment".
+func resultIDs(page *models.SearchPage) []string {
+	var ids []string
+	for _, r := range page.Results {
+		id := strings.TrimPrefix(r.URL(), "/archived")
+		ids = append(ids, strings.TrimPrefix(id, "/post/"))
+	}
+	return ids
+}
+
+func TestSearch_Filters(t *testing.T) {
+	search := newTestSearch()
+
+	tests := []struct {
+		name  string
+		query models.SearchQuery
+		want  string
+	}{
+		{"all", models.SearchQuery{Text: "go"}, "1 1#c10 2 2#c20 3"},
+		{"excluded word", models.SearchQuery{Text: "go -generics"}, "1#c10 2 2#c20 3"},
+		{"every word required", models.SearchQuery{Text: "rust go"}, "3"},
+		{"live", models.SearchQuery{Text: "go", Scope: models.SearchLive}, "1 1#c10 3"},
+		{"archived", models.SearchQuery{Text: "go", Scope: models.SearchArchived}, "2 2#c20"},
+		{"board", models.SearchQuery{Text: "go", Board: "g"}, "3"},
+		{"has image", models.SearchQuery{Text: "go", HasImage: true}, "1 2#c20"},
+		{"date range", models.SearchQuery{Text: "go", From: searchEpoch, To: searchEpoch.Add(2 * time.Hour)}, "1 3"},
+		{"no match", models.SearchQuery{Text: "haskell"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := search.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			ids := resultIDs(page)
+			// Order is checked in TestSearch_Ranking
+			sort.Strings(ids)
+			if got := strings.Join(ids, " "); got != tt.want {
+				t.Errorf("got results %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch_Ranking(t *testing.T) {
+	page, err := newTestSearch().Search(context.Background(), models.SearchQuery{Text: "go"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A title match outranks a text match and "go go go" outranks a single
+	// "go"; equal ranks put the newer match first
+	want := "2 2#c20 3 1#c10 1"
+	if got := strings.Join(resultIDs(page), " "); got != want {
+		t.Errorf("got order %q, want %q", got, want)
+	}
+	for i := 1; i < len(page.Results); i++ {
+		if page.Results[i].Rank > page.Results[i-1].Rank {
+			t.Errorf("result %d ranks higher than result %d", i, i-1)
+		}
+	}
+}
+
+func TestSearch_SnippetIsEscapedAndHighlighted(t *testing.T) {
+	page, err := newTestSearch().Search(context.Background(), models.SearchQuery{Text: "alert", Scope: models.SearchArchived})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(page.Results))
+	}
+	snippet := page.Results[0].SnippetHTML
+	if strings.Contains(snippet, "<script>") {
+		t.Errorf("snippet is not escaped: %q", snippet)
+	}
+	if !strings.Contains(snippet, "&lt;script&gt;<mark>alert</mark>(1)") {
+		t.Errorf("match is not highlighted: %q", snippet)
+	}
+}
+
+func TestSearch_Pagination(t *testing.T) {
+	search := newTestSearch()
+
+	var all []string
+	query := models.SearchQuery{Text: "go", Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination does not end")
+		}
+		page, err := search.Search(context.Background(), query)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(page.Results) > 2 {
+			t.Fatalf("got %d results, want at most 2", len(page.Results))
+		}
+		all = append(all, resultIDs(page)...)
+		if page.NextOffset == 0 {
+			break
+		}
+		query.Offset = page.NextOffset
+	}
+	if got, want := strings.Join(all, " "), "2 2#c20 3 1#c10 1"; got != want {
+		t.Errorf("got %q over all pages, want %q", got, want)
+	}
+}
+
+func TestSearch_InvalidQuery(t *testing.T) {
+	search := newTestSearch()
+
+	for name, query := range map[string]models.SearchQuery{
+		"empty":       {Text: "   "},
+		"too long":    {Text: strings.Repeat("a", models.MaxSearchQueryLength+1)},
+		"empty range": {Text: "go", From: searchEpoch, To: searchEpoch},
+		"deep offset": {Text: "go", Offset: models.MaxSearchOffset + 1},
+	} {
+		if _, err := search.Search(context.Background(), query); !errors.Is(err, models.ErrInvalidInput) {
+			t.Errorf("%s: got %v, want ErrInvalidInput", name, err)
+		}
+	}
+}
+
+func TestSearch_OffsetCap(t *testing.T) {
+	repo := memory.NewSearchRepositoryMem()
+	for id := 1; id <= models.MaxSearchOffset+10; id++ {
+		repo.AddPost(models.Post{ID: id, Board: "b", Title: "go", Text: "go", CreatedAt: searchEpoch})
+	}
+	search := services.NewSearchService(repo)
+
+	page, err := search.Search(context.Background(), models.SearchQuery{Text: "go", Limit: 5, Offset: models.MaxSearchOffset - 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.NextOffset != models.MaxSearchOffset {
+		t.Errorf("next offset = %d, want %d", page.NextOffset, models.MaxSearchOffset)
+	}
+	page, err = search.Search(context.Background(), models.SearchQuery{Text: "go", Limit: 5, Offset: models.MaxSearchOffset})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Results) != 5 || page.NextOffset != 0 {
+		t.Errorf("last page has %d results and next offset %d, want 5 and none", len(page.Results), page.NextOffset)
+	}
+}
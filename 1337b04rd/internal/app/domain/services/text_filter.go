@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// TextFilter checks or rewrites submitted text. Returning an error rejects
+// the submission; errors should wrap models.ErrInvalidInput.
+type TextFilter interface {
+	Filter(text string) (string, error)
+}
+
+// TextFilterFunc adapts a function to TextFilter.
+type TextFilterFunc func(text string) (string, error)
+
+func (f TextFilterFunc) Filter(text string) (string, error) {
+	return f(text)
+}
+
+// FilterPipeline runs filters in order, feeding each the previous output.
+type FilterPipeline []TextFilter
+
+func (p FilterPipeline) Filter(text string) (string, error) {
+	for _, f := range p {
+		var err error
+		if text, err = f.Filter(text); err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
+
+// WithoutLengthLimits returns the pipeline without its length filter, for
+// short fields such as thread titles that the limits are not meant for.
+func (p FilterPipeline) WithoutLengthLimits() FilterPipeline {
+	var kept FilterPipeline
+	for _, f := range p {
+		if _, ok := f.(*lengthFilter); !ok {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// BuildFilterPipeline compiles rules into a pipeline. Banned words are
+// checked on the raw text, then replacements and URL stripping run, and the
+// length limits apply to the final text.
+func BuildFilterPipeline(rules []*models.FilterRule) (FilterPipeline, error) {
+	var banned []string
+	var replacements FilterPipeline
+	urls := &urlFilter{}
+	lengths := &lengthFilter{}
+
+	for _, rule := range rules {
+		if err := ValidateFilterRule(rule); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", rule.ID, err)
+		}
+		switch rule.Kind {
+		case models.FilterBanWord:
+			banned = append(banned, regexp.QuoteMeta(strings.TrimSpace(rule.Pattern)))
+		case models.FilterReplace:
+			re := regexp.MustCompile(rule.Pattern) // Validated above
+			replacement := rule.Replacement
+			replacements = append(replacements, TextFilterFunc(func(text string) (string, error) {
+				return re.ReplaceAllString(text, replacement), nil
+			}))
+		case models.FilterStripURLs:
+			urls.enabled = true
+		case models.FilterAllowDomain:
+			urls.enabled = true
+			urls.allowed = append(urls.allowed, normalizeDomain(rule.Pattern))
+		case models.FilterMinLength:
+			lengths.min, _ = strconv.Atoi(rule.Pattern)
+		case models.FilterMaxLength:
+			lengths.max, _ = strconv.Atoi(rule.Pattern)
+		}
+	}
+
+	var pipeline FilterPipeline
+	if len(banned) > 0 {
+		// Words are delimited by anything that is not a letter or digit, so
+		// that non-ASCII words match too (\b is ASCII-only in RE2)
+		re := regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(?:` + strings.Join(banned, "|") + `)(?:$|[^\p{L}\p{N}])`)
+		pipeline = append(pipeline, TextFilterFunc(func(text string) (string, error) {
+			if re.MatchString(text) {
+				return "", fmt.Errorf("text contains a banned word: %w", models.ErrInvalidInput)
+			}
+			return text, nil
+		}))
+	}
+	pipeline = append(pipeline, replacements...)
+	if urls.enabled {
+		pipeline = append(pipeline, urls)
+	}
+	if lengths.min > 0 || lengths.max > 0 {
+		pipeline = append(pipeline, lengths)
+	}
+	return pipeline, nil
+}
+
+// ValidateFilterRule checks that a rule can be compiled.
+func ValidateFilterRule(rule *models.FilterRule) error {
+	if !rule.Kind.Valid() {
+		return fmt.Errorf("unknown filter kind %q: %w", rule.Kind, models.ErrInvalidInput)
+	}
+	switch rule.Kind {
+	case models.FilterBanWord, models.FilterAllowDomain:
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return fmt.Errorf("%s needs a pattern: %w", rule.Kind, models.ErrInvalidInput)
+		}
+	case models.FilterReplace:
+		if rule.Pattern == "" {
+			return fmt.Errorf("replace needs a pattern: %w", models.ErrInvalidInput)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid regexp %q: %v: %w", rule.Pattern, err, models.ErrInvalidInput)
+		}
+	case models.FilterMinLength, models.FilterMaxLength:
+		if n, err := strconv.Atoi(rule.Pattern); err != nil || n <= 0 {
+			return fmt.Errorf("%s needs a positive number: %w", rule.Kind, models.ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// urlFilter removes links whose host is not in allowed.
+type urlFilter struct {
+	enabled bool
+	allowed []string
+}
+
+func (f *urlFilter) Filter(text string) (string, error) {
+	return urlPattern.ReplaceAllStringFunc(text, func(link string) string {
+		if f.isAllowed(urlHost(link)) {
+			return link
+		}
+		return ""
+	}), nil
+}
+
+func (f *urlFilter) isAllowed(host string) bool {
+	for _, domain := range f.allowed {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlHost extracts the lowercased host name of a matched link.
+func urlHost(link string) string {
+	link = strings.ToLower(link)
+	if i := strings.Index(link, "://"); i >= 0 {
+		link = link[i+3:]
+	}
+	if i := strings.IndexAny(link, "/?#"); i >= 0 {
+		link = link[:i]
+	}
+	if i := strings.LastIndex(link, "@"); i >= 0 {
+		link = link[i+1:]
+	}
+	if i := strings.LastIndex(link, ":"); i >= 0 {
+		link = link[:i]
+	}
+	return link
+}
+
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	return strings.TrimPrefix(domain, "www.")
+}
+
+// lengthFilter enforces length limits in characters; zero means no limit.
+type lengthFilter struct {
+	min, max int
+}
+
+func (f *lengthFilter) Filter(text string) (string, error) {
+	n := utf8.RuneCountInString(strings.TrimSpace(text))
+	if f.min > 0 && n < f.min {
+		return "", fmt.Errorf("text is too short, minimum is %d characters: %w", f.min, models.ErrInvalidInput)
+	}
+	if f.max > 0 && n > f.max {
+		return "", fmt.Errorf("text is too long, maximum is %d characters: %w", f.max, models.ErrInvalidInput)
+	}
+	return text, nil
+}
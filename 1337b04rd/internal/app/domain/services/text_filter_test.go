@@ -0,0 +1,95 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+func rule(kind models.FilterRuleKind, pattern, replacement string) *models.FilterRule {
+	return &models.FilterRule{Kind: kind, Pattern: pattern, Replacement: replacement}
+}
+
+func TestBuildFilterPipeline(t *testing.T) {
+	rules := []*models.FilterRule{
+		rule(models.FilterBanWord, "scam", ""),
+		rule(models.FilterBanWord, "казино", ""),
+		rule(models.FilterReplace, `(?i)heck`, "h*ck"),
+		rule(models.FilterStripURLs, "", ""),
+		rule(models.FilterAllowDomain, "www.Example.org", ""),
+		rule(models.FilterMinLength, "3", ""),
+		rule(models.FilterMaxLength, "40", ""),
+	}
+	pipeline, err := services.BuildFilterPipeline(rules)
+	if err != nil {
+		t.Fatalf("BuildFilterPipeline failed: %v", err)
+	}
+
+	tests := []struct {
+		in   string
+		want string // "" when the text is rejected
+	}{
+		{"what the Heck", "what the h*ck"},
+		{"a SCAM!", ""},
+		{"scams are words of their own", "scams are words of their own"},
+		{"лучшее казино тут", ""},
+		{"see https://evil.com/x now", "see  now"},
+		{"see https://docs.example.org/a ok", "see https://docs.example.org/a ok"},
+		{"www.example.org.evil.com", ""}, // Stripped to nothing, then too short
+		{"hi", ""},
+		{"this text is much longer than forty characters", ""},
+	}
+	for _, tt := range tests {
+		got, err := pipeline.Filter(tt.in)
+		if tt.want == "" {
+			if !errors.Is(err, models.ErrInvalidInput) {
+				t.Errorf("Filter(%q) = %q, %v, want ErrInvalidInput", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Filter(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	// Titles keep every rule but the length limits
+	if got, err := pipeline.WithoutLengthLimits().Filter("hi heck"); err != nil || got != "hi h*ck" {
+		t.Errorf("title filter = %q, %v", got, err)
+	}
+}
+
+func TestBuildFilterPipeline_BannedWordsSeeRawText(t *testing.T) {
+	// The replacement would hide the banned word if it ran first
+	pipeline, err := services.BuildFilterPipeline([]*models.FilterRule{
+		rule(models.FilterReplace, "spam", "ham"),
+		rule(models.FilterBanWord, "spam", ""),
+	})
+	if err != nil {
+		t.Fatalf("BuildFilterPipeline failed: %v", err)
+	}
+	if _, err := pipeline.Filter("buy spam"); !errors.Is(err, models.ErrInvalidInput) {
+		t.Errorf("banned word after a replacement accepted: %v", err)
+	}
+}
+
+func TestValidateFilterRule(t *testing.T) {
+	invalid := []*models.FilterRule{
+		rule("shout", "x", ""),
+		rule(models.FilterBanWord, " ", ""),
+		rule(models.FilterAllowDomain, "", ""),
+		rule(models.FilterReplace, "", "x"),
+		rule(models.FilterReplace, "(", "x"),
+		rule(models.FilterMinLength, "zero", ""),
+		rule(models.FilterMaxLength, "-1", ""),
+	}
+	for _, r := range invalid {
+		if err := services.ValidateFilterRule(r); !errors.Is(err, models.ErrInvalidInput) {
+			t.Errorf("rule %s %q accepted: %v", r.Kind, r.Pattern, err)
+		}
+	}
+	if _, err := services.BuildFilterPipeline(invalid[4:5]); err == nil {
+		t.Error("pipeline built from an invalid rule")
+	}
+}
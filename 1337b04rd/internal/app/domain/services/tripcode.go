@@ -0,0 +1,92 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+const (
+	maxPosterNameLength = 50
+	maxNameFieldLength  = 100 // Name, separator and password together
+	tripcodeLength      = 10
+)
+
+// Tripcoder turns a "name#password" field into a display name and tripcode.
+// Plain tripcodes (name#password) are the same on every board that uses this
+// scheme; secure ones (name##password) are keyed with a server-side salt so
+// they cannot be computed offline.
+type Tripcoder struct {
+	salt []byte
+}
+
+// NewTripcoder creates a Tripcoder. An empty salt is replaced by a random one,
+// which keeps secure tripcodes safe but changes them on every restart.
+func NewTripcoder(salt string) *Tripcoder {
+	if salt == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			panic(fmt.Sprintf("tripcode salt: %v", err))
+		}
+		slog.Warn("TRIPCODE_SALT is not set, secure tripcodes will change after a restart")
+		return &Tripcoder{salt: random}
+	}
+	return &Tripcoder{salt: []byte(salt)}
+}
+
+// Identity parses the name field of a post form. An empty name falls back to
+// the session character. The returned tripcode is empty when no password was
+// given.
+func (t *Tripcoder) Identity(field, fallback string) (name, tripcode string, err error) {
+	if utf8.RuneCountInString(field) > maxNameFieldLength {
+		return "", "", fmt.Errorf("name field longer than %d characters: %w", maxNameFieldLength, models.ErrInvalidInput)
+	}
+	name, password, secure := splitNameField(field)
+
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxPosterNameLength {
+		return "", "", fmt.Errorf("name longer than %d characters: %w", maxPosterNameLength, models.ErrInvalidInput)
+	}
+	// A name such as "Rick !!AbC" would pass for a tripcode
+	if strings.Contains(name, "!") {
+		return "", "", fmt.Errorf("name cannot contain '!', which marks tripcodes: %w", models.ErrInvalidInput)
+	}
+	if name == "" {
+		name = fallback
+	}
+
+	switch {
+	case password == "":
+	case secure:
+		mac := hmac.New(sha256.New, t.salt)
+		mac.Write([]byte(password))
+		tripcode = "!!" + encodeTrip(mac.Sum(nil))
+	default:
+		sum := sha256.Sum256([]byte(password))
+		tripcode = "!" + encodeTrip(sum[:])
+	}
+	return name, tripcode, nil
+}
+
+// splitNameField splits "name#password" and "name##password" at the first '#'.
+func splitNameField(field string) (name, password string, secure bool) {
+	name, password, found := strings.Cut(field, "#")
+	if !found {
+		return field, "", false
+	}
+	if rest, ok := strings.CutPrefix(password, "#"); ok {
+		return name, rest, true
+	}
+	return name, password, false
+}
+
+func encodeTrip(sum []byte) string {
+	return base64.RawURLEncoding.EncodeToString(sum)[:tripcodeLength]
+}
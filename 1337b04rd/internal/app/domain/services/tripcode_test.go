@@ -0,0 +1,102 @@
+package services_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+func TestTripcoder_Identity(t *testing.T) {
+	tripcoder := services.NewTripcoder("salt")
+
+	tests := []struct {
+		field    string
+		wantName string
+		wantTrip string // "!" or "!!" when a tripcode of that kind is expected
+	}{
+		{"", "Morty", ""},
+		{"Rick", "Rick", ""},
+		{"  Rick  ", "Rick", ""},
+		{"Rick#secret", "Rick", "!"},
+		{"Rick##secret", "Rick", "!!"},
+		{"#secret", "Morty", "!"},
+		{"Rick#", "Rick", ""},
+		{"Rick#pass#word", "Rick", "!"},
+	}
+	for _, tt := range tests {
+		name, trip, err := tripcoder.Identity(tt.field, "Morty")
+		if err != nil {
+			t.Errorf("Identity(%q) failed: %v", tt.field, err)
+			continue
+		}
+		if name != tt.wantName {
+			t.Errorf("Identity(%q) name = %q, want %q", tt.field, name, tt.wantName)
+		}
+		switch tt.wantTrip {
+		case "":
+			if trip != "" {
+				t.Errorf("Identity(%q) tripcode = %q, want none", tt.field, trip)
+			}
+		case "!":
+			if len(trip) != 11 || !strings.HasPrefix(trip, "!") || strings.HasPrefix(trip, "!!") {
+				t.Errorf("Identity(%q) tripcode = %q, want a plain one", tt.field, trip)
+			}
+		case "!!":
+			if len(trip) != 12 || !strings.HasPrefix(trip, "!!") {
+				t.Errorf("Identity(%q) tripcode = %q, want a secure one", tt.field, trip)
+			}
+		}
+	}
+}
+
+func TestTripcoder_Hashing(t *testing.T) {
+	a, b := services.NewTripcoder("salt a"), services.NewTripcoder("salt b")
+	trip := func(tc *services.Tripcoder, field string) string {
+		t.Helper()
+		_, trip, err := tc.Identity(field, "Morty")
+		if err != nil {
+			t.Fatalf("Identity(%q) failed: %v", field, err)
+		}
+		return trip
+	}
+
+	// Plain tripcodes depend on the password only
+	if trip(a, "Rick#secret") != trip(b, "Morty#secret") {
+		t.Error("plain tripcode depends on the salt or the name")
+	}
+	if trip(a, "Rick#secret") == trip(a, "Rick#Secret") {
+		t.Error("different passwords give the same plain tripcode")
+	}
+
+	// Secure tripcodes are keyed with the salt
+	if trip(a, "Rick##secret") != trip(a, "Rick##secret") {
+		t.Error("secure tripcode is not stable")
+	}
+	if trip(a, "Rick##secret") == trip(b, "Rick##secret") {
+		t.Error("secure tripcode does not depend on the salt")
+	}
+	if trip(a, "Rick##secret")[2:] == trip(a, "Rick#secret")[1:] {
+		t.Error("secure tripcode equals the plain one")
+	}
+}
+
+func TestTripcoder_RejectsInvalidNames(t *testing.T) {
+	tripcoder := services.NewTripcoder("salt")
+	fields := []string{
+		"Rick !!AbCdEfGhIj",
+		"Rick!#secret",
+		strings.Repeat("n", 51),
+		strings.Repeat("n", 50) + "#" + strings.Repeat("p", 50),
+	}
+	for _, field := range fields {
+		if _, _, err := tripcoder.Identity(field, "Morty"); !errors.Is(err, models.ErrInvalidInput) {
+			t.Errorf("Identity(%.20q...) error = %v, want ErrInvalidInput", field, err)
+		}
+	}
+	if _, _, err := tripcoder.Identity(strings.Repeat("n", 50)+"#"+strings.Repeat("p", 49), "Morty"); err != nil {
+		t.Errorf("field of 100 characters rejected: %v", err)
+	}
+}
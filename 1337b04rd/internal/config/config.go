@@ -0,0 +1,164 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// Config holds runtime settings read from the environment.
+type Config struct {
+	DatabaseDSN     string
+	BumpLimit       int
+	ArchivePolicy   models.ArchivePolicy
+	ArchiveInterval time.Duration
+	PurgePolicy     models.PurgePolicy
+
+	// Admin account created or updated on startup when both are set
+	AdminName     string
+	AdminPassword string
+
+	FloodPolicy     models.FloodPolicy
+	TrustProxy      bool // Read client IPs from X-Forwarded-For
+	DuplicatePolicy models.DuplicatePolicy
+
+	// How often filter rules are re-read from the database
+	FilterReloadInterval time.Duration
+
+	// Server-side secret for secure (name##password) tripcodes
+	TripcodeSalt string
+
+	// Secret for the per-thread poster IDs of boards that show them
+	PosterIDSalt string
+
+	// Longest side of image thumbnails, in pixels
+	ThumbnailSize int
+
+	// Largest accepted uploads: file size in bytes, longest side and total
+	// pixels (summed over the frames of a GIF)
+	ImageMaxBytes     int
+	ImageMaxDimension int
+	ImageMaxPixels    int
+
+	// Images accepted with one post or comment
+	MaxAttachments int
+
+	// Requests to triple-s: connection, response header and whole request
+	// timeouts, and how often and how soon failed requests are tried again
+	S3ConnectTimeout  time.Duration
+	S3ResponseTimeout time.Duration
+	S3RequestTimeout  time.Duration
+	S3RetryAttempts   int
+	S3RetryBackoff    time.Duration
+
+	// Move images stored under client file names to content-addressed keys
+	// at startup
+	MigrateImageKeys bool
+
+	// How long unused images wait before they are deleted from storage
+	ImageDeleteGrace time.Duration
+
+	// Board behind the routes that predate boards, such as /posts
+	DefaultBoard string
+
+	// Reports a session may file within ReportRateWindow
+	ReportRateLimit  int
+	ReportRateWindow time.Duration
+}
+
+// Load reads the configuration from environment variables, falling back to
+// defaults that match docker-compose.yml.
+func Load() Config {
+	return Config{
+		DatabaseDSN: getEnv("DATABASE_DSN", "host=db port=5432 user=board_user password=board_pass dbname=board_db sslmode=disable"),
+		BumpLimit:   getEnvInt("BUMP_LIMIT", 300),
+		ArchivePolicy: models.ArchivePolicy{
+			NoReplyTTL: getEnvDuration("ARCHIVE_TTL_NO_REPLIES", models.DefaultArchivePolicy.NoReplyTTL),
+			ReplyTTL:   getEnvDuration("ARCHIVE_TTL_REPLIES", models.DefaultArchivePolicy.ReplyTTL),
+		},
+		ArchiveInterval: getEnvDuration("ARCHIVE_INTERVAL", time.Minute),
+		PurgePolicy: models.PurgePolicy{
+			Retention: getEnvDuration("ARCHIVE_RETENTION", 0),
+			DryRun:    getEnvBool("ARCHIVE_PURGE_DRY_RUN", false),
+		},
+		AdminName:     os.Getenv("MOD_ADMIN_NAME"),
+		AdminPassword: os.Getenv("MOD_ADMIN_PASSWORD"),
+		FloodPolicy: models.FloodPolicy{
+			PostInterval:      getEnvDuration("FLOOD_POST_INTERVAL", models.DefaultFloodPolicy.PostInterval),
+			CommentsPerMinute: getEnvInt("FLOOD_COMMENTS_PER_MINUTE", models.DefaultFloodPolicy.CommentsPerMinute),
+			ThreadCooldown:    getEnvDuration("FLOOD_THREAD_COOLDOWN", models.DefaultFloodPolicy.ThreadCooldown),
+		},
+		TrustProxy: getEnvBool("TRUST_PROXY", false),
+		DuplicatePolicy: models.DuplicatePolicy{
+			Window: getEnvDuration("DUPLICATE_WINDOW", models.DefaultDuplicatePolicy.Window),
+			Action: models.ParseDuplicateAction(os.Getenv("DUPLICATE_ACTION"), models.DefaultDuplicatePolicy.Action),
+		},
+		FilterReloadInterval: getEnvDuration("FILTER_RELOAD_INTERVAL", 30*time.Second),
+		TripcodeSalt:         os.Getenv("TRIPCODE_SALT"),
+		PosterIDSalt:         os.Getenv("POSTER_ID_SALT"),
+		ThumbnailSize:        getEnvInt("THUMBNAIL_SIZE", 250),
+		ImageMaxBytes:        getEnvInt("IMAGE_MAX_BYTES", 10<<20),
+		ImageMaxDimension:    getEnvInt("IMAGE_MAX_DIMENSION", 8000),
+		ImageMaxPixels:       getEnvInt("IMAGE_MAX_PIXELS", 40_000_000),
+		MaxAttachments:       getEnvInt("MAX_ATTACHMENTS", 4),
+		S3ConnectTimeout:     getEnvDuration("S3_CONNECT_TIMEOUT", 5*time.Second),
+		S3ResponseTimeout:    getEnvDuration("S3_RESPONSE_TIMEOUT", 30*time.Second),
+		S3RequestTimeout:     getEnvDuration("S3_REQUEST_TIMEOUT", 2*time.Minute),
+		S3RetryAttempts:      getEnvInt("S3_RETRY_ATTEMPTS", 3),
+		S3RetryBackoff:       getEnvDuration("S3_RETRY_BACKOFF", 200*time.Millisecond),
+		MigrateImageKeys:     getEnvBool("MIGRATE_IMAGE_KEYS", false),
+		ImageDeleteGrace:     getEnvDuration("IMAGE_DELETE_GRACE", 10*time.Minute),
+		DefaultBoard:         getEnv("DEFAULT_BOARD", "b"),
+		ReportRateLimit:      getEnvInt("REPORT_RATE_LIMIT", 5),
+		ReportRateWindow:     getEnvDuration("REPORT_RATE_WINDOW", 10*time.Minute),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid integer in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Invalid boolean in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return b
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("Invalid duration in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+	"1337b04rd/internal/app/domain/services"
+)
+
+// APIHandler serves the versioned JSON API under /api/v1/.
+type APIHandler struct {
+	PostService    *services.PostService
+	CommentService *services.CommentService
+	SessionRepo    ports.SessionRepository
+	S3Adapter      ports.S3Adapter
+	MarkupService  *services.MarkupService
+	BoardService   *services.BoardService
+	Images         *services.ImageService // nil leaves the uploads of refused messages in storage
+}
+
+func NewAPIHandler(postService *services.PostService, commentService *services.CommentService, sessionRepo ports.SessionRepository, s3Adapter ports.S3Adapter, markupService *services.MarkupService, boardService *services.BoardService) *APIHandler {
+	return &APIHandler{
+		PostService:    postService,
+		CommentService: commentService,
+		SessionRepo:    sessionRepo,
+		S3Adapter:      s3Adapter,
+		MarkupService:  markupService,
+		BoardService:   boardService,
+	}
+}
+
+// CreatePostRequest is the JSON body accepted by CreatePost.
+// Multipart forms use the same field names as the HTML form instead.
+type CreatePostRequest struct {
+	Name  string `json:"name"` // Optional "name#password" or "name##password"
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// CreateCommentRequest is the JSON body accepted by CreateComment.
+type CreateCommentRequest struct {
+	Name            string `json:"name"` // Optional "name#password" or "name##password"
+	Text            string `json:"text"`
+	ParentCommentID *int   `json:"parent_comment_id"`
+	Sage            bool   `json:"sage"`
+}
+
+// ListBoards handles GET /api/v1/boards.
+func (h *APIHandler) ListBoards(w http.ResponseWriter, r *http.Request) {
+	boards, err := h.BoardService.GetBoards()
+	if err != nil {
+		writeJSONError(w, statusForError(err), "failed to fetch boards")
+		return
+	}
+	if boards == nil {
+		boards = []*models.Board{}
+	}
+	writeJSON(w, http.StatusOK, DataResponse{Data: boards})
+}
+
+// GetBoard handles GET /api/v1/boards/{board}.
+func (h *APIHandler) GetBoard(w http.ResponseWriter, r *http.Request) {
+	board := h.boardFromPath(w, r)
+	if board == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, board)
+}
+
+// boardFromPath loads the board named by the {board} path segment, or the
+// default board on the routes without one. It writes the error response and
+// returns nil if there is no such board.
+func (h *APIHandler) boardFromPath(w http.ResponseWriter, r *http.Request) *models.Board {
+	board, err := h.BoardService.GetBoard(r.PathValue("board"))
+	if err != nil {
+		writeJSONError(w, statusForError(err), errorMessage(err, "failed to fetch board"))
+		return nil
+	}
+	return board
+}
+
+// ListPosts handles GET /api/v1/boards/{board}/posts?cursor=&limit=&sort=
+// and, for the default board, GET /api/v1/posts.
+func (h *APIHandler) ListPosts(w http.ResponseWriter, r *http.Request) {
+	board := h.boardFromPath(w, r)
+	if board == nil {
+		return
+	}
+	page, err := pageRequestFromQuery(r, models.SortByBumpTime)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.PostService.GetAllPosts(board.Slug, page)
+	if err != nil {
+		writeJSONError(w, statusForError(err), "failed to fetch posts")
+		return
+	}
+	writeJSON(w, http.StatusOK, newPageResponse(r, result))
+}
+
+// GetPost handles GET /api/v1/posts/{id} and embeds the post's comments.
+func (h *APIHandler) GetPost(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid post id")
+		return
+	}
+
+	post, err := h.PostService.GetPostByID(id)
+	if err != nil {
+		writeJSONError(w, statusForError(err), "failed to fetch post")
+		return
+	}
+	if post == nil {
+		writeJSONError(w, http.StatusNotFound, "post not found")
+		return
+	}
+
+	if !h.attachComments(w, r, post) {
+		return
+	}
+	writeJSON(w, http.StatusOK, post)
+}
+
+// CreatePost handles POST /api/v1/boards/{board}/posts and, for the default
+// board, POST /api/v1/posts. It accepts either a JSON body or a multipart
+// form with "name", "subject", "comment" and optional "image" files.
+func (h *APIHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := sessionIDFromContext(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "missing session")
+		return
+	}
+
+	var req CreatePostRequest
+	var images []*models.UploadedImage
+	if isJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	} else {
+		// The images are stored only once the fields are valid and the
+		// board accepts them
+		uploaded, err := uploadImages(h.S3Adapter, h.Images, r, "post", func(images []*models.UploadedImage) error {
+			if r.FormValue("subject") == "" || r.FormValue("comment") == "" {
+				return fmt.Errorf("title and text are required: %w", models.ErrInvalidInput)
+			}
+			return h.PostService.CheckImages(r.PathValue("board"), images)
+		})
+		if err != nil {
+			slog.Error("Image upload failed", "error", err)
+			writeJSONError(w, statusForError(err), errorMessage(err, "image upload failed"))
+			return
+		}
+		images = uploaded
+		req.Name = r.FormValue("name")
+		req.Title = r.FormValue("subject")
+		req.Text = r.FormValue("comment")
+	}
+
+	if req.Title == "" || req.Text == "" {
+		writeJSONError(w, http.StatusBadRequest, "title and text are required")
+		return
+	}
+
+	post, err := h.PostService.CreatePost(sessionID, r.PathValue("board"), req.Name, req.Title, req.Text, images)
+	if err != nil {
+		discardImages(h.Images, r, images)
+		writeJSONError(w, statusForError(err), errorMessage(err, "failed to create post"))
+		return
+	}
+	writeJSON(w, http.StatusCreated, post)
+}
+
+// ListComments handles GET /api/v1/posts/{id}/comments?view=&depth=&collapse=
+// for live and archived threads. Hidden threads are not found.
+func (h *APIHandler) ListComments(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || postID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid post id")
+		return
+	}
+
+	post, err := h.visiblePost(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, statusForError(err), "failed to fetch post")
+		return
+	}
+	if post == nil {
+		writeJSONError(w, http.StatusNotFound, "post not found")
+		return
+	}
+
+	comments, err := h.CommentService.GetCommentsView(postID, commentViewFromQuery(r))
+	if err != nil {
+		writeJSONError(w, statusForError(err), "failed to fetch comments")
+		return
+	}
+	h.MarkupService.RenderThread(postID, nil, comments)
+	writeJSON(w, http.StatusOK, DataResponse{Data: nonNilComments(comments)})
+}
+
+// CreateComment handles POST /api/v1/posts/{id}/comments. It accepts either
+// a JSON body or a multipart form with "name", "comment", "parent_id" and
+// optional "image" files.
+func (h *APIHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := sessionIDFromContext(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "missing session")
+		return
+	}
+	userData, ok := h.SessionRepo.GetSessionData(sessionID)
+	if !ok || userData.Name == "" {
+		writeJSONError(w, http.StatusUnauthorized, "invalid session")
+		return
+	}
+
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || postID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid post id")
+		return
+	}
+
+	var req CreateCommentRequest
+	var images []*models.UploadedImage
+	if isJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	} else {
+		// The images are stored only once the fields are valid and the
+		// board accepts them
+		uploaded, err := uploadImages(h.S3Adapter, h.Images, r, "comment", func(images []*models.UploadedImage) error {
+			if r.FormValue("comment") == "" {
+				return fmt.Errorf("text is required: %w", models.ErrInvalidInput)
+			}
+			if parentIDStr := r.FormValue("parent_id"); parentIDStr != "" {
+				parentID, err := strconv.Atoi(parentIDStr)
+				if err != nil {
+					return fmt.Errorf("invalid parent id: %w", models.ErrInvalidInput)
+				}
+				req.ParentCommentID = &parentID
+			}
+			return h.CommentService.CheckImages(postID, images)
+		})
+		if err != nil {
+			slog.Error("Image upload failed", "error", err)
+			writeJSONError(w, statusForError(err), errorMessage(err, "image upload failed"))
+			return
+		}
+		images = uploaded
+		req.Name = r.FormValue("name")
+		req.Text = r.FormValue("comment")
+		req.Sage = r.FormValue("sage") != ""
+	}
+
+	if req.Text == "" {
+		writeJSONError(w, http.StatusBadRequest, "text is required")
+		return
+	}
+
+	comment := models.Comment{
+		PostID:          postID,
+		ParentCommentID: req.ParentCommentID,
+		UserName:        userData.Name,
+		UserAvatar:      userData.Avatar,
+		SessionID:       sessionID,
+		NameField:       req.Name,
+		Text:            req.Text,
+		Sage:            req.Sage,
+		CreatedAt:       time.Now(),
+	}
+	comment.SetAttachments(models.NewAttachments(images))
+
+	created, err := h.CommentService.CreateComment(comment)
+	if err != nil {
+		discardImages(h.Images, r, images)
+		writeJSONError(w, statusForError(err), errorMessage(err, "failed to create comment"))
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// ListArchivedPosts handles GET /api/v1/boards/{board}/archive?cursor=&limit=&sort=
+// and, for the default board, GET /api/v1/archive.
+func (h *APIHandler) ListArchivedPosts(w http.ResponseWriter, r *http.Request) {
+	board := h.boardFromPath(w, r)
+	if board == nil {
+		return
+	}
+	page, err := pageRequestFromQuery(r, models.SortByArchivedAt)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.PostService.GetArchivedPosts(board.Slug, page)
+	if err != nil {
+		writeJSONError(w, statusForError(err), "failed to fetch archived posts")
+		return
+	}
+	writeJSON(w, http.StatusOK, newPageResponse(r, result))
+}
+
+// GetArchivedPost handles GET /api/v1/archive/{id}.
+func (h *APIHandler) GetArchivedPost(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid post id")
+		return
+	}
+
+	post, err := h.PostService.GetArchivedPostByID(id)
+	if err != nil {
+		writeJSONError(w, statusForError(err), "failed to fetch archived post")
+		return
+	}
+	if post == nil {
+		writeJSONError(w, http.StatusNotFound, "archived post not found")
+		return
+	}
+
+	if !h.attachComments(w, r, post) {
+		return
+	}
+	writeJSON(w, http.StatusOK, post)
+}
+
+// visiblePost returns the live or archived thread with the given ID, or nil
+// if there is none or it is hidden.
+func (h *APIHandler) visiblePost(id string) (*models.Post, error) {
+	post, err := h.PostService.GetPostByID(id)
+	if err != nil || post != nil {
+		return post, err
+	}
+	return h.PostService.GetArchivedPostByID(id)
+}
+
+// attachComments loads the comments of post in the view requested by the
+// query string and writes an error response if that fails. It reports
+// whether the caller may continue.
+func (h *APIHandler) attachComments(w http.ResponseWriter, r *http.Request, post *models.Post) bool {
+	comments, err := h.CommentService.GetCommentsView(post.ID, commentViewFromQuery(r))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch comments")
+		return false
+	}
+	post.Comments = nonNilComments(comments)
+	h.MarkupService.RenderThread(post.ID, post, post.Comments)
+	return true
+}
+
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// nonNilPosts makes empty lists encode as [] instead of null.
+func nonNilPosts(posts []*models.Post) []*models.Post {
+	if posts == nil {
+		return []*models.Post{}
+	}
+	return posts
+}
+
+// nonNilComments makes empty lists encode as [] instead of null.
+func nonNilComments(comments []*models.Comment) []*models.Comment {
+	if comments == nil {
+		return []*models.Comment{}
+	}
+	return comments
+}
@@ -0,0 +1,388 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+	"1337b04rd/internal/interface/handlers"
+)
+
+// apiFixture wires an APIHandler to in-memory repositories holding:
+//
+//	1 live thread with comment 11 and its reply 12
+//	2 archived thread with comment 21
+//	3 hidden thread with comment 31
+type apiFixture struct {
+	handler  *handlers.APIHandler
+	posts    *fakePosts
+	comments *fakeComments
+	boards   *fakeBoards
+	storage  *fakeStorage
+	queue    *fakeImageQueue
+}
+
+func newAPIFixture() *apiFixture {
+	archivedAt := time.Now().Add(-time.Hour)
+	posts := newFakePosts(
+		&models.Post{ID: 1, Board: "b", Title: "live", Text: "live thread"},
+		&models.Post{ID: 2, Board: "b", Title: "archived", Text: "archived thread", ArchivedAt: &archivedAt},
+		&models.Post{ID: 3, Board: "b", Title: "hidden", Text: "hidden thread", IsHidden: true},
+	)
+	parent := 11
+	comments := &fakeComments{comments: []*models.Comment{
+		{ID: 11, PostID: 1, UserName: "Rick", Text: "reply to live"},
+		{ID: 12, PostID: 1, ParentCommentID: &parent, UserName: "Rick", Text: "nested reply"},
+		{ID: 21, PostID: 2, UserName: "Rick", Text: "reply to archived"},
+		{ID: 31, PostID: 3, UserName: "Rick", Text: "reply to hidden"},
+	}}
+	sessions := fakeSessions{"s1": {Name: "Morty", Avatar: "morty.png"}}
+	boardRepo := newFakeBoards()
+	boards := services.NewBoardService(boardRepo, "b")
+	storage := &fakeStorage{}
+	queue := &fakeImageQueue{queued: map[string]time.Time{}}
+
+	postService := services.NewPostService(posts, sessions, boards)
+	commentService := services.NewCommentService(comments)
+	commentService.Boards = boards
+	handler := handlers.NewAPIHandler(postService, commentService, sessions, storage, services.NewMarkupService(noQuotes{}), boards)
+	handler.Images = services.NewImageService(storage, queue)
+	return &apiFixture{handler: handler, posts: posts, comments: comments, boards: boardRepo, storage: storage, queue: queue}
+}
+
+// apiRequest builds a request with the session of the auth middleware and
+// the given path values.
+func apiRequest(method, target, contentType string, body []byte, pathValues ...string) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		req.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	return req.WithContext(context.WithValue(req.Context(), "sessionId", "s1"))
+}
+
+// errorCode decodes the code of an error envelope.
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) int {
+	t.Helper()
+	var resp handlers.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not an error envelope: %s", rec.Body)
+	}
+	return resp.Error.Code
+}
+
+func TestAPIListBoards(t *testing.T) {
+	f := newAPIFixture()
+
+	rec := httptest.NewRecorder()
+	f.handler.ListBoards(rec, apiRequest(http.MethodGet, "/api/v1/boards", "", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusOK)
+	}
+	var resp struct {
+		Data []models.Board `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not a data envelope: %s", rec.Body)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].Slug != "b" {
+		t.Errorf("boards = %+v, want only b", resp.Data)
+	}
+}
+
+func TestAPIListComments(t *testing.T) {
+	f := newAPIFixture()
+
+	tests := []struct {
+		id       string
+		status   int
+		wantText string
+	}{
+		{"1", http.StatusOK, "reply to live"},
+		{"2", http.StatusOK, "reply to archived"},
+		{"3", http.StatusNotFound, ""},
+		{"4", http.StatusNotFound, ""},
+		{"x", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		f.handler.ListComments(rec, apiRequest(http.MethodGet, "/api/v1/posts/"+tt.id+"/comments", "", nil, "id", tt.id))
+		if rec.Code != tt.status {
+			t.Errorf("post %s: status %d, want %d", tt.id, rec.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			if code := errorCode(t, rec); code != tt.status {
+				t.Errorf("post %s: error code %d, want %d", tt.id, code, tt.status)
+			}
+			continue
+		}
+		var resp struct {
+			Data []models.Comment `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if len(resp.Data) != 1 || resp.Data[0].Text != tt.wantText {
+			t.Errorf("post %s: comments %+v, want %q", tt.id, resp.Data, tt.wantText)
+		}
+	}
+}
+
+func TestAPIListComments_View(t *testing.T) {
+	f := newAPIFixture()
+
+	tests := []struct {
+		query string
+		want  string // Top-level IDs, with nested replies in parentheses
+	}{
+		{"", "11(12)"},
+		{"?view=tree", "11(12)"},
+		{"?view=flat", "11 12"},
+		{"?view=tree&depth=1", "11(12)"},
+		{"?view=tree&collapse=1", "11(12)"},
+		{"?view=unknown", "11(12)"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		f.handler.ListComments(rec, apiRequest(http.MethodGet, "/api/v1/posts/1/comments"+tt.query, "", nil, "id", "1"))
+		var resp struct {
+			Data []*models.Comment `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if got := commentIDs(resp.Data); got != tt.want {
+			t.Errorf("%q: comments %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+// commentIDs renders a comment view as "id(replies) id".
+func commentIDs(comments []*models.Comment) string {
+	var parts []string
+	for _, c := range comments {
+		s := strconv.Itoa(c.ID)
+		if len(c.Replies) > 0 {
+			s += "(" + commentIDs(c.Replies) + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestAPIGetPost(t *testing.T) {
+	f := newAPIFixture()
+
+	for id, status := range map[string]int{"1": http.StatusOK, "2": http.StatusNotFound, "3": http.StatusNotFound, "x": http.StatusBadRequest} {
+		rec := httptest.NewRecorder()
+		f.handler.GetPost(rec, apiRequest(http.MethodGet, "/api/v1/posts/"+id, "", nil, "id", id))
+		if rec.Code != status {
+			t.Errorf("post %s: status %d, want %d", id, rec.Code, status)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	f.handler.GetPost(rec, apiRequest(http.MethodGet, "/api/v1/posts/1", "", nil, "id", "1"))
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	if post.ID != 1 || commentIDs(post.Comments) != "11(12)" {
+		t.Errorf("post 1 = %+v, want comments 11 and 12 embedded", post)
+	}
+}
+
+func TestAPICreatePost_JSON(t *testing.T) {
+	f := newAPIFixture()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"title":"hello","text":"world"}`, http.StatusCreated},
+		{"no title", `{"text":"world"}`, http.StatusBadRequest},
+		{"no text", `{"title":"hello"}`, http.StatusBadRequest},
+		{"broken JSON", `{"title":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			f.handler.CreatePost(rec, apiRequest(http.MethodPost, "/api/v1/posts", "application/json", []byte(tt.body)))
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusCreated {
+				return
+			}
+			var post models.Post
+			json.Unmarshal(rec.Body.Bytes(), &post)
+			if post.ID == 0 || post.Board != "b" || post.UserName != "Morty" || post.Title != "hello" {
+				t.Errorf("created post = %+v", post)
+			}
+		})
+	}
+	if f.storage.calls != 0 {
+		t.Errorf("JSON requests reached the image storage %d times", f.storage.calls)
+	}
+}
+
+func TestAPICreatePost_NoSession(t *testing.T) {
+	f := newAPIFixture()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(`{"title":"a","text":"b"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	f.handler.CreatePost(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAPICreateComment_JSON(t *testing.T) {
+	f := newAPIFixture()
+
+	rec := httptest.NewRecorder()
+	f.handler.CreateComment(rec, apiRequest(http.MethodPost, "/api/v1/posts/1/comments", "application/json", []byte(`{"text":"new reply","sage":true}`), "id", "1"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var comment models.Comment
+	json.Unmarshal(rec.Body.Bytes(), &comment)
+	if comment.PostID != 1 || comment.Text != "new reply" || !comment.Sage || comment.UserName != "Morty" {
+		t.Errorf("created comment = %+v", comment)
+	}
+
+	for name, body := range map[string]string{"no text": `{}`, "broken JSON": `{"text":`} {
+		rec := httptest.NewRecorder()
+		f.handler.CreateComment(rec, apiRequest(http.MethodPost, "/api/v1/posts/1/comments", "application/json", []byte(body), "id", "1"))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", name, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	f.handler.CreateComment(rec, apiRequest(http.MethodPost, "/api/v1/posts/0/comments", "application/json", []byte(`{"text":"a"}`), "id", "0"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("post 0: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// imageForm builds a multipart body with fields and one image file.
+func imageForm(fields map[string]string) (body []byte, contentType string) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, _ := writer.CreateFormFile("image", "a.png")
+	part.Write([]byte("png"))
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	writer.Close()
+	return buf.Bytes(), writer.FormDataContentType()
+}
+
+func TestAPICreate_FieldsCheckedBeforeStoring(t *testing.T) {
+	f := newAPIFixture()
+	f.storage.uploads = []*models.UploadedImage{{URL: "/img/a.png", Format: models.ImagePNG}}
+
+	tests := []struct {
+		name   string
+		create http.HandlerFunc
+		target string
+		fields map[string]string
+	}{
+		{"thread without title", f.handler.CreatePost, "/api/v1/posts", map[string]string{"comment": "text"}},
+		{"reply without text", f.handler.CreateComment, "/api/v1/posts/1/comments", map[string]string{"parent_id": "11"}},
+		{"reply to a bad parent", f.handler.CreateComment, "/api/v1/posts/1/comments", map[string]string{"comment": "text", "parent_id": "x"}},
+	}
+	for _, tt := range tests {
+		body, contentType := imageForm(tt.fields)
+		rec := httptest.NewRecorder()
+		tt.create(rec, apiRequest(http.MethodPost, tt.target, contentType, body, "id", "1"))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, http.StatusBadRequest)
+		}
+	}
+	if f.storage.stored != 0 {
+		t.Errorf("%d images stored for refused forms", f.storage.stored)
+	}
+
+	body, contentType := imageForm(map[string]string{"comment": "text", "parent_id": "11"})
+	rec := httptest.NewRecorder()
+	f.handler.CreateComment(rec, apiRequest(http.MethodPost, "/api/v1/posts/1/comments", contentType, body, "id", "1"))
+	if rec.Code != http.StatusCreated || f.storage.stored != 1 {
+		t.Errorf("valid reply: status %d with %d images stored, want %d and 1", rec.Code, f.storage.stored, http.StatusCreated)
+	}
+}
+
+func TestAPICreate_BoardRulesCheckedBeforeStoring(t *testing.T) {
+	f := newAPIFixture()
+	f.storage.uploads = []*models.UploadedImage{{URL: "/img/a.png", Format: models.ImagePNG}}
+	f.boards.boards["jpg"] = &models.Board{Slug: "jpg", ImageFormats: []models.ImageFormat{models.ImageJPEG}}
+
+	body, contentType := imageForm(map[string]string{"subject": "title", "comment": "text"})
+	rec := httptest.NewRecorder()
+	f.handler.CreatePost(rec, apiRequest(http.MethodPost, "/api/v1/boards/jpg/posts", contentType, body, "board", "jpg"))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("PNG on a JPEG board: status %d, want %d", rec.Code, http.StatusUnsupportedMediaType)
+	}
+
+	f.boards.boards["b"].TextOnly = true
+	body, contentType = imageForm(map[string]string{"comment": "text"})
+	rec = httptest.NewRecorder()
+	f.handler.CreateComment(rec, apiRequest(http.MethodPost, "/api/v1/posts/1/comments", contentType, body, "id", "1"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("image on a text-only board: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if f.storage.stored != 0 {
+		t.Errorf("%d images stored against the board rules", f.storage.stored)
+	}
+}
+
+func TestAPICreate_RefusedUploadsDiscarded(t *testing.T) {
+	f := newAPIFixture()
+	f.storage.uploads = []*models.UploadedImage{{URL: "/img/a.png", ThumbnailURL: "/img/a-thumb.png", Format: models.ImagePNG}}
+	reply := func() int {
+		body, contentType := imageForm(map[string]string{"comment": "text"})
+		rec := httptest.NewRecorder()
+		f.handler.CreateComment(rec, apiRequest(http.MethodPost, "/api/v1/posts/1/comments", contentType, body, "id", "1"))
+		return rec.Code
+	}
+
+	// A deletion queued by an earlier refused message is cancelled by the
+	// next upload of the same image
+	f.queue.queued["/img/a.png"] = time.Now()
+	if code := reply(); code != http.StatusCreated {
+		t.Fatalf("reply: status %d, want %d", code, http.StatusCreated)
+	}
+	if len(f.queue.queued) != 0 {
+		t.Errorf("queue holds %v after a created reply, want nothing", f.queue.queued)
+	}
+
+	tests := []struct {
+		name       string
+		storageErr error
+		commentErr error
+	}{
+		{"storage failure", errors.New("storage unavailable"), nil},
+		{"comment not created", nil, errors.New("database unavailable")},
+	}
+	for _, tt := range tests {
+		f.storage.err, f.comments.err = tt.storageErr, tt.commentErr
+		f.queue.queued = map[string]time.Time{}
+		if code := reply(); code < http.StatusBadRequest {
+			t.Errorf("%s: status %d, want an error", tt.name, code)
+		}
+		if _, ok := f.queue.queued["/img/a.png"]; !ok || len(f.queue.queued) != 2 {
+			t.Errorf("%s: queue holds %v, want the image and its thumbnail", tt.name, f.queue.queued)
+		}
+	}
+}
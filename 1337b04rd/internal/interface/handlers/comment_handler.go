@@ -16,6 +16,7 @@ type CommentHandler struct {
 	CommentService *services.CommentService
 	GetSessionData ports.SessionRepository
 	S3Adapter      ports.S3Adapter
+	Images         *services.ImageService // nil leaves the uploads of refused comments in storage
 }
 
 func NewCommentHandler(commentService *services.CommentService, sessionRepo ports.SessionRepository, s3Adapter ports.S3Adapter) *CommentHandler {
@@ -47,43 +48,20 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse form
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
-	}
-
-	// Extract form values
-	postIDStr := r.FormValue("post_id")
-	text := r.FormValue("comment")
-	parentIDStr := r.FormValue("parent_id")
-
-	if postIDStr == "" || text == "" {
-		http.Error(w, "Missing post ID or comment text", http.StatusBadRequest)
-		return
-	}
-
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		http.Error(w, "Invalid post ID", http.StatusBadRequest)
-		return
-	}
-
+	// Read the form; the images are stored only once the fields are valid
+	// and the board accepts them
+	var postID int
 	var parentID *int
-	if parentIDStr != "" {
-		id, err := strconv.Atoi(parentIDStr)
-		if err != nil {
-			http.Error(w, "Invalid parent ID", http.StatusBadRequest)
-			return
+	images, err := uploadImages(h.S3Adapter, h.Images, r, "comment", func(images []*models.UploadedImage) error {
+		var err error
+		if postID, parentID, err = commentFormTarget(r); err != nil {
+			return err
 		}
-		parentID = &id
-	}
-
-	// Upload image
-	imageURL, err := h.S3Adapter.UploadImage(r, "comment")
+		return h.CommentService.CheckImages(postID, images)
+	})
 	if err != nil {
 		slog.Error("Image upload failed", "error", err)
-		http.Error(w, "Image upload failed", http.StatusInternalServerError)
+		http.Error(w, errorMessage(err, "Image upload failed"), statusForError(err))
 		return
 	}
 
@@ -93,14 +71,18 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		ParentCommentID: parentID,
 		UserName:        userData.Name,
 		UserAvatar:      userData.Avatar,
-		Text:            text,
-		ImageURL:        imageURL,
+		SessionID:       sessionID,
+		NameField:       r.FormValue("name"),
+		Text:            r.FormValue("comment"),
+		Sage:            r.FormValue("sage") != "",
 		CreatedAt:       time.Now(),
 	}
+	comment.SetAttachments(models.NewAttachments(images))
 
 	if _, err := h.CommentService.CreateComment(comment); err != nil {
+		discardImages(h.Images, r, images)
 		slog.Error("Failed to create comment", "error", err)
-		http.Error(w, "Failed to save comment", http.StatusInternalServerError)
+		http.Error(w, errorMessage(err, "Failed to save comment"), statusForError(err))
 		return
 	}
 
@@ -109,3 +91,22 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	// Redirect to post
 	http.Redirect(w, r, fmt.Sprintf("/post/%d", postID), http.StatusSeeOther)
 }
+
+// commentFormTarget checks the fields of a comment form that has been read
+// and returns the thread and, for nested replies, the parent comment.
+func commentFormTarget(r *http.Request) (postID int, parentID *int, err error) {
+	if r.FormValue("post_id") == "" || r.FormValue("comment") == "" {
+		return 0, nil, fmt.Errorf("missing post ID or comment text: %w", models.ErrInvalidInput)
+	}
+	if postID, err = strconv.Atoi(r.FormValue("post_id")); err != nil {
+		return 0, nil, fmt.Errorf("invalid post ID: %w", models.ErrInvalidInput)
+	}
+	if parentIDStr := r.FormValue("parent_id"); parentIDStr != "" {
+		id, err := strconv.Atoi(parentIDStr)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid parent ID: %w", models.ErrInvalidInput)
+		}
+		parentID = &id
+	}
+	return postID, parentID, nil
+}
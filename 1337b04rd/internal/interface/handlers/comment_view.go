@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// PostView is the template data for a single thread.
+type PostView struct {
+	*models.Post
+	Board *models.Board
+	View  models.CommentViewMode
+}
+
+// commentViewFromQuery reads the view, depth and collapse query parameters.
+// Malformed numbers are ignored.
+func commentViewFromQuery(r *http.Request) models.CommentViewOptions {
+	q := r.URL.Query()
+	opts := models.CommentViewOptions{Mode: models.ParseCommentViewMode(q.Get("view"))}
+	if depth, err := strconv.Atoi(q.Get("depth")); err == nil && depth > 0 {
+		opts.MaxDepth = depth
+	}
+	if collapse, err := strconv.Atoi(q.Get("collapse")); err == nil && collapse > 0 {
+		opts.CollapseAfter = collapse
+	}
+	return opts
+}
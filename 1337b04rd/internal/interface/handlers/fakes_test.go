@@ -0,0 +1,213 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+)
+
+// fakePosts keeps threads in memory. Methods the handlers under test do not
+// use are left to the embedded interface and panic if called.
+type fakePosts struct {
+	ports.PostRepository
+	mu     sync.Mutex
+	posts  map[int]*models.Post
+	nextID int
+}
+
+func newFakePosts(posts ...*models.Post) *fakePosts {
+	f := &fakePosts{posts: map[int]*models.Post{}, nextID: 100}
+	for _, p := range posts {
+		f.posts[p.ID] = p
+	}
+	return f
+}
+
+func (f *fakePosts) CreatePost(post *models.Post, maxThreads int) (*models.Post, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if post.ID == 0 {
+		f.nextID++
+		post.ID = f.nextID
+	}
+	f.posts[post.ID] = post
+	return post, nil
+}
+
+func (f *fakePosts) NextPostID() (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	return f.nextID, nil
+}
+
+func (f *fakePosts) GetPostByID(id string) (*models.Post, error) {
+	return f.find(id, false)
+}
+
+func (f *fakePosts) GetArchivedPostByID(id string) (*models.Post, error) {
+	return f.find(id, true)
+}
+
+func (f *fakePosts) find(id string, archived bool) (*models.Post, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, _ := strconv.Atoi(id)
+	post := f.posts[n]
+	if post == nil || post.IsHidden || (post.ArchivedAt != nil) != archived {
+		return nil, nil
+	}
+	copied := *post
+	return &copied, nil
+}
+
+// fakeComments keeps comments in memory. err, if set, fails CreateComment.
+type fakeComments struct {
+	mu       sync.Mutex
+	comments []*models.Comment
+	nextID   int
+	err      error
+}
+
+func (f *fakeComments) CreateComment(comment models.Comment, bumpLimit int) (*models.Comment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.nextID++
+	comment.ID = 1000 + f.nextID
+	f.comments = append(f.comments, &comment)
+	return &comment, nil
+}
+
+func (f *fakeComments) GetCommentsByPostID(postID int) ([]*models.Comment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []*models.Comment
+	for _, c := range f.comments {
+		if c.PostID == postID {
+			copied := *c
+			list = append(list, &copied)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeComments) DeleteComment(commentID string) error {
+	return nil
+}
+
+// fakeBoards serves a fixed set of boards; threads live on the board named
+// by postBoards, or on "b".
+type fakeBoards struct {
+	ports.BoardRepository
+	boards     map[string]*models.Board
+	postBoards map[int]string
+}
+
+func newFakeBoards(boards ...*models.Board) *fakeBoards {
+	f := &fakeBoards{boards: map[string]*models.Board{"b": {Slug: "b", Title: "Random"}}, postBoards: map[int]string{}}
+	for _, b := range boards {
+		f.boards[b.Slug] = b
+	}
+	return f
+}
+
+func (f *fakeBoards) GetBoards() ([]*models.Board, error) {
+	var list []*models.Board
+	for _, b := range f.boards {
+		list = append(list, b)
+	}
+	return list, nil
+}
+
+func (f *fakeBoards) GetBoard(slug string) (*models.Board, error) {
+	return f.boards[slug], nil
+}
+
+func (f *fakeBoards) GetBoardByPostID(postID int) (*models.Board, error) {
+	if slug, ok := f.postBoards[postID]; ok {
+		return f.boards[slug], nil
+	}
+	return f.boards["b"], nil
+}
+
+// fakeSessions knows every session ID it was given.
+type fakeSessions map[string]models.UserData
+
+func (f fakeSessions) GetSessionData(sessionID string) (models.UserData, bool) {
+	data, ok := f[sessionID]
+	return data, ok
+}
+
+func (f fakeSessions) SetSessionData(sessionID string, data models.UserData) error {
+	f[sessionID] = data
+	return nil
+}
+
+// noQuotes resolves no quote at all.
+type noQuotes struct{}
+
+func (noQuotes) ResolveQuotes(ids []int) ([]models.QuoteTarget, error) {
+	return nil, nil
+}
+
+// fakeStorage stands in for triple-s. It returns the images in uploads
+// after parsing the form and, like the adapter, stores them only if check
+// accepts them. stored counts the images stored. err, if set, fails the
+// upload after storing, returning the images with it as the adapter does.
+type fakeStorage struct {
+	ports.S3Adapter
+	mu      sync.Mutex
+	uploads []*models.UploadedImage
+	calls   int
+	stored  int
+	err     error
+}
+
+func (f *fakeStorage) UploadImages(r *http.Request, imageType string, check func([]*models.UploadedImage) error) ([]*models.UploadedImage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		return nil, err
+	}
+	if check != nil {
+		if err := check(f.uploads); err != nil {
+			return nil, err
+		}
+	}
+	f.stored += len(f.uploads)
+	return f.uploads, f.err
+}
+
+// fakeImageQueue keeps the image deletion queue in memory.
+type fakeImageQueue struct {
+	ports.ImageRepository
+	mu     sync.Mutex
+	queued map[string]time.Time
+}
+
+func (f *fakeImageQueue) ScheduleDeletions(ctx context.Context, urls []string, due time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, url := range urls {
+		f.queued[url] = due
+	}
+	return nil
+}
+
+func (f *fakeImageQueue) CancelDeletions(ctx context.Context, urls []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, url := range urls {
+		delete(f.queued, url)
+	}
+	return nil
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+	"1337b04rd/internal/interface/middleware"
+)
+
+type ModHandler struct {
+	ModerationService *services.ModerationService
+	ReportService     *services.ReportService
+	DuplicateService  *services.DuplicateService
+	FilterService     *services.FilterService
+	BoardService      *services.BoardService
+}
+
+func NewModHandler(moderationService *services.ModerationService, reportService *services.ReportService, duplicateService *services.DuplicateService, filterService *services.FilterService, boardService *services.BoardService) *ModHandler {
+	return &ModHandler{
+		ModerationService: moderationService,
+		ReportService:     reportService,
+		DuplicateService:  duplicateService,
+		FilterService:     filterService,
+		BoardService:      boardService,
+	}
+}
+
+// ModDashboardView is the template data for the moderation dashboard.
+type ModDashboardView struct {
+	Moderator *models.Moderator
+	AuditLog  []*models.ModAction
+}
+
+// ReportQueueView is the template data for the report queue.
+type ReportQueueView struct {
+	Moderator *models.Moderator
+	Groups    []*models.ReportGroup
+}
+
+// BannedImagesView is the template data for the image ban list.
+type BannedImagesView struct {
+	Moderator *models.Moderator
+	Bans      []*models.BannedImage
+}
+
+// FilterRulesView is the template data for the word filter editor.
+type FilterRulesView struct {
+	Moderator *models.Moderator
+	Rules     []*models.FilterRule
+	Kinds     []models.FilterRuleKind
+}
+
+// BoardsView is the template data for the board editor.
+type BoardsView struct {
+	Moderator    *models.Moderator
+	Boards       []*models.Board
+	DefaultBoard string
+	ImageFormats []models.ImageFormat
+}
+
+// postActions maps the {action} path segment of /mod/posts/{id}/{action}.
+var postActions = map[string]models.ModActionType{
+	"hide":   models.ActionHidePost,
+	"unhide": models.ActionUnhidePost,
+	"delete": models.ActionDeletePost,
+	"lock":   models.ActionLockPost,
+	"unlock": models.ActionUnlockPost,
+	"pin":    models.ActionPinPost,
+	"unpin":  models.ActionUnpinPost,
+}
+
+// moderatorFromContext returns the moderator stored by ModMiddleware.
+func moderatorFromContext(r *http.Request) *models.Moderator {
+	moderator, _ := r.Context().Value("moderator").(*models.Moderator)
+	return moderator
+}
+
+func (h *ModHandler) ServeLoginForm(w http.ResponseWriter, r *http.Request) {
+	tmpl := template.Must(template.ParseFiles("web/templates/mod-login.html"))
+	tmpl.Execute(w, nil)
+}
+
+func (h *ModHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.ModerationService.Login(r.FormValue("name"), r.FormValue("password"))
+	if err != nil {
+		http.Error(w, "Invalid name or password", statusForError(err))
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.ModSessionCookie,
+		Value:    token,
+		Path:     "/mod",
+		Expires:  time.Now().Add(services.ModSessionTTL),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/mod", http.StatusSeeOther)
+}
+
+func (h *ModHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(middleware.ModSessionCookie); err == nil {
+		if err := h.ModerationService.Logout(cookie.Value); err != nil {
+			slog.Error("Failed to end moderator session", "error", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.ModSessionCookie,
+		Value:    "",
+		Path:     "/mod",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, "/mod/login", http.StatusSeeOther)
+}
+
+func (h *ModHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
+	auditLog, err := h.ModerationService.GetAuditLog(100)
+	if err != nil {
+		http.Error(w, "Failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles("web/templates/mod.html"))
+	data := ModDashboardView{Moderator: moderatorFromContext(r), AuditLog: auditLog}
+	if err := tmpl.Execute(w, data); err != nil {
+		slog.Error("Failed to render moderation template", "error", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// PostAction handles POST /mod/posts/{id}/{action}.
+func (h *ModHandler) PostAction(w http.ResponseWriter, r *http.Request) {
+	actionType, ok := postActions[r.PathValue("action")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.act(w, r, actionType)
+}
+
+// DeleteComment handles POST /mod/comments/{id}/delete.
+func (h *ModHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	h.act(w, r, models.ActionDeleteComment)
+}
+
+// act applies actionType to the {id} target using the "reason" form value.
+// JSON clients get the audit entry back; browsers are redirected to /mod.
+func (h *ModHandler) act(w http.ResponseWriter, r *http.Request, actionType models.ModActionType) {
+	targetID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || targetID <= 0 {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	action, err := h.ModerationService.Act(moderatorFromContext(r), actionType, targetID, r.FormValue("reason"))
+	wantsJSON := r.Header.Get("Accept") == "application/json"
+	if err != nil {
+		if wantsJSON {
+			writeJSONError(w, statusForError(err), err.Error())
+			return
+		}
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	if wantsJSON {
+		writeJSON(w, http.StatusOK, action)
+		return
+	}
+	http.Redirect(w, r, "/mod", http.StatusSeeOther)
+}
+
+// Reports shows open reports grouped by target.
+func (h *ModHandler) Reports(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.ReportService.GetQueue(100)
+	if err != nil {
+		http.Error(w, "Failed to load reports", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("Accept") == "application/json" {
+		writeJSON(w, http.StatusOK, DataResponse{Data: groups})
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles("web/templates/mod-reports.html"))
+	data := ReportQueueView{Moderator: moderatorFromContext(r), Groups: groups}
+	if err := tmpl.Execute(w, data); err != nil {
+		slog.Error("Failed to render report queue template", "error", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// DismissReports handles POST /mod/reports/{type}/{id}/dismiss.
+func (h *ModHandler) DismissReports(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || targetID <= 0 {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	n, err := h.ReportService.Dismiss(moderatorFromContext(r), r.PathValue("type"), targetID)
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	if r.Header.Get("Accept") == "application/json" {
+		writeJSON(w, http.StatusOK, map[string]int{"dismissed": n})
+		return
+	}
+	http.Redirect(w, r, "/mod/reports", http.StatusSeeOther)
+}
+
+// ActOnReports handles POST /mod/reports/{type}/{id}/act. The "action" form
+// value uses the same names as /mod/posts/{id}/{action}; comments only
+// support "delete".
+func (h *ModHandler) ActOnReports(w http.ResponseWriter, r *http.Request) {
+	targetType := r.PathValue("type")
+	targetID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || targetID <= 0 {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	var actionType models.ModActionType
+	var ok bool
+	switch targetType {
+	case "post":
+		actionType, ok = postActions[r.FormValue("action")]
+	case "comment":
+		actionType, ok = models.ActionDeleteComment, r.FormValue("action") == "delete"
+	}
+	if !ok {
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	action, err := h.ReportService.Act(moderatorFromContext(r), targetType, targetID, actionType, r.FormValue("reason"))
+	wantsJSON := r.Header.Get("Accept") == "application/json"
+	if err != nil {
+		if wantsJSON {
+			writeJSONError(w, statusForError(err), err.Error())
+			return
+		}
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	if wantsJSON {
+		writeJSON(w, http.StatusOK, action)
+		return
+	}
+	http.Redirect(w, r, "/mod/reports", http.StatusSeeOther)
+}
+
+// BannedImages lists banned image hashes.
+func (h *ModHandler) BannedImages(w http.ResponseWriter, r *http.Request) {
+	bans, err := h.DuplicateService.GetBannedImages(100)
+	if err != nil {
+		http.Error(w, "Failed to load banned images", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("Accept") == "application/json" {
+		writeJSON(w, http.StatusOK, DataResponse{Data: bans})
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles("web/templates/mod-images.html"))
+	data := BannedImagesView{Moderator: moderatorFromContext(r), Bans: bans}
+	if err := tmpl.Execute(w, data); err != nil {
+		slog.Error("Failed to render banned images template", "error", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// BanImage handles POST /mod/images/ban. The image is given either by its
+// "hash" or by "target_type" and "target_id" of a post or comment.
+func (h *ModHandler) BanImage(w http.ResponseWriter, r *http.Request) {
+	targetID := 0
+	if id := r.FormValue("target_id"); id != "" {
+		var err error
+		if targetID, err = strconv.Atoi(id); err != nil || targetID <= 0 {
+			http.Error(w, "Invalid target ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ban, err := h.DuplicateService.BanImage(moderatorFromContext(r), r.FormValue("hash"),
+		r.FormValue("target_type"), targetID, r.FormValue("reason"))
+	wantsJSON := r.Header.Get("Accept") == "application/json"
+	if err != nil {
+		if wantsJSON {
+			writeJSONError(w, statusForError(err), err.Error())
+			return
+		}
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	if wantsJSON {
+		writeJSON(w, http.StatusOK, ban)
+		return
+	}
+	http.Redirect(w, r, "/mod/images", http.StatusSeeOther)
+}
+
+// UnbanImage handles POST /mod/images/{hash}/unban with a "reason".
+func (h *ModHandler) UnbanImage(w http.ResponseWriter, r *http.Request) {
+	if err := h.DuplicateService.UnbanImage(moderatorFromContext(r), r.PathValue("hash"), r.FormValue("reason")); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	http.Redirect(w, r, "/mod/images", http.StatusSeeOther)
+}
+
+// FilterRules lists the word filter rules.
+func (h *ModHandler) FilterRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.FilterService.GetRules()
+	if err != nil {
+		http.Error(w, "Failed to load filter rules", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("Accept") == "application/json" {
+		writeJSON(w, http.StatusOK, DataResponse{Data: rules})
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles("web/templates/mod-filters.html"))
+	data := FilterRulesView{Moderator: moderatorFromContext(r), Rules: rules, Kinds: models.FilterRuleKinds}
+	if err := tmpl.Execute(w, data); err != nil {
+		slog.Error("Failed to render filter rules template", "error", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// AddFilterRule handles POST /mod/filters with "kind", "pattern" and
+// "replacement" form values.
+func (h *ModHandler) AddFilterRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.FilterService.AddRule(moderatorFromContext(r), models.FilterRuleKind(r.FormValue("kind")),
+		r.FormValue("pattern"), r.FormValue("replacement"))
+	wantsJSON := r.Header.Get("Accept") == "application/json"
+	if err != nil {
+		if wantsJSON {
+			writeJSONError(w, statusForError(err), err.Error())
+			return
+		}
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	if wantsJSON {
+		writeJSON(w, http.StatusCreated, rule)
+		return
+	}
+	http.Redirect(w, r, "/mod/filters", http.StatusSeeOther)
+}
+
+// DeleteFilterRule handles POST /mod/filters/{id}/delete.
+func (h *ModHandler) DeleteFilterRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.FilterService.DeleteRule(moderatorFromContext(r), id); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	http.Redirect(w, r, "/mod/filters", http.StatusSeeOther)
+}
+
+// Boards lists the boards and, for admins, lets them edit the settings.
+func (h *ModHandler) Boards(w http.ResponseWriter, r *http.Request) {
+	boards, err := h.BoardService.GetBoards()
+	if err != nil {
+		http.Error(w, "Failed to load boards", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("Accept") == "application/json" {
+		writeJSON(w, http.StatusOK, DataResponse{Data: boards})
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles("web/templates/mod-boards.html"))
+	data := BoardsView{
+		Moderator:    moderatorFromContext(r),
+		Boards:       boards,
+		DefaultBoard: h.BoardService.DefaultSlug,
+		ImageFormats: models.ImageFormats,
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		slog.Error("Failed to render boards template", "error", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+	}
+}
+
+// SaveBoard handles POST /mod/boards. It creates the board named by "slug" or
+// updates its settings: "title", "description", "no_reply_ttl" and
+// "reply_ttl" (durations such as 10m, empty for the global default),
+// "max_threads", the "require_image", "text_only" and "poster_ids" checkboxes
+// and one "image_formats" checkbox per allowed format (none means all).
+func (h *ModHandler) SaveBoard(w http.ResponseWriter, r *http.Request) {
+	wantsJSON := r.Header.Get("Accept") == "application/json"
+	fail := func(err error) {
+		if wantsJSON {
+			writeJSONError(w, statusForError(err), err.Error())
+			return
+		}
+		http.Error(w, err.Error(), statusForError(err))
+	}
+
+	board, err := boardFromForm(r)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := h.BoardService.SaveBoard(moderatorFromContext(r), board); err != nil {
+		fail(err)
+		return
+	}
+
+	if wantsJSON {
+		writeJSON(w, http.StatusOK, board)
+		return
+	}
+	http.Redirect(w, r, "/mod/boards", http.StatusSeeOther)
+}
+
+// DeleteBoard handles POST /mod/boards/{slug}/delete.
+func (h *ModHandler) DeleteBoard(w http.ResponseWriter, r *http.Request) {
+	if err := h.BoardService.DeleteBoard(moderatorFromContext(r), r.PathValue("slug")); err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+	http.Redirect(w, r, "/mod/boards", http.StatusSeeOther)
+}
+
+func boardFromForm(r *http.Request) (*models.Board, error) {
+	board := &models.Board{
+		Slug:         r.FormValue("slug"),
+		Title:        r.FormValue("title"),
+		Description:  r.FormValue("description"),
+		RequireImage: r.FormValue("require_image") != "",
+		TextOnly:     r.FormValue("text_only") != "",
+		PosterIDs:    r.FormValue("poster_ids") != "",
+	}
+
+	var err error
+	if board.Archive.NoReplyTTL, err = parseOptionalDuration(r.FormValue("no_reply_ttl")); err != nil {
+		return nil, err
+	}
+	if board.Archive.ReplyTTL, err = parseOptionalDuration(r.FormValue("reply_ttl")); err != nil {
+		return nil, err
+	}
+	if v := r.FormValue("max_threads"); v != "" {
+		if board.MaxThreads, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid max_threads %q: %w", v, models.ErrInvalidInput)
+		}
+	}
+	for _, v := range r.Form["image_formats"] {
+		format, err := models.ParseImageFormat(v)
+		if err != nil {
+			return nil, err
+		}
+		board.ImageFormats = append(board.ImageFormats, format)
+	}
+	return board, nil
+}
+
+func parseOptionalDuration(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", v, models.ErrInvalidInput)
+	}
+	return d, nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// PageView is the template data for paginated post listings.
+type PageView struct {
+	Board   *models.Board
+	Pinned  []*models.Post
+	Posts   []*models.Post
+	Sort    models.PostSort
+	NextURL string
+	PrevURL string
+}
+
+// PageResponse is the JSON body of paginated post listings.
+type PageResponse struct {
+	Pinned     []*models.Post  `json:"pinned,omitempty"`
+	Data       []*models.Post  `json:"data"`
+	Sort       models.PostSort `json:"sort"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+	Next       string          `json:"next,omitempty"`
+	Prev       string          `json:"prev,omitempty"`
+}
+
+// pageRequestFromQuery reads the cursor, limit and sort query parameters.
+func pageRequestFromQuery(r *http.Request, defaultSort models.PostSort) (models.PageRequest, error) {
+	q := r.URL.Query()
+	page := models.PageRequest{
+		Cursor: q.Get("cursor"),
+		Sort:   models.ParsePostSort(q.Get("sort"), defaultSort),
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return page, fmt.Errorf("invalid limit %q: %w", limit, models.ErrInvalidInput)
+		}
+		page.Limit = n
+	}
+	return page.Normalize(defaultSort), nil
+}
+
+// pageLink builds the URL of a neighbouring page, keeping sort and limit.
+func pageLink(r *http.Request, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+	q := url.Values{}
+	q.Set("cursor", cursor)
+	if sort := r.URL.Query().Get("sort"); sort != "" {
+		q.Set("sort", sort)
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		q.Set("limit", limit)
+	}
+	return r.URL.Path + "?" + q.Encode()
+}
+
+func newPageView(r *http.Request, board *models.Board, page *models.PostPage) PageView {
+	return PageView{
+		Board:   board,
+		Pinned:  page.Pinned,
+		Posts:   page.Posts,
+		Sort:    page.Sort,
+		NextURL: pageLink(r, page.NextCursor),
+		PrevURL: pageLink(r, page.PrevCursor),
+	}
+}
+
+func newPageResponse(r *http.Request, page *models.PostPage) PageResponse {
+	return PageResponse{
+		Pinned:     page.Pinned,
+		Data:       nonNilPosts(page.Posts),
+		Sort:       page.Sort,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+		Next:       pageLink(r, page.NextCursor),
+		Prev:       pageLink(r, page.PrevCursor),
+	}
+}
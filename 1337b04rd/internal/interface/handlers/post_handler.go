@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 
+	"1337b04rd/internal/app/domain/models"
 	"1337b04rd/internal/app/domain/ports"
 	"1337b04rd/internal/app/domain/services"
 )
@@ -15,19 +16,73 @@ type PostHandler struct {
 	PostService    *services.PostService
 	S3Adapter      ports.S3Adapter
 	CommentService ports.CommentService
+	MarkupService  *services.MarkupService
+	BoardService   *services.BoardService
+	Images         *services.ImageService // nil leaves the uploads of refused threads in storage
 }
 
-func NewPostHandler(postService *services.PostService, s3Adapter ports.S3Adapter, commentService ports.CommentService) *PostHandler {
+func NewPostHandler(postService *services.PostService, s3Adapter ports.S3Adapter, commentService ports.CommentService, markupService *services.MarkupService, boardService *services.BoardService) *PostHandler {
 	return &PostHandler{
 		PostService:    postService,
 		S3Adapter:      s3Adapter,
 		CommentService: commentService,
+		MarkupService:  markupService,
+		BoardService:   boardService,
 	}
 }
 
+// threadTemplateFuncs is used by the templates that show rendered markup.
+var threadTemplateFuncs = template.FuncMap{
+	// markup marks the output of services.RenderMarkup as safe HTML. It must
+	// only be applied to TextHTML and SnippetHTML fields.
+	"markup": func(s string) template.HTML { return template.HTML(s) },
+}
+
+// ListBoards shows the board index.
+func (h *PostHandler) ListBoards(w http.ResponseWriter, r *http.Request) {
+	boards, err := h.BoardService.GetBoards()
+	if err != nil {
+		http.Error(w, "Failed to fetch boards", statusForError(err))
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles("web/templates/boards.html"))
+	if err := tmpl.Execute(w, boards); err != nil {
+		slog.Error("Failed to render boards template", "error", err)
+		http.Error(w, "Failed to render boards", http.StatusInternalServerError)
+	}
+}
+
+// RedirectToDefaultBoard answers the URLs that predate boards by redirecting
+// to the same page of the default board.
+func (h *PostHandler) RedirectToDefaultBoard(page string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := "/" + h.BoardService.DefaultSlug + "/" + page
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
+
+// boardFromPath loads the board named by the {board} path segment. It writes
+// the error response and returns nil if there is no such board.
+func (h *PostHandler) boardFromPath(w http.ResponseWriter, r *http.Request) *models.Board {
+	board, err := h.BoardService.GetBoard(r.PathValue("board"))
+	if err != nil {
+		http.Error(w, errorMessage(err, "Failed to fetch board"), statusForError(err))
+		return nil
+	}
+	return board
+}
+
 func (h *PostHandler) ServeCreatePostForm(w http.ResponseWriter, r *http.Request) {
+	board := h.boardFromPath(w, r)
+	if board == nil {
+		return
+	}
 	tmpl := template.Must(template.ParseFiles("web/templates/create-post.html"))
-	tmpl.Execute(w, nil)
+	tmpl.Execute(w, board)
 }
 
 func (h *PostHandler) SubmitPost(w http.ResponseWriter, r *http.Request) {
@@ -36,12 +91,6 @@ func (h *PostHandler) SubmitPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		slog.Error("Failed to parse form", "error", err)
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
-	}
-
 	sessionIDRaw := r.Context().Value("sessionId")
 	sessionID, ok := sessionIDRaw.(string)
 	if !ok || sessionID == "" {
@@ -50,37 +99,51 @@ func (h *PostHandler) SubmitPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := r.FormValue("subject")
-	text := r.FormValue("comment")
-
-	imageURL, err := h.S3Adapter.UploadImage(r, "post")
+	// Read the form; the images are stored only if the board accepts them
+	images, err := uploadImages(h.S3Adapter, h.Images, r, "post", func(images []*models.UploadedImage) error {
+		return h.PostService.CheckImages(r.PathValue("board"), images)
+	})
 	if err != nil {
 		slog.Error("Image upload failed", "error", err)
-		http.Error(w, "Image upload failed", http.StatusInternalServerError)
+		http.Error(w, errorMessage(err, "Image upload failed"), statusForError(err))
 		return
 	}
+	name := r.FormValue("name")
+	title := r.FormValue("subject")
+	text := r.FormValue("comment")
 
-	createdPost, err := h.PostService.CreatePost(sessionID, title, text, imageURL)
+	createdPost, err := h.PostService.CreatePost(sessionID, r.PathValue("board"), name, title, text, images)
 	if err != nil {
+		discardImages(h.Images, r, images)
 		slog.Error("Failed to create post", "error", err)
-		http.Error(w, "Failed to create post", http.StatusInternalServerError)
+		http.Error(w, errorMessage(err, "Failed to create post"), statusForError(err))
 		return
 	}
 
-	slog.Info("Post created", "post_id", createdPost.ID)
-	http.Redirect(w, r, "/create", http.StatusSeeOther)
+	slog.Info("Post created", "post_id", createdPost.ID, "board", createdPost.Board)
+	http.Redirect(w, r, "/"+createdPost.Board+"/create", http.StatusSeeOther)
 }
 
 func (h *PostHandler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
-	posts, err := h.PostService.GetAllPosts()
+	board := h.boardFromPath(w, r)
+	if board == nil {
+		return
+	}
+	page, err := pageRequestFromQuery(r, models.SortByBumpTime)
+	if err != nil {
+		http.Error(w, "Invalid page parameters", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.PostService.GetAllPosts(board.Slug, page)
 	if err != nil {
 		slog.Error("Failed to fetch posts", "error", err)
-		http.Error(w, "Failed to fetch posts", http.StatusInternalServerError)
+		http.Error(w, "Failed to fetch posts", statusForError(err))
 		return
 	}
 
 	tmpl := template.Must(template.ParseFiles("web/templates/catalog.html"))
-	if err := tmpl.Execute(w, posts); err != nil {
+	if err := tmpl.Execute(w, newPageView(r, board, result)); err != nil {
 		slog.Error("Failed to render catalog template", "error", err)
 		http.Error(w, "Failed to render posts", http.StatusInternalServerError)
 	}
@@ -104,17 +167,25 @@ func (h *PostHandler) GetPostByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comments, err := h.CommentService.GetCommentsByPostID(post.ID)
+	opts := commentViewFromQuery(r)
+	comments, err := h.CommentService.GetCommentsView(post.ID, opts)
 	if err != nil {
 		slog.Error("Failed to load comments", "post_id", post.ID, "error", err)
 		http.Error(w, "Error loading comments", http.StatusInternalServerError)
 		return
 	}
 	post.Comments = comments
+	h.MarkupService.RenderThread(post.ID, post, comments)
+
+	board, err := h.BoardService.GetBoard(post.Board)
+	if err != nil {
+		http.Error(w, "Failed to fetch board", statusForError(err))
+		return
+	}
 
-	tmpl := template.Must(template.ParseFiles("web/templates/post.html"))
+	tmpl := template.Must(template.New("post.html").Funcs(threadTemplateFuncs).ParseFiles("web/templates/post.html"))
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, post); err != nil {
+	if err := tmpl.Execute(&buf, PostView{Post: post, Board: board, View: opts.Mode}); err != nil {
 		slog.Error("Failed to render post template", "post_id", post.ID, "error", err)
 		http.Error(w, "Template error", http.StatusInternalServerError)
 		return
@@ -123,15 +194,25 @@ func (h *PostHandler) GetPostByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *PostHandler) GetArchivedPostsHandler(w http.ResponseWriter, r *http.Request) {
-	posts, err := h.PostService.GetArchivedPosts()
+	board := h.boardFromPath(w, r)
+	if board == nil {
+		return
+	}
+	page, err := pageRequestFromQuery(r, models.SortByArchivedAt)
+	if err != nil {
+		http.Error(w, "Invalid page parameters", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.PostService.GetArchivedPosts(board.Slug, page)
 	if err != nil {
 		slog.Error("Failed to fetch archived posts", "error", err)
-		http.Error(w, "Failed to fetch archived posts", http.StatusInternalServerError)
+		http.Error(w, "Failed to fetch archived posts", statusForError(err))
 		return
 	}
 
 	tmpl := template.Must(template.ParseFiles("web/templates/archive.html"))
-	if err := tmpl.Execute(w, posts); err != nil {
+	if err := tmpl.Execute(w, newPageView(r, board, result)); err != nil {
 		slog.Error("Failed to render archive template", "error", err)
 		http.Error(w, "Failed to render posts", http.StatusInternalServerError)
 	}
@@ -155,17 +236,25 @@ func (h *PostHandler) GetArchivedPostByID(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	comments, err := h.CommentService.GetCommentsByPostID(post.ID)
+	opts := commentViewFromQuery(r)
+	comments, err := h.CommentService.GetCommentsView(post.ID, opts)
 	if err != nil {
 		slog.Error("Failed to load archived comments", "post_id", post.ID, "error", err)
 		http.Error(w, "Error loading comments", http.StatusInternalServerError)
 		return
 	}
 	post.Comments = comments
+	h.MarkupService.RenderThread(post.ID, post, comments)
+
+	board, err := h.BoardService.GetBoard(post.Board)
+	if err != nil {
+		http.Error(w, "Failed to fetch board", statusForError(err))
+		return
+	}
 
-	tmpl := template.Must(template.ParseFiles("web/templates/archive-post.html"))
+	tmpl := template.Must(template.New("archive-post.html").Funcs(threadTemplateFuncs).ParseFiles("web/templates/archive-post.html"))
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, post); err != nil {
+	if err := tmpl.Execute(&buf, PostView{Post: post, Board: board, View: opts.Mode}); err != nil {
 		slog.Error("Failed to render archived post template", "post_id", post.ID, "error", err)
 		http.Error(w, "Template error", http.StatusInternalServerError)
 		return
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+// ReportHandler lets users flag posts and comments for moderators.
+type ReportHandler struct {
+	ReportService *services.ReportService
+}
+
+func NewReportHandler(reportService *services.ReportService) *ReportHandler {
+	return &ReportHandler{ReportService: reportService}
+}
+
+// CreateReportRequest is the JSON body accepted by POST /api/v1/reports.
+// The HTML form uses the same field names.
+type CreateReportRequest struct {
+	TargetType string                `json:"target_type"`
+	TargetID   int                   `json:"target_id"`
+	Category   models.ReportCategory `json:"category"`
+	Text       string                `json:"text"`
+}
+
+// SubmitReport handles the report form on thread pages and redirects back
+// to the reported post or comment.
+func (h *ReportHandler) SubmitReport(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := sessionIDFromContext(r)
+	if !ok {
+		http.Error(w, "Missing session", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	targetID, err := strconv.Atoi(r.FormValue("target_id"))
+	if err != nil {
+		http.Error(w, "Invalid target ID", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.ReportService.SubmitReport(sessionID, r.FormValue("target_type"), targetID,
+		models.ReportCategory(r.FormValue("category")), r.FormValue("text"))
+	if err != nil {
+		http.Error(w, err.Error(), statusForError(err))
+		return
+	}
+
+	target := fmt.Sprintf("/post/%d", report.PostID)
+	if report.TargetType == "comment" {
+		target += fmt.Sprintf("#c%d", report.TargetID)
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// CreateReport handles POST /api/v1/reports.
+func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := sessionIDFromContext(r)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "missing session")
+		return
+	}
+
+	var req CreateReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	report, err := h.ReportService.SubmitReport(sessionID, req.TargetType, req.TargetID, req.Category, req.Text)
+	if err != nil {
+		writeJSONError(w, statusForError(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, report)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"1337b04rd/internal/app/domain/models"
+)
+
+// APIError is the body of every error returned by the JSON API.
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse wraps APIError so that all failures share one envelope:
+// {"error": {"code": 404, "message": "post not found"}}
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// DataResponse wraps successful list responses.
+type DataResponse struct {
+	Data any `json:"data"`
+}
+
+// writeJSON encodes v as JSON with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to encode JSON response", "error", err)
+	}
+}
+
+// writeJSONError writes an error envelope with the given status code.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ErrorResponse{Error: APIError{Code: status, Message: message}})
+}
+
+// statusForError maps domain errors to HTTP status codes.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, models.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, models.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrUnsupportedMedia):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorMessage returns the error text for client errors, which explain what
+// to fix, and fallback for server errors, which should not leak details.
+func errorMessage(err error, fallback string) string {
+	if statusForError(err) >= http.StatusInternalServerError {
+		return fallback
+	}
+	return err.Error()
+}
+
+// sessionIDFromContext returns the session ID stored by the auth middleware.
+func sessionIDFromContext(r *http.Request) (string, bool) {
+	sessionID, ok := r.Context().Value("sessionId").(string)
+	return sessionID, ok && sessionID != ""
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+// searchDateLayout is the format of the from and to query parameters.
+const searchDateLayout = "2006-01-02"
+
+// SearchHandler serves the search page and GET /api/v1/search.
+type SearchHandler struct {
+	SearchService *services.SearchService
+	BoardService  *services.BoardService
+}
+
+func NewSearchHandler(searchService *services.SearchService, boardService *services.BoardService) *SearchHandler {
+	return &SearchHandler{SearchService: searchService, BoardService: boardService}
+}
+
+// SearchView is the template data of search.html. Form holds the query
+// parameters as submitted, so the form can be filled in again.
+type SearchView struct {
+	Form    map[string]string
+	Boards  []*models.Board
+	Page    *models.SearchPage // nil until a query is submitted
+	Error   string
+	NextURL string
+}
+
+// SearchResponse is the JSON body of GET /api/v1/search.
+type SearchResponse struct {
+	Data       []models.SearchResult `json:"data"`
+	NextOffset int                   `json:"next_offset,omitempty"`
+	Next       string                `json:"next,omitempty"`
+}
+
+// Search shows the search form and, when q is set, one page of results.
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	boards, err := h.BoardService.GetBoards()
+	if err != nil {
+		http.Error(w, "Failed to load boards", statusForError(err))
+		return
+	}
+
+	q := r.URL.Query()
+	view := SearchView{Boards: boards, Form: map[string]string{}}
+	for _, key := range []string{"q", "board", "scope", "from", "to", "has_image"} {
+		view.Form[key] = q.Get(key)
+	}
+
+	status := http.StatusOK
+	if view.Form["q"] != "" {
+		page, err := h.search(r)
+		if err != nil {
+			status = statusForError(err)
+			view.Error = errorMessage(err, "Search failed, try again later")
+		} else {
+			view.Page = page
+			view.NextURL = searchLink(r, page.NextOffset)
+		}
+	}
+
+	tmpl := template.Must(template.New("search.html").Funcs(threadTemplateFuncs).ParseFiles("web/templates/search.html"))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, view); err != nil {
+		slog.Error("Failed to render search template", "error", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// SearchAPI handles GET /api/v1/search.
+func (h *SearchHandler) SearchAPI(w http.ResponseWriter, r *http.Request) {
+	page, err := h.search(r)
+	if err != nil {
+		writeJSONError(w, statusForError(err), errorMessage(err, "search failed"))
+		return
+	}
+	writeJSON(w, http.StatusOK, SearchResponse{
+		Data:       page.Results,
+		NextOffset: page.NextOffset,
+		Next:       searchLink(r, page.NextOffset),
+	})
+}
+
+func (h *SearchHandler) search(r *http.Request) (*models.SearchPage, error) {
+	query, err := searchQueryFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return h.SearchService.Search(r.Context(), query)
+}
+
+// searchQueryFromRequest reads q, board, scope (all, live, archived), from
+// and to (YYYY-MM-DD, both inclusive), has_image, limit and offset.
+func searchQueryFromRequest(r *http.Request) (models.SearchQuery, error) {
+	q := r.URL.Query()
+	query := models.SearchQuery{
+		Text:     q.Get("q"),
+		Board:    q.Get("board"),
+		HasImage: q.Get("has_image") == "1" || q.Get("has_image") == "true" || q.Get("has_image") == "on",
+	}
+
+	var err error
+	if query.Scope, err = models.ParseSearchScope(q.Get("scope")); err != nil {
+		return query, err
+	}
+	if from := q.Get("from"); from != "" {
+		if query.From, err = time.Parse(searchDateLayout, from); err != nil {
+			return query, fmt.Errorf("invalid from date %q: %w", from, models.ErrInvalidInput)
+		}
+	}
+	if to := q.Get("to"); to != "" {
+		day, err := time.Parse(searchDateLayout, to)
+		if err != nil {
+			return query, fmt.Errorf("invalid to date %q: %w", to, models.ErrInvalidInput)
+		}
+		query.To = day.AddDate(0, 0, 1)
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit <= 0 {
+			return query, fmt.Errorf("invalid limit %q: %w", limit, models.ErrInvalidInput)
+		}
+	}
+	if offset := q.Get("offset"); offset != "" {
+		if query.Offset, err = strconv.Atoi(offset); err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("invalid offset %q: %w", offset, models.ErrInvalidInput)
+		}
+	}
+	return query, nil
+}
+
+// searchLink builds the URL of the next page of results, keeping the query
+// and its filters.
+func searchLink(r *http.Request, offset int) string {
+	if offset == 0 {
+		return ""
+	}
+	q := r.URL.Query()
+	q.Set("offset", strconv.Itoa(offset))
+	return r.URL.Path + "?" + q.Encode()
+}
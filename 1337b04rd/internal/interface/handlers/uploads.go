@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/ports"
+	"1337b04rd/internal/app/domain/services"
+)
+
+// uploadImages reads the form of r and stores its images once check accepts
+// them, taking them off the deletion queue of images first. Images stored
+// before a failure are discarded. images may be nil, which leaves the queue
+// alone.
+func uploadImages(storage ports.S3Adapter, images *services.ImageService, r *http.Request, imageType string, check func([]*models.UploadedImage) error) ([]*models.UploadedImage, error) {
+	uploaded, err := storage.UploadImages(r, imageType, func(batch []*models.UploadedImage) error {
+		if check != nil {
+			if err := check(batch); err != nil {
+				return err
+			}
+		}
+		if images == nil {
+			return nil
+		}
+		return images.Keep(r.Context(), batch)
+	})
+	if err != nil {
+		discardImages(images, r, uploaded)
+	}
+	return uploaded, err
+}
+
+// discardImages queues the uploaded images of a message that was not created
+// for deletion. It goes on when the client has gone away.
+func discardImages(images *services.ImageService, r *http.Request, uploaded []*models.UploadedImage) {
+	if images == nil || len(uploaded) == 0 {
+		return
+	}
+	images.Discard(context.WithoutCancel(r.Context()), uploaded)
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+// FloodMiddleware rejects submissions that exceed the flood limits. Limits
+// apply to both the session and the client IP, so dropping the session
+// cookie does not reset them.
+type FloodMiddleware struct {
+	Guard *services.FloodGuard
+
+	// TrustProxy takes the client IP from X-Forwarded-For / X-Real-IP.
+	// Only enable it behind a reverse proxy that sets these headers.
+	TrustProxy bool
+}
+
+// LimitThreads applies the thread limits to POST requests. Requests the
+// handler answers with an error status do not count.
+func (fm *FloodMiddleware) LimitThreads(next http.Handler) http.Handler {
+	return fm.limit(next, fm.Guard.ReserveThread)
+}
+
+// LimitComments applies the comment limits to POST requests. Requests the
+// handler answers with an error status do not count.
+func (fm *FloodMiddleware) LimitComments(next http.Handler) http.Handler {
+	return fm.limit(next, fm.Guard.ReserveComment)
+}
+
+func (fm *FloodMiddleware) limit(next http.Handler, reserve func(keys ...string) (*services.FloodReservation, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		keys := []string{"ip:" + ClientIP(r, fm.TrustProxy)}
+		if sessionID, ok := r.Context().Value("sessionId").(string); ok && sessionID != "" {
+			keys = append(keys, "session:"+sessionID)
+		}
+
+		reservation, err := reserve(keys...)
+		if err != nil {
+			var limitErr *models.RateLimitError
+			if errors.As(err, &limitErr) {
+				w.Header().Set("Retry-After", strconv.Itoa(limitErr.RetryAfterSeconds()))
+			}
+			log.Printf("flood limit hit for %v on %s: %v", keys, r.URL.Path, err)
+			writeTooManyRequests(w, r, err.Error())
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status >= http.StatusBadRequest {
+			reservation.Cancel()
+		}
+	})
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// writeTooManyRequests answers with 429, using the JSON API error envelope
+// for /api/ requests.
+func writeTooManyRequests(w http.ResponseWriter, r *http.Request, message string) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		http.Error(w, message, http.StatusTooManyRequests)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"code": http.StatusTooManyRequests, "message": message},
+	})
+}
+
+// ClientIP returns the IP address of the client that sent r.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,54 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+	"1337b04rd/internal/interface/middleware"
+)
+
+func TestFloodMiddleware_FailedSubmissionsDoNotCount(t *testing.T) {
+	fm := middleware.FloodMiddleware{Guard: services.NewFloodGuard(models.FloodPolicy{PostInterval: time.Minute}, nil)}
+
+	status := http.StatusBadRequest
+	handler := fm.LimitComments(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	post := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/submit-comment", nil))
+		return rec.Code
+	}
+
+	// Rejected comments leave the poster free to try again at once
+	for i := 0; i < 3; i++ {
+		if code := post(); code != http.StatusBadRequest {
+			t.Fatalf("attempt %d: status %d, want %d", i+1, code, http.StatusBadRequest)
+		}
+	}
+
+	status = http.StatusSeeOther
+	if code := post(); code != http.StatusSeeOther {
+		t.Fatalf("valid comment: status %d, want %d", code, http.StatusSeeOther)
+	}
+	if code := post(); code != http.StatusTooManyRequests {
+		t.Errorf("comment right after a valid one: status %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestFloodMiddleware_IgnoresGet(t *testing.T) {
+	fm := middleware.FloodMiddleware{Guard: services.NewFloodGuard(models.FloodPolicy{PostInterval: time.Minute}, nil)}
+	handler := fm.LimitThreads(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/create-post", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %d: status %d", i+1, rec.Code)
+		}
+	}
+}
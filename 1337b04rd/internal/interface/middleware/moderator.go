@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"1337b04rd/internal/app/domain/models"
+	"1337b04rd/internal/app/domain/services"
+)
+
+// ModSessionCookie holds the moderator session token. It is independent of
+// the anonymous "sessionId" cookie.
+const ModSessionCookie = "modSession"
+
+type ModMiddleware struct {
+	ModerationService *services.ModerationService
+}
+
+// RequireModerator lets the request through only with a valid moderator
+// session and stores the moderator in the request context.
+func (mm *ModMiddleware) RequireModerator(next http.Handler) http.Handler {
+	return mm.requireRole(next, false)
+}
+
+// RequireAdmin is like RequireModerator but also demands the admin role.
+func (mm *ModMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return mm.requireRole(next, true)
+}
+
+func (mm *ModMiddleware) requireRole(next http.Handler, adminOnly bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := getCookieValue(r, ModSessionCookie)
+		var moderator *models.Moderator
+		ok := false
+		if err == nil {
+			moderator, ok = mm.ModerationService.Authenticate(token)
+		}
+		if !ok {
+			if r.Method == http.MethodGet {
+				http.Redirect(w, r, "/mod/login", http.StatusSeeOther)
+				return
+			}
+			http.Error(w, "Moderator login required", http.StatusUnauthorized)
+			return
+		}
+		if adminOnly && !moderator.IsAdmin() {
+			log.Printf("moderator '%s' denied admin access to %s", moderator.Name, r.URL.Path)
+			http.Error(w, "Admin role required", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "moderator", moderator)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -7,16 +7,69 @@ import (
 	"1337b04rd/internal/interface/middleware"
 )
 
-func RegisterRoutes(mux *http.ServeMux, postHandler *handlers.PostHandler, commentHandler *handlers.CommentHandler, authMiddleware middleware.AuthMiddleware) {
+func RegisterRoutes(mux *http.ServeMux, postHandler *handlers.PostHandler, commentHandler *handlers.CommentHandler, apiHandler *handlers.APIHandler, reportHandler *handlers.ReportHandler, searchHandler *handlers.SearchHandler, modHandler *handlers.ModHandler, authMiddleware middleware.AuthMiddleware, floodMiddleware middleware.FloodMiddleware, modMiddleware middleware.ModMiddleware) {
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./web/templates"))))
 
-	mux.Handle("/posts", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(postHandler.GetAllPosts)))
-	mux.Handle("/create-post", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(postHandler.SubmitPost)))
-	mux.Handle("/create", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(postHandler.ServeCreatePostForm)))
+	// Boards. They get their own mux under "/" so that {board} never clashes
+	// with the fixed routes of this one; models.Board reserves those names.
+	boards := http.NewServeMux()
+	boards.HandleFunc("GET /{$}", postHandler.ListBoards)
+	boards.HandleFunc("GET /{board}/{$}", postHandler.GetAllPosts)
+	boards.HandleFunc("GET /{board}/archive", postHandler.GetArchivedPostsHandler)
+	boards.HandleFunc("GET /{board}/create", postHandler.ServeCreatePostForm)
+	boards.Handle("POST /{board}/create", floodMiddleware.LimitThreads(http.HandlerFunc(postHandler.SubmitPost)))
+	mux.Handle("/", authMiddleware.LoginOrLastVisitHandler(boards))
+
+	// URLs from before boards existed point at the default board
+	mux.Handle("/posts", postHandler.RedirectToDefaultBoard(""))
+	mux.Handle("/create", postHandler.RedirectToDefaultBoard("create"))
+	mux.Handle("/archive", postHandler.RedirectToDefaultBoard("archive"))
+	mux.Handle("/create-post", authMiddleware.LoginOrLastVisitHandler(floodMiddleware.LimitThreads(http.HandlerFunc(postHandler.SubmitPost))))
+
+	// Threads are addressed by their ID alone, which is unique across boards
 	mux.Handle("/post/", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(postHandler.GetPostByID)))
 
-	mux.Handle("/submit-comment", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(commentHandler.CreateComment)))
+	mux.Handle("/submit-comment", authMiddleware.LoginOrLastVisitHandler(floodMiddleware.LimitComments(http.HandlerFunc(commentHandler.CreateComment))))
+
+	mux.Handle("POST /report", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(reportHandler.SubmitReport)))
+
+	mux.Handle("GET /search", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(searchHandler.Search)))
 
-	mux.Handle("/archive", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(postHandler.GetArchivedPostsHandler)))
 	mux.Handle("/archived/post/", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(postHandler.GetArchivedPostByID)))
+
+	// JSON API. The routes without a board use the default board.
+	mux.Handle("GET /api/v1/boards", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(apiHandler.ListBoards)))
+	mux.Handle("GET /api/v1/boards/{board}", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(apiHandler.GetBoard)))
+	mux.Handle("GET /api/v1/boards/{board}/posts", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(apiHandler.ListPosts)))
+	mux.Handle("POST /api/v1/boards/{board}/posts", authMiddleware.LoginOrLastVisitHandler(floodMiddleware.LimitThreads(http.HandlerFunc(apiHandler.CreatePost))))
+	mux.Handle("GET /api/v1/boards/{board}/archive", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(apiHandler.ListArchivedPosts)))
+	mux.Handle("GET /api/v1/posts", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(apiHandler.ListPosts)))
+	mux.Handle("POST /api/v1/posts", authMiddleware.LoginOrLastVisitHandler(floodMiddleware.LimitThreads(http.HandlerFunc(apiHandler.CreatePost))))
+	mux.Handle("GET /api/v1/posts/{id}", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(apiHandler.GetPost)))
+	mux.Handle("GET /api/v1/posts/{id}/comments", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(apiHandler.ListComments)))
+	mux.Handle("POST /api/v1/posts/{id}/comments", authMiddleware.LoginOrLastVisitHandler(floodMiddleware.LimitComments(http.HandlerFunc(apiHandler.CreateComment))))
+	mux.Handle("GET /api/v1/archive", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(apiHandler.ListArchivedPosts)))
+	mux.Handle("GET /api/v1/archive/{id}", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(apiHandler.GetArchivedPost)))
+	mux.Handle("GET /api/v1/search", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(searchHandler.SearchAPI)))
+	mux.Handle("POST /api/v1/reports", authMiddleware.LoginOrLastVisitHandler(http.HandlerFunc(reportHandler.CreateReport)))
+
+	// Moderation
+	mux.HandleFunc("GET /mod/login", modHandler.ServeLoginForm)
+	mux.HandleFunc("POST /mod/login", modHandler.Login)
+	mux.HandleFunc("POST /mod/logout", modHandler.Logout)
+	mux.Handle("GET /mod", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.Dashboard)))
+	mux.Handle("POST /mod/posts/{id}/{action}", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.PostAction)))
+	mux.Handle("POST /mod/comments/{id}/delete", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.DeleteComment)))
+	mux.Handle("GET /mod/reports", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.Reports)))
+	mux.Handle("POST /mod/reports/{type}/{id}/dismiss", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.DismissReports)))
+	mux.Handle("POST /mod/reports/{type}/{id}/act", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.ActOnReports)))
+	mux.Handle("GET /mod/images", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.BannedImages)))
+	mux.Handle("POST /mod/images/ban", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.BanImage)))
+	mux.Handle("POST /mod/images/{hash}/unban", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.UnbanImage)))
+	mux.Handle("GET /mod/filters", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.FilterRules)))
+	mux.Handle("POST /mod/filters", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.AddFilterRule)))
+	mux.Handle("POST /mod/filters/{id}/delete", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.DeleteFilterRule)))
+	mux.Handle("GET /mod/boards", modMiddleware.RequireModerator(http.HandlerFunc(modHandler.Boards)))
+	mux.Handle("POST /mod/boards", modMiddleware.RequireAdmin(http.HandlerFunc(modHandler.SaveBoard)))
+	mux.Handle("POST /mod/boards/{slug}/delete", modMiddleware.RequireAdmin(http.HandlerFunc(modHandler.DeleteBoard)))
 }
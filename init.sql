@@ -12,18 +12,52 @@ CREATE TABLE sessions (
     avatar TEXT,
     last_visit TIMESTAMP
 );
+-- Доски (/b/, /g/, ...) и их настройки
+CREATE TABLE boards (
+    slug TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    archive_ttl_no_replies INT NOT NULL DEFAULT 0,  -- Секунды, 0 = глобальное значение
+    archive_ttl_replies INT NOT NULL DEFAULT 0,
+    max_threads INT NOT NULL DEFAULT 0,             -- 0 = без ограничения
+    require_image BOOLEAN NOT NULL DEFAULT FALSE,   -- Картинка обязательна для нового треда
+    text_only BOOLEAN NOT NULL DEFAULT FALSE,       -- Картинки запрещены
+    poster_ids BOOLEAN NOT NULL DEFAULT FALSE,      -- Показывать ID автора в треде
+    image_formats TEXT[] NOT NULL DEFAULT '{}',     -- Разрешённые форматы картинок (jpeg, png, gif), пусто = все
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+INSERT INTO boards (slug, title, description, require_image, text_only) VALUES
+    ('b', 'Random', 'Anything goes.', FALSE, FALSE),
+    ('g', 'Technology', 'Computers, gadgets and software.', FALSE, FALSE),
+    ('tech', 'Programming', 'Text-only board for code and algorithms.', FALSE, TRUE);
+
 -- Таблица постов
 CREATE TABLE posts (
     id SERIAL PRIMARY KEY,  -- Автоинкремент
+    board TEXT NOT NULL REFERENCES boards(slug),  -- Доска треда
     title TEXT NOT NULL,
     text TEXT NOT NULL,
     user_name TEXT NOT NULL,  -- Имя пользователя
+    tripcode TEXT,            -- Трипкод (!xxx или !!xxx), если указан name#password
+    poster_id TEXT,           -- ID автора внутри треда (хэш сессии и треда)
     user_avatar TEXT,         -- Аватар пользователя
     image_url TEXT,
+    thumbnail_url TEXT,       -- Уменьшенная копия картинки (макс. 250px)
+    image_name TEXT,          -- Исходное имя файла картинки (только для показа)
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    bumped_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,  -- Время последнего бампа треда
     archived_at TIMESTAMP,
-    is_hidden BOOLEAN DEFAULT FALSE
+    is_hidden BOOLEAN DEFAULT FALSE,
+    is_locked BOOLEAN NOT NULL DEFAULT FALSE,  -- Новые ответы запрещены
+    is_pinned BOOLEAN NOT NULL DEFAULT FALSE,  -- Закреплён сверху каталога, не архивируется
+    text_hash TEXT,   -- SHA-256 нормализованного текста (поиск дублей)
+    image_hash TEXT,  -- SHA-256 картинки
+    -- Полнотекстовый поиск: заголовок весит больше текста
+    search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', text), 'B')
+    ) STORED
 );
 
 -- Таблица комментариев
@@ -32,8 +66,135 @@ CREATE TABLE comments (
     post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,  -- Внешний ключ на posts
     parent_comment_id INT REFERENCES comments(id) ON DELETE CASCADE,  -- Внешний ключ на comments
     user_name TEXT NOT NULL,  -- Имя пользователя
+    tripcode TEXT,            -- Трипкод
+    poster_id TEXT,           -- ID автора внутри треда
     user_avatar TEXT,         -- Аватар пользователя
     text TEXT NOT NULL,
     image_url TEXT,   
+    thumbnail_url TEXT,       -- Уменьшенная копия картинки
+    image_name TEXT,          -- Исходное имя файла картинки
+    sage BOOLEAN NOT NULL DEFAULT FALSE,  -- Ответ без бампа треда
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    text_hash TEXT,
+    image_hash TEXT,
+    search_vector tsvector GENERATED ALWAYS AS (setweight(to_tsvector('simple', text), 'B')) STORED  -- Полнотекстовый поиск
+);
+
+CREATE INDEX idx_posts_bumped_at ON posts (board, bumped_at DESC, id DESC) WHERE archived_at IS NULL;
+CREATE INDEX idx_posts_archived_at ON posts (archived_at) WHERE archived_at IS NOT NULL;
+CREATE INDEX idx_comments_post_id ON comments (post_id, created_at);
+CREATE INDEX idx_posts_search ON posts USING GIN (search_vector);
+CREATE INDEX idx_comments_search ON comments USING GIN (search_vector);
+CREATE INDEX idx_posts_text_hash ON posts (text_hash, created_at) WHERE text_hash IS NOT NULL;
+CREATE INDEX idx_posts_image_hash ON posts (image_hash, created_at) WHERE image_hash IS NOT NULL;
+CREATE INDEX idx_comments_text_hash ON comments (text_hash, created_at) WHERE text_hash IS NOT NULL;
+CREATE INDEX idx_comments_image_hash ON comments (image_hash, created_at) WHERE image_hash IS NOT NULL;
+
+-- Вложения постов и комментариев (image_url и т.п. выше дублируют первое из них)
+CREATE TABLE attachments (
+    id SERIAL PRIMARY KEY,
+    post_id INT REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id INT REFERENCES comments(id) ON DELETE CASCADE,
+    position INT NOT NULL,        -- Порядок внутри сообщения, с 0
+    url TEXT NOT NULL,            -- Публичный URL, ключ объекта = sha256 + расширение
+    thumbnail_url TEXT,           -- Уменьшенная копия, если она есть
+    content_type TEXT NOT NULL,   -- MIME-тип, определённый по содержимому
+    size BIGINT NOT NULL,         -- Размер сохранённого файла в байтах
+    width INT NOT NULL,
+    height INT NOT NULL,
+    name TEXT,                    -- Исходное имя файла (только для показа)
+    hash TEXT NOT NULL,           -- sha256 загруженного файла, для поиска дублей
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))  -- Ровно один владелец
+);
+
+CREATE UNIQUE INDEX idx_attachments_post ON attachments (post_id, position) WHERE post_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_attachments_comment ON attachments (comment_id, position) WHERE comment_id IS NOT NULL;
+CREATE INDEX idx_attachments_hash ON attachments (hash, created_at);
+CREATE INDEX idx_attachments_url ON attachments (url);
+
+-- Модераторы (отдельно от анонимных сессий)
+CREATE TABLE moderators (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'moderator',  -- moderator | admin
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE mod_sessions (
+    token TEXT PRIMARY KEY,
+    moderator_id INT NOT NULL REFERENCES moderators(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Журнал действий модераторов
+CREATE TABLE mod_actions (
+    id SERIAL PRIMARY KEY,
+    moderator_id INT REFERENCES moderators(id) ON DELETE SET NULL,
+    moderator_name TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,  -- post | comment | image
+    target_id INT NOT NULL,     -- 0 для картинки, забаненной по хэшу
+    image_hash TEXT,            -- Хэш картинки для ban_image и unban_image
+    reason TEXT NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT now()
 );
+
+-- Жалобы пользователей на посты и комментарии
+CREATE TABLE reports (
+    id SERIAL PRIMARY KEY,
+    target_type TEXT NOT NULL,  -- post | comment
+    target_id INT NOT NULL,
+    post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,  -- Тред, в котором находится цель
+    session_id TEXT NOT NULL,
+    category TEXT NOT NULL,
+    text TEXT,
+    status TEXT NOT NULL DEFAULT 'open',  -- open | dismissed | actioned
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    resolved_by INT REFERENCES moderators(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    UNIQUE (session_id, target_type, target_id)
+);
+
+CREATE INDEX idx_reports_open ON reports(status, created_at);
+CREATE INDEX idx_reports_session ON reports(session_id, created_at);
+
+-- Навсегда запрещённые картинки (по SHA-256)
+CREATE TABLE banned_images (
+    hash TEXT PRIMARY KEY,
+    reason TEXT NOT NULL,
+    moderator_id INT REFERENCES moderators(id) ON DELETE SET NULL,
+    moderator_name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Правила фильтра текста (редактируются модераторами без перезапуска)
+CREATE TABLE filter_rules (
+    id SERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,  -- ban_word | replace | strip_urls | allow_domain | min_length | max_length
+    pattern TEXT NOT NULL DEFAULT '',
+    replacement TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Цитаты >>id из комментариев (для обратных ссылок "Replies")
+CREATE TABLE comment_references (
+    comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    target_type TEXT NOT NULL,  -- post | comment
+    target_id INT NOT NULL,
+    PRIMARY KEY (comment_id, target_type, target_id)
+);
+
+CREATE INDEX idx_comment_references_target ON comment_references(target_type, target_id);
+
+-- Объекты хранилища, ожидающие удаления: картинки удалённых тредов, старые
+-- ключи после миграции и файлы несостоявшихся загрузок. Перед удалением
+-- снова проверяется, что на URL никто не ссылается
+CREATE TABLE image_deletions (
+    url TEXT PRIMARY KEY,       -- Публичный URL картинки или миниатюры
+    due_at TIMESTAMP NOT NULL   -- Удаляется не раньше этого времени
+);
+
+CREATE INDEX idx_image_deletions_due ON image_deletions (due_at);